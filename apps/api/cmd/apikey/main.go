@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"strings"
+	"time"
+
+	"altread-go/api/internal/config"
+	"altread-go/api/internal/database"
+	"altread-go/api/internal/services"
+
+	"github.com/google/uuid"
+)
+
+func main() {
+	var (
+		command = flag.String("command", "list", "API key command: create, revoke, list")
+		name    = flag.String("name", "", "Key name for create command")
+		tenant  = flag.String("tenant", "", "Tenant ID for create and list commands")
+		scopes  = flag.String("scopes", "", "Comma-separated scopes for create command")
+		expires = flag.Duration("expires", 0, "Key lifetime for create command (0 = never expires)")
+		id      = flag.String("id", "", "Key ID for revoke command")
+	)
+	flag.Parse()
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+
+	if err := database.Init(cfg); err != nil {
+		log.Fatalf("Failed to initialize database: %v", err)
+	}
+	defer database.Close()
+
+	ctx := context.Background()
+	svc := services.NewAPIKeyService()
+
+	switch *command {
+	case "create":
+		var scopeList []string
+		for _, scope := range strings.Split(*scopes, ",") {
+			if scope = strings.TrimSpace(scope); scope != "" {
+				scopeList = append(scopeList, scope)
+			}
+		}
+
+		var expiresAt *time.Time
+		if *expires > 0 {
+			t := time.Now().Add(*expires)
+			expiresAt = &t
+		}
+
+		rawKey, key, err := svc.CreateAPIKey(ctx, *name, *tenant, scopeList, expiresAt)
+		if err != nil {
+			log.Fatalf("Failed to create API key: %v", err)
+		}
+		log.Printf("Created API key %s for tenant %s with scopes: %s", key.ID, key.TenantID, key.Scopes)
+		log.Println("Store this key now, it cannot be shown again:")
+		log.Printf("  %s", rawKey)
+
+	case "revoke":
+		keyID, err := uuid.Parse(*id)
+		if err != nil {
+			log.Fatal("A valid key ID is required for revoke command. Use -id flag")
+		}
+		if err := svc.RevokeAPIKey(ctx, keyID); err != nil {
+			log.Fatalf("Failed to revoke API key: %v", err)
+		}
+		log.Printf("Revoked API key %s", keyID)
+
+	case "list":
+		keys, err := svc.ListAPIKeys(ctx, *tenant)
+		if err != nil {
+			log.Fatalf("Failed to list API keys: %v", err)
+		}
+		if len(keys) == 0 {
+			log.Println("No API keys found")
+			return
+		}
+		for _, key := range keys {
+			status := "active"
+			if key.RevokedAt != nil {
+				status = "revoked"
+			} else if key.ExpiresAt != nil && time.Now().After(*key.ExpiresAt) {
+				status = "expired"
+			}
+			log.Printf("%s  %s...  tenant=%s  name=%s  scopes=%s  %s", key.ID, key.KeyPrefix, key.TenantID, key.Name, key.Scopes, status)
+		}
+
+	default:
+		log.Fatalf("Unknown command: %s. Use: create, revoke, or list", *command)
+	}
+}
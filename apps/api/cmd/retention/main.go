@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"strings"
+
+	"altread-go/api/internal/config"
+	"altread-go/api/internal/database"
+	"altread-go/api/internal/services"
+)
+
+func main() {
+	var (
+		dryRun = flag.Bool("dry-run", false, "Count expired rows without deleting them")
+		tables = flag.String("tables", "", "Comma-separated tables to purge (default: all managed tables)")
+	)
+	flag.Parse()
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+
+	if err := database.Init(cfg); err != nil {
+		log.Fatalf("Failed to initialize database: %v", err)
+	}
+	defer database.Close()
+
+	flushLogs := services.InstallLogger(cfg)
+	defer flushLogs()
+
+	var tableList []string
+	for _, table := range strings.Split(*tables, ",") {
+		if table = strings.TrimSpace(table); table != "" {
+			tableList = append(tableList, table)
+		}
+	}
+
+	worker := services.NewRetentionWorker(cfg)
+	for _, policy := range worker.Policies() {
+		if policy.Retention <= 0 {
+			log.Printf("%s: kept forever", policy.Table)
+		}
+	}
+
+	results, err := worker.Purge(context.Background(), *dryRun, tableList...)
+	for _, result := range results {
+		cutoff := result.Cutoff.UTC().Format("2006-01-02 15:04:05")
+		switch {
+		case *dryRun:
+			log.Printf("%s: %d rows older than %s would be deleted", result.Table, result.Rows, cutoff)
+		case result.Archive != "":
+			log.Printf("%s: deleted %d rows older than %s, archived to %s", result.Table, result.Rows, cutoff, result.Archive)
+		default:
+			log.Printf("%s: deleted %d rows older than %s", result.Table, result.Rows, cutoff)
+		}
+	}
+	if err != nil {
+		flushLogs()
+		log.Fatalf("Failed to purge expired rows: %v", err)
+	}
+}
@@ -0,0 +1,17 @@
+// Package apierror writes the JSON error bodies shared by the API handlers and
+// middleware
+package apierror
+
+import (
+	"altread-go/api/internal/tracing"
+
+	"github.com/labstack/echo/v4"
+)
+
+// JSON writes an error body tagged with the request ID so clients can quote it in bug reports
+func JSON(c echo.Context, status int, body map[string]interface{}) error {
+	if requestID := tracing.RequestIDFromContext(c.Request().Context()); requestID != "" {
+		body["request_id"] = requestID
+	}
+	return c.JSON(status, body)
+}
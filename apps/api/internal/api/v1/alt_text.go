@@ -2,13 +2,17 @@ package v1
 
 import (
 	"fmt"
+	"log/slog"
 	"net/http"
 	"strings"
 	"time"
 
+	"altread-go/api/internal/api/apierror"
 	"altread-go/api/internal/constants"
+	"altread-go/api/internal/logging"
 	"altread-go/api/internal/schemas"
 	"altread-go/api/internal/services"
+	"altread-go/api/internal/tracing"
 
 	"github.com/labstack/echo/v4"
 )
@@ -16,14 +20,14 @@ import (
 // AltTextHandler handles HTTP requests for alt text generation
 type AltTextHandler struct {
 	openAIService *services.OpenAIService
-	logService    *services.LogService
+	eventService  *services.EventService
 }
 
 // NewAltTextHandler creates a new alt text handler instance
-func NewAltTextHandler(openAIService *services.OpenAIService, logService *services.LogService) *AltTextHandler {
+func NewAltTextHandler(openAIService *services.OpenAIService, eventService *services.EventService) *AltTextHandler {
 	return &AltTextHandler{
 		openAIService: openAIService,
-		logService:    logService,
+		eventService:  eventService,
 	}
 }
 
@@ -32,7 +36,7 @@ func (h *AltTextHandler) GenerateAltText(c echo.Context) error {
 
 	var req schemas.GenerateAltTextRequest
 	if err := c.Bind(&req); err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+		return apierror.JSON(c, http.StatusBadRequest, map[string]interface{}{
 			"success": false,
 			"error":   "Invalid request body",
 			"code":    constants.ErrCodeInvalidRequest,
@@ -41,20 +45,31 @@ func (h *AltTextHandler) GenerateAltText(c echo.Context) error {
 
 	if req.Image == "" {
 		duration := int(time.Since(startTime).Milliseconds())
-		h.logRequest(c.Request().Method, c.Request().URL.Path, http.StatusBadRequest, duration)
-		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+		h.logRequest(c, http.StatusBadRequest, duration)
+		return apierror.JSON(c, http.StatusBadRequest, map[string]interface{}{
 			"success": false,
 			"error":   "Image is required",
 			"code":    constants.ErrCodeMissingImage,
 		})
 	}
 
+	recordEvent(c, h.eventService, constants.EventImageUploaded, map[string]interface{}{
+		"image_bytes": len(req.Image),
+	})
+
+	req.UserAgent = c.Request().UserAgent()
+	req.AcceptLanguage = c.Request().Header.Get("Accept-Language")
+
 	ctx := c.Request().Context()
 	response, err := h.openAIService.GenerateAltText(ctx, &req)
 	if err != nil {
 		duration := int(time.Since(startTime).Milliseconds())
-		h.logRequest(c.Request().Method, c.Request().URL.Path, http.StatusInternalServerError, duration)
-		return c.JSON(http.StatusInternalServerError, map[string]interface{}{
+		h.logRequest(c, http.StatusInternalServerError, duration)
+		recordEvent(c, h.eventService, constants.EventAltTextFailed, map[string]interface{}{
+			"status":             http.StatusInternalServerError,
+			"processing_time_ms": duration,
+		})
+		return apierror.JSON(c, http.StatusInternalServerError, map[string]interface{}{
 			"success": false,
 			"error":   "Internal server error",
 			"code":    constants.ErrCodeInternalError,
@@ -65,31 +80,48 @@ func (h *AltTextHandler) GenerateAltText(c echo.Context) error {
 	statusCode := http.StatusOK
 	if !response.Success {
 		if response.Error != nil && (*response.Error == "OpenAI API key is not configured" ||
-			strings.Contains(*response.Error, "API key")) {
+			strings.Contains(*response.Error, "API key") ||
+			*response.Error == services.ErrCircuitOpen.Error()) {
 			statusCode = http.StatusServiceUnavailable
 		} else {
 			statusCode = http.StatusInternalServerError
 		}
 	}
 
-	h.logRequest(c.Request().Method, c.Request().URL.Path, statusCode, duration)
+	h.logRequest(c, statusCode, duration)
 
 	if !response.Success {
+		data := map[string]interface{}{
+			"status":             statusCode,
+			"processing_time_ms": response.ProcessingTime,
+		}
+		if response.Error != nil {
+			data["error"] = *response.Error
+		}
+		recordEvent(c, h.eventService, constants.EventAltTextFailed, data)
+
+		response.RequestID = tracing.RequestIDFromContext(ctx)
 		return c.JSON(statusCode, response)
 	}
 
+	recordEvent(c, h.eventService, constants.EventAltTextGenerated, map[string]interface{}{
+		"processing_time_ms": response.ProcessingTime,
+		"alt_text_length":    len(response.AltText),
+	})
+
 	return c.JSON(http.StatusOK, response)
 }
 
-func (h *AltTextHandler) logRequest(method, path string, status int, durationMs int) {
-	level := "info"
+func (h *AltTextHandler) logRequest(c echo.Context, status int, durationMs int) {
+	level := slog.LevelInfo
 	if status >= 400 {
-		level = "warning"
+		level = slog.LevelWarn
 	}
 	if status >= 500 {
-		level = "error"
+		level = slog.LevelError
 	}
 
-	message := fmt.Sprintf("%s %s - %d (%dms)", method, path, status, durationMs)
-	h.logService.Log(level, "api", message, nil, nil)
+	req := c.Request()
+	message := fmt.Sprintf("%s %s - %d (%dms)", req.Method, req.URL.Path, status, durationMs)
+	slog.Log(req.Context(), level, message, logging.ServiceKey, "api", "status", status, "duration_ms", durationMs)
 }
@@ -1,59 +1,473 @@
 package v1
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
+	"altread-go/api/internal/api/apierror"
+	"altread-go/api/internal/auth"
+	"altread-go/api/internal/constants"
+	"altread-go/api/internal/logging"
 	"altread-go/api/internal/services"
 
 	"github.com/labstack/echo/v4"
 )
 
+const (
+	analyticsStreamBuffer    = 256
+	analyticsStreamHeartbeat = 15 * time.Second
+)
+
+var validTimeRanges = map[string]bool{
+	"7d":  true,
+	"30d": true,
+	"90d": true,
+	"all": true,
+}
+
 // AnalyticsHandler handles HTTP requests for analytics data
 type AnalyticsHandler struct {
 	analyticsService *services.AnalyticsService
+	analyticsCache   *services.AnalyticsCache
+	exportService    *services.ExportService
+	analyticsBus     *services.AnalyticsBus
 }
 
 // NewAnalyticsHandler creates a new analytics handler instance
-func NewAnalyticsHandler(analyticsService *services.AnalyticsService) *AnalyticsHandler {
+func NewAnalyticsHandler(analyticsService *services.AnalyticsService, analyticsCache *services.AnalyticsCache, exportService *services.ExportService, analyticsBus *services.AnalyticsBus) *AnalyticsHandler {
 	return &AnalyticsHandler{
 		analyticsService: analyticsService,
+		analyticsCache:   analyticsCache,
+		exportService:    exportService,
+		analyticsBus:     analyticsBus,
 	}
 }
 
-// GetAnalytics retrieves analytics data for the specified time range
+// GetAnalytics retrieves analytics data for a preset time range or a custom
+// from/to range, bucketed by granularity in the requested time zone. Results
+// come from the analytics cache when possible, and conditional requests are
+// answered with 304 Not Modified.
 func (h *AnalyticsHandler) GetAnalytics(c echo.Context) error {
-	// Get time range from query parameter, default to "30d"
-	timeRange := c.QueryParam("timeRange")
-	if timeRange == "" {
-		timeRange = "30d"
+	tenantID, ok := resolveTenantScope(c)
+	if !ok {
+		return apierror.JSON(c, http.StatusForbidden, map[string]interface{}{
+			"success": false,
+			"error":   "Access to the requested tenant is not allowed",
+			"code":    constants.ErrCodeInsufficientScope,
+		})
 	}
 
-	// Validate time range
-	validRanges := map[string]bool{
-		"7d":  true,
-		"30d": true,
-		"90d": true,
-		"all": true,
+	query, err := parseAnalyticsQuery(c, tenantID)
+	if err != nil {
+		return apierror.JSON(c, http.StatusBadRequest, map[string]interface{}{
+			"success": false,
+			"error":   err.Error(),
+			"code":    constants.ErrCodeInvalidRequest,
+		})
 	}
-	if !validRanges[timeRange] {
-		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+
+	ctx := c.Request().Context()
+	cached, err := h.analyticsCache.GetAnalytics(ctx, h.analyticsService, query)
+	if errors.Is(err, services.ErrTooManyBuckets) {
+		return apierror.JSON(c, http.StatusBadRequest, map[string]interface{}{
 			"success": false,
-			"error":   "Invalid timeRange parameter. Must be one of: 7d, 30d, 90d, all",
+			"error":   fmt.Sprintf("Range is too long for %s granularity; at most %d points are returned", query.Granularity, services.MaxAnalyticsBuckets),
+			"code":    constants.ErrCodeInvalidRequest,
+		})
+	}
+	if err != nil {
+		return apierror.JSON(c, http.StatusInternalServerError, map[string]interface{}{
+			"success": false,
+			"error":   "Failed to retrieve analytics data",
+		})
+	}
+
+	header := c.Response().Header()
+	header.Set("ETag", cached.ETag)
+	header.Set(echo.HeaderLastModified, cached.LastModified.Format(http.TimeFormat))
+	header.Set("Cache-Control", "private, no-cache")
+	if notModified(c.Request(), cached.ETag, cached.LastModified) {
+		return c.NoContent(http.StatusNotModified)
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"success": true,
+		"data":    cached.Data,
+	})
+}
+
+// notModified evaluates If-None-Match, or If-Modified-Since when there is no
+// If-None-Match, against a response's validators
+func notModified(req *http.Request, etag string, lastModified time.Time) bool {
+	if match := req.Header.Get("If-None-Match"); match != "" {
+		for _, candidate := range strings.Split(match, ",") {
+			candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+			if candidate == "*" || candidate == etag {
+				return true
+			}
+		}
+		return false
+	}
+
+	if since := req.Header.Get(echo.HeaderIfModifiedSince); since != "" {
+		t, err := http.ParseTime(since)
+		return err == nil && !lastModified.After(t)
+	}
+	return false
+}
+
+// StreamAnalytics pushes processed images and voice plays as Server-Sent Events
+// as they are tracked by any replica, each with the running totals since the
+// start of the UTC day. A snapshot event with the totals so far opens the
+// stream, and another is sent when the totals restart at UTC midnight.
+func (h *AnalyticsHandler) StreamAnalytics(c echo.Context) error {
+	tenantID, ok := resolveTenantScope(c)
+	if !ok {
+		return apierror.JSON(c, http.StatusForbidden, map[string]interface{}{
+			"success": false,
+			"error":   "Access to the requested tenant is not allowed",
+			"code":    constants.ErrCodeInsufficientScope,
 		})
 	}
 
+	// Subscribe before reading the totals so no update falls between them;
+	// updates the totals already count are skipped by RunningTotals.Add
+	updates, unsubscribe := h.analyticsBus.Subscribe(analyticsStreamBuffer)
+	defer unsubscribe()
+
 	ctx := c.Request().Context()
-	data, err := h.analyticsService.GetAnalytics(ctx, timeRange)
+	day := time.Now().UTC().Truncate(24 * time.Hour)
+	totals, err := h.analyticsService.RunningTotals(ctx, tenantID, day)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]interface{}{
+		return apierror.JSON(c, http.StatusInternalServerError, map[string]interface{}{
 			"success": false,
 			"error":   "Failed to retrieve analytics data",
 		})
 	}
 
+	res := c.Response()
+	res.Header().Set(echo.HeaderContentType, "text/event-stream")
+	res.Header().Set("Cache-Control", "no-cache")
+	res.Header().Set("Connection", "keep-alive")
+	res.Header().Set("X-Accel-Buffering", "no")
+	res.WriteHeader(http.StatusOK)
+
+	id := 0
+	send := func(event string, payload interface{}) bool {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return true
+		}
+		id++
+		if _, err := fmt.Fprintf(res, "id: %d\nevent: %s\ndata: %s\n\n", id, event, data); err != nil {
+			return false
+		}
+		res.Flush()
+		return true
+	}
+
+	if !send("snapshot", totals) {
+		return nil
+	}
+
+	heartbeat := time.NewTicker(analyticsStreamHeartbeat)
+	defer heartbeat.Stop()
+	nextDay := time.NewTimer(time.Until(day.AddDate(0, 0, 1)))
+	defer nextDay.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(res, ": ping\n\n"); err != nil {
+				return nil
+			}
+			res.Flush()
+		case <-nextDay.C:
+			day = day.AddDate(0, 0, 1)
+			if totals, err = h.analyticsService.RunningTotals(ctx, tenantID, day); err != nil {
+				return nil
+			}
+			if !send("snapshot", totals) {
+				return nil
+			}
+			nextDay.Reset(time.Until(day.AddDate(0, 0, 1)))
+		case update := <-updates:
+			if tenantID != "" && update.TenantID != tenantID {
+				continue
+			}
+			if !totals.Add(update) {
+				continue
+			}
+			if !send(update.Type, map[string]interface{}{
+				"update": update,
+				"totals": totals,
+			}) {
+				return nil
+			}
+		}
+	}
+}
+
+// ExportAnalytics streams raw image_uploads or voice_plays rows as CSV or NDJSON,
+// filtered by range, success, model and tenant, with the selected columns
+func (h *AnalyticsHandler) ExportAnalytics(c echo.Context) error {
+	tenantID, ok := resolveTenantScope(c)
+	if !ok {
+		return apierror.JSON(c, http.StatusForbidden, map[string]interface{}{
+			"success": false,
+			"error":   "Access to the requested tenant is not allowed",
+			"code":    constants.ErrCodeInsufficientScope,
+		})
+	}
+
+	req, err := parseExportRequest(c, tenantID)
+	if err == nil {
+		err = req.Validate()
+	}
+	if err != nil {
+		return apierror.JSON(c, http.StatusBadRequest, map[string]interface{}{
+			"success": false,
+			"error":   err.Error(),
+			"code":    constants.ErrCodeInvalidRequest,
+		})
+	}
+
+	res := c.Response()
+	w := &exportWriter{
+		res:         res,
+		contentType: req.ContentType(),
+		filename:    fmt.Sprintf("%s-%s.%s", req.Dataset, time.Now().UTC().Format("20060102-150405"), req.Format),
+	}
+
+	ctx := c.Request().Context()
+	err = h.exportService.Export(ctx, req, w, w.flush)
+	if err == nil {
+		// An empty NDJSON export writes nothing
+		w.start()
+	}
+	if err != nil && !res.Committed {
+		return apierror.JSON(c, http.StatusInternalServerError, map[string]interface{}{
+			"success": false,
+			"error":   "Failed to export analytics data",
+		})
+	}
+	if err != nil {
+		// The status line is already sent, so the truncated body is all the client sees
+		slog.ErrorContext(ctx, "Analytics export failed mid-stream", logging.ServiceKey, "api",
+			"dataset", req.Dataset, "error", err)
+	}
+	return nil
+}
+
+// exportWriter sends the export's download headers with its first bytes, so an
+// export that fails before writing anything is answered with a JSON error
+// rather than an attachment
+type exportWriter struct {
+	res         *echo.Response
+	contentType string
+	filename    string
+}
+
+func (w *exportWriter) start() {
+	if w.res.Committed {
+		return
+	}
+	header := w.res.Header()
+	header.Set(echo.HeaderContentType, w.contentType)
+	header.Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", w.filename))
+	header.Set("Cache-Control", "no-store")
+	header.Set("X-Accel-Buffering", "no")
+	w.res.WriteHeader(http.StatusOK)
+}
+
+func (w *exportWriter) Write(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	w.start()
+	return w.res.Write(p)
+}
+
+func (w *exportWriter) flush() {
+	if w.res.Committed {
+		w.res.Flush()
+	}
+}
+
+// GetPerformance charts sampled system performance metrics for the specified time range.
+// The data spans all tenants, so it is limited to admins when authentication is enabled.
+func (h *AnalyticsHandler) GetPerformance(c echo.Context) error {
+	if !isAdmin(c) {
+		return apierror.JSON(c, http.StatusForbidden, map[string]interface{}{
+			"success": false,
+			"error":   "Missing required scope: " + constants.ScopeAdmin,
+			"code":    constants.ErrCodeInsufficientScope,
+		})
+	}
+
+	timeRange := c.QueryParam("timeRange")
+	if timeRange == "" {
+		timeRange = "7d"
+	}
+	if !validTimeRanges[timeRange] {
+		return apierror.JSON(c, http.StatusBadRequest, map[string]interface{}{
+			"success": false,
+			"error":   "Invalid timeRange parameter. Must be one of: 7d, 30d, 90d, all",
+		})
+	}
+
+	ctx := c.Request().Context()
+	series, err := h.analyticsService.GetPerformanceSeries(ctx, timeRange, c.QueryParam("metric"), c.QueryParam("route"), c.QueryParam("model"))
+	if err != nil {
+		return apierror.JSON(c, http.StatusInternalServerError, map[string]interface{}{
+			"success": false,
+			"error":   "Failed to retrieve performance data",
+		})
+	}
+
 	return c.JSON(http.StatusOK, map[string]interface{}{
 		"success": true,
-		"data":    data,
+		"data":    series,
 	})
 }
 
+// resolveTenantScope returns the tenant whose data the caller may read. Admins and
+// anonymous callers (when authentication is disabled) may pick any tenant via the
+// "tenant" query parameter, or none to see all tenants; everyone else is pinned to
+// their own tenant and ok is false if they ask for another one or have no tenant.
+func resolveTenantScope(c echo.Context) (tenantID string, ok bool) {
+	requested := c.QueryParam("tenant")
+
+	principal := auth.PrincipalFromContext(c.Request().Context())
+	if principal == nil || principal.HasScope(constants.ScopeAdmin) {
+		return requested, true
+	}
+
+	if principal.TenantID == "" || (requested != "" && requested != principal.TenantID) {
+		return "", false
+	}
+
+	return principal.TenantID, true
+}
+
+// parseAnalyticsQuery reads the range, granularity and time zone of an analytics
+// request. RFC 3339 from/to parameters take precedence over timeRange.
+func parseAnalyticsQuery(c echo.Context, tenantID string) (services.AnalyticsQuery, error) {
+	tz := c.QueryParam("tz")
+	if tz == "" {
+		tz = "UTC"
+	}
+	loc, err := time.LoadLocation(tz)
+	if err != nil || tz == "Local" {
+		return services.AnalyticsQuery{}, errors.New("Invalid tz parameter. Must be an IANA time zone name such as Europe/Berlin")
+	}
+
+	granularity := c.QueryParam("granularity")
+	if granularity == "" {
+		granularity = services.GranularityDay
+	}
+	if !services.ValidGranularity(granularity) {
+		return services.AnalyticsQuery{}, errors.New("Invalid granularity parameter. Must be one of: hour, day, week, month")
+	}
+
+	var query services.AnalyticsQuery
+	fromParam, toParam := c.QueryParam("from"), c.QueryParam("to")
+	if fromParam != "" || toParam != "" {
+		query = services.AnalyticsQuery{
+			To:          time.Now(),
+			Granularity: granularity,
+			Location:    loc,
+			TenantID:    tenantID,
+		}
+		for _, param := range []struct {
+			name  string
+			value string
+			dst   *time.Time
+		}{{"from", fromParam, &query.From}, {"to", toParam, &query.To}} {
+			if param.value == "" {
+				continue
+			}
+			t, err := time.Parse(time.RFC3339, param.value)
+			if err != nil {
+				return services.AnalyticsQuery{}, fmt.Errorf("Invalid %s parameter. Must be an RFC 3339 timestamp", param.name)
+			}
+			*param.dst = t
+		}
+	} else {
+		timeRange := c.QueryParam("timeRange")
+		if timeRange == "" {
+			timeRange = "30d"
+		}
+		if !validTimeRanges[timeRange] {
+			return services.AnalyticsQuery{}, errors.New("Invalid timeRange parameter. Must be one of: 7d, 30d, 90d, all")
+		}
+		query = services.NewAnalyticsQuery(timeRange, granularity, loc, tenantID)
+	}
+
+	if err := query.Validate(); err != nil {
+		if errors.Is(err, services.ErrTooManyBuckets) {
+			return services.AnalyticsQuery{}, fmt.Errorf("Range is too long for %s granularity; at most %d points are returned", granularity, services.MaxAnalyticsBuckets)
+		}
+		return services.AnalyticsQuery{}, errors.New("Invalid range. from must be before to")
+	}
+	return query, nil
+}
+
+// parseExportRequest reads the dataset, format, RFC 3339 range, filters and
+// comma-separated columns of an export request
+func parseExportRequest(c echo.Context, tenantID string) (*services.ExportRequest, error) {
+	req := &services.ExportRequest{
+		Dataset:  c.QueryParam("dataset"),
+		Format:   c.QueryParam("format"),
+		To:       time.Now(),
+		Model:    c.QueryParam("model"),
+		TenantID: tenantID,
+	}
+	if req.Dataset == "" {
+		req.Dataset = services.ExportDatasetImageUploads
+	}
+	if req.Format == "" {
+		req.Format = services.ExportFormatCSV
+	}
+
+	for _, param := range []struct {
+		name string
+		dst  *time.Time
+	}{{"from", &req.From}, {"to", &req.To}} {
+		value := c.QueryParam(param.name)
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid %s parameter. Must be an RFC 3339 timestamp", param.name)
+		}
+		*param.dst = t
+	}
+
+	if value := c.QueryParam("success"); value != "" {
+		success, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, errors.New("Invalid success parameter. Must be true or false")
+		}
+		req.Success = &success
+	}
+
+	if columns := c.QueryParam("columns"); columns != "" {
+		for _, name := range strings.Split(columns, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				req.Columns = append(req.Columns, name)
+			}
+		}
+	}
+
+	return req, nil
+}
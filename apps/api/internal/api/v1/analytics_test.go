@@ -0,0 +1,50 @@
+package v1
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"altread-go/api/internal/auth"
+	"altread-go/api/internal/constants"
+
+	"github.com/labstack/echo/v4"
+)
+
+func TestResolveTenantScope(t *testing.T) {
+	reader := &auth.Principal{Subject: "key-1", TenantID: "acme", Scopes: []string{constants.ScopeAnalyticsRead}}
+	noTenant := &auth.Principal{Subject: "user-1", Scopes: []string{constants.ScopeAnalyticsRead}}
+	admin := &auth.Principal{Subject: "key-2", TenantID: "acme", Scopes: []string{constants.ScopeAdmin}}
+
+	tests := []struct {
+		name      string
+		principal *auth.Principal
+		query     string
+		want      string
+		wantOK    bool
+	}{
+		{name: "anonymous sees all tenants", want: "", wantOK: true},
+		{name: "anonymous may pick a tenant", query: "?tenant=globex", want: "globex", wantOK: true},
+		{name: "tenant sees its own data", principal: reader, want: "acme", wantOK: true},
+		{name: "tenant may name itself", principal: reader, query: "?tenant=acme", want: "acme", wantOK: true},
+		{name: "tenant may not pick another tenant", principal: reader, query: "?tenant=globex", wantOK: false},
+		{name: "principal without tenant is rejected", principal: noTenant, wantOK: false},
+		{name: "principal without tenant may not pick one", principal: noTenant, query: "?tenant=acme", wantOK: false},
+		{name: "admin sees all tenants", principal: admin, want: "", wantOK: true},
+		{name: "admin may pick a tenant", principal: admin, query: "?tenant=globex", want: "globex", wantOK: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/analytics"+tt.query, nil)
+			if tt.principal != nil {
+				req = req.WithContext(auth.WithPrincipal(req.Context(), tt.principal))
+			}
+			c := echo.New().NewContext(req, httptest.NewRecorder())
+
+			got, ok := resolveTenantScope(c)
+			if ok != tt.wantOK || (ok && got != tt.want) {
+				t.Errorf("resolveTenantScope() = %q, %v, want %q, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
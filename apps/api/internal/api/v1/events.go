@@ -0,0 +1,101 @@
+package v1
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"altread-go/api/internal/api/apierror"
+	"altread-go/api/internal/constants"
+	"altread-go/api/internal/logging"
+	"altread-go/api/internal/schemas"
+	"altread-go/api/internal/services"
+
+	"github.com/labstack/echo/v4"
+)
+
+// EventsHandler handles analytics events reported by clients
+type EventsHandler struct {
+	eventService *services.EventService
+}
+
+// NewEventsHandler creates a new events handler instance
+func NewEventsHandler(eventService *services.EventService) *EventsHandler {
+	return &EventsHandler{
+		eventService: eventService,
+	}
+}
+
+// RecordEvent validates a client event against the schema of its type and stores it
+func (h *EventsHandler) RecordEvent(c echo.Context) error {
+	var req schemas.ClientEventRequest
+	if err := c.Bind(&req); err != nil {
+		return apierror.JSON(c, http.StatusBadRequest, map[string]interface{}{
+			"success": false,
+			"error":   "Invalid request body",
+			"code":    constants.ErrCodeInvalidRequest,
+		})
+	}
+
+	data, err := services.ValidateClientEvent(&req)
+	if errors.Is(err, services.ErrInvalidEvent) {
+		return apierror.JSON(c, http.StatusBadRequest, map[string]interface{}{
+			"success": false,
+			"error":   err.Error(),
+			"code":    constants.ErrCodeInvalidEvent,
+		})
+	}
+	if err != nil {
+		return apierror.JSON(c, http.StatusInternalServerError, map[string]interface{}{
+			"success": false,
+			"error":   "Failed to record event",
+			"code":    constants.ErrCodeInternalError,
+		})
+	}
+
+	meta := eventMeta(c)
+	if req.SessionID != "" {
+		meta.SessionID = req.SessionID
+	}
+	if err := h.eventService.Record(c.Request().Context(), req.Type, data, meta); err != nil {
+		return apierror.JSON(c, http.StatusInternalServerError, map[string]interface{}{
+			"success": false,
+			"error":   "Failed to record event",
+			"code":    constants.ErrCodeInternalError,
+		})
+	}
+
+	return c.JSON(http.StatusAccepted, map[string]interface{}{
+		"success": true,
+	})
+}
+
+// eventMeta describes the caller of the current request for analytics events
+func eventMeta(c echo.Context) services.EventMeta {
+	req := c.Request()
+	return services.EventMeta{
+		ClientUserID: strings.TrimSpace(req.Header.Get("X-User-ID")),
+		SessionID:    strings.TrimSpace(req.Header.Get("X-Session-ID")),
+		IPAddress:    c.RealIP(),
+		UserAgent:    req.UserAgent(),
+	}
+}
+
+// recordEvent stores a server-side analytics event in the background; failures
+// are logged and never affect the response
+func recordEvent(c echo.Context, eventService *services.EventService, eventType string, data map[string]interface{}) {
+	if eventService == nil {
+		return
+	}
+
+	meta := eventMeta(c)
+	ctx := context.WithoutCancel(c.Request().Context())
+	go func() {
+		if err := eventService.Record(ctx, eventType, data, meta); err != nil {
+			slog.ErrorContext(ctx, "Failed to record analytics event", logging.ServiceKey, "events",
+				"event_type", eventType, "error", err)
+		}
+	}()
+}
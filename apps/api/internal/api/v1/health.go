@@ -0,0 +1,51 @@
+package v1
+
+import (
+	"net/http"
+	"time"
+
+	"altread-go/api/internal/services"
+
+	"github.com/labstack/echo/v4"
+)
+
+// HealthHandler handles liveness, readiness and health probes
+type HealthHandler struct {
+	healthService *services.HealthService
+}
+
+// NewHealthHandler creates a new health handler instance
+func NewHealthHandler(healthService *services.HealthService) *HealthHandler {
+	return &HealthHandler{
+		healthService: healthService,
+	}
+}
+
+// Livez reports that the process is running and able to serve HTTP
+func (h *HealthHandler) Livez(c echo.Context) error {
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"status":    services.HealthOK,
+		"timestamp": time.Now().UTC(),
+	})
+}
+
+// Readyz reports whether the API can serve traffic; only critical dependencies are checked
+func (h *HealthHandler) Readyz(c echo.Context) error {
+	report := h.healthService.Readiness(c.Request().Context())
+	return c.JSON(reportStatusCode(report), report)
+}
+
+// Health reports the status and latency of every dependency
+func (h *HealthHandler) Health(c echo.Context) error {
+	report := h.healthService.Health(c.Request().Context())
+	return c.JSON(reportStatusCode(report), report)
+}
+
+// reportStatusCode returns 503 only when a critical component fails, so orchestrators
+// keep routing traffic to replicas that are merely degraded
+func reportStatusCode(report *services.HealthReport) int {
+	if report.Status == services.HealthFail {
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusOK
+}
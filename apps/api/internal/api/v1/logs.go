@@ -0,0 +1,195 @@
+package v1
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"altread-go/api/internal/api/apierror"
+	"altread-go/api/internal/auth"
+	"altread-go/api/internal/constants"
+	"altread-go/api/internal/logging"
+	"altread-go/api/internal/services"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	logTailBuffer    = 256
+	logTailHeartbeat = 15 * time.Second
+)
+
+// LogsHandler serves the admin API for reading application logs
+type LogsHandler struct {
+	logQuery   *services.LogQueryService
+	logService *services.LogService
+}
+
+// NewLogsHandler creates a new logs handler instance
+func NewLogsHandler(logQuery *services.LogQueryService, logService *services.LogService) *LogsHandler {
+	return &LogsHandler{
+		logQuery:   logQuery,
+		logService: logService,
+	}
+}
+
+// QueryLogs returns application logs filtered by level, service, trace ID, tenant,
+// time range and message text, newest first with cursor pagination
+func (h *LogsHandler) QueryLogs(c echo.Context) error {
+	if !isAdmin(c) {
+		return apierror.JSON(c, http.StatusForbidden, map[string]interface{}{
+			"success": false,
+			"error":   "Missing required scope: " + constants.ScopeAdmin,
+			"code":    constants.ErrCodeInsufficientScope,
+		})
+	}
+
+	filter, err := parseLogFilter(c)
+	if err != nil {
+		return apierror.JSON(c, http.StatusBadRequest, map[string]interface{}{
+			"success": false,
+			"error":   err.Error(),
+			"code":    constants.ErrCodeInvalidRequest,
+		})
+	}
+
+	limit := services.DefaultLogPageSize
+	if limitParam := c.QueryParam("limit"); limitParam != "" {
+		limit, err = strconv.Atoi(limitParam)
+		if err != nil || limit < 1 || limit > services.MaxLogPageSize {
+			return apierror.JSON(c, http.StatusBadRequest, map[string]interface{}{
+				"success": false,
+				"error":   fmt.Sprintf("Invalid limit parameter. Must be between 1 and %d", services.MaxLogPageSize),
+				"code":    constants.ErrCodeInvalidRequest,
+			})
+		}
+	}
+
+	page, err := h.logQuery.QueryLogs(c.Request().Context(), filter, c.QueryParam("cursor"), limit)
+	if errors.Is(err, services.ErrInvalidCursor) {
+		return apierror.JSON(c, http.StatusBadRequest, map[string]interface{}{
+			"success": false,
+			"error":   "Invalid cursor parameter",
+			"code":    constants.ErrCodeInvalidRequest,
+		})
+	}
+	if err != nil {
+		return apierror.JSON(c, http.StatusInternalServerError, map[string]interface{}{
+			"success": false,
+			"error":   "Failed to retrieve logs",
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"success": true,
+		"data":    page,
+	})
+}
+
+// TailLogs streams new application logs as Server-Sent Events as they are
+// written by this API instance. The level, service, trace and tenant filters
+// of QueryLogs apply; the time range and search filters do not.
+func (h *LogsHandler) TailLogs(c echo.Context) error {
+	if !isAdmin(c) {
+		return apierror.JSON(c, http.StatusForbidden, map[string]interface{}{
+			"success": false,
+			"error":   "Missing required scope: " + constants.ScopeAdmin,
+			"code":    constants.ErrCodeInsufficientScope,
+		})
+	}
+
+	filter, err := parseLogFilter(c)
+	if err != nil {
+		return apierror.JSON(c, http.StatusBadRequest, map[string]interface{}{
+			"success": false,
+			"error":   err.Error(),
+			"code":    constants.ErrCodeInvalidRequest,
+		})
+	}
+
+	records, unsubscribe := h.logService.Subscribe(logTailBuffer)
+	defer unsubscribe()
+
+	res := c.Response()
+	res.Header().Set(echo.HeaderContentType, "text/event-stream")
+	res.Header().Set("Cache-Control", "no-cache")
+	res.Header().Set("Connection", "keep-alive")
+	res.Header().Set("X-Accel-Buffering", "no")
+	res.WriteHeader(http.StatusOK)
+	res.Flush()
+
+	heartbeat := time.NewTicker(logTailHeartbeat)
+	defer heartbeat.Stop()
+
+	ctx := c.Request().Context()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(res, ": ping\n\n"); err != nil {
+				return nil
+			}
+			res.Flush()
+		case record := <-records:
+			if !filter.Matches(record) {
+				continue
+			}
+			data, err := json.Marshal(record)
+			if err != nil {
+				continue
+			}
+			if _, err := fmt.Fprintf(res, "id: %d\nevent: log\ndata: %s\n\n", record.ID, data); err != nil {
+				return nil
+			}
+			res.Flush()
+		}
+	}
+}
+
+// isAdmin reports whether the caller may read data across tenants; anonymous
+// callers are allowed since they only exist when authentication is disabled
+func isAdmin(c echo.Context) bool {
+	principal := auth.PrincipalFromContext(c.Request().Context())
+	return principal == nil || principal.HasScope(constants.ScopeAdmin)
+}
+
+func parseLogFilter(c echo.Context) (*services.LogFilter, error) {
+	filter := &services.LogFilter{
+		Service:  c.QueryParam("service"),
+		TraceID:  c.QueryParam("traceId"),
+		TenantID: c.QueryParam("tenant"),
+		Search:   strings.TrimSpace(c.QueryParam("q")),
+	}
+
+	if levels := c.QueryParam("level"); levels != "" {
+		for _, name := range strings.Split(levels, ",") {
+			level, ok := logging.ParseLevel(name)
+			if !ok {
+				return nil, errors.New("Invalid level parameter. Must be a comma-separated list of: debug, info, warning, error")
+			}
+			filter.Levels = append(filter.Levels, string(level))
+		}
+	}
+
+	for _, param := range []struct {
+		name string
+		dst  *time.Time
+	}{{"from", &filter.From}, {"to", &filter.To}} {
+		value := c.QueryParam(param.name)
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid %s parameter. Must be an RFC 3339 timestamp", param.name)
+		}
+		*param.dst = t
+	}
+
+	return filter, nil
+}
@@ -0,0 +1,50 @@
+package v1
+
+import (
+	"net/http"
+	"time"
+
+	"altread-go/api/internal/api/apierror"
+	"altread-go/api/internal/metrics"
+
+	"github.com/labstack/echo/v4"
+)
+
+// SystemHandler handles HTTP requests for API performance data
+type SystemHandler struct{}
+
+// NewSystemHandler creates a new system handler instance
+func NewSystemHandler() *SystemHandler {
+	return &SystemHandler{}
+}
+
+// GetMetrics returns per-endpoint request rates, error rates and latency
+// percentiles over a sliding window for the analytics dashboard
+func (h *SystemHandler) GetMetrics(c echo.Context) error {
+	windowParam := c.QueryParam("window")
+	if windowParam == "" {
+		windowParam = "15m"
+	}
+
+	window, err := time.ParseDuration(windowParam)
+	if err != nil || window < time.Minute || window > metrics.EndpointLatency.MaxWindow() {
+		return apierror.JSON(c, http.StatusBadRequest, map[string]interface{}{
+			"success": false,
+			"error":   "Invalid window parameter. Must be a duration between 1m and 1h, e.g. 5m, 15m, 1h",
+		})
+	}
+
+	endpoints := metrics.EndpointLatency.Snapshot(window)
+	if endpoints == nil {
+		endpoints = []metrics.WindowSnapshot{}
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"success": true,
+		"data": map[string]interface{}{
+			"window":      windowParam,
+			"generatedAt": time.Now().UTC(),
+			"endpoints":   endpoints,
+		},
+	})
+}
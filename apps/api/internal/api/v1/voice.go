@@ -3,34 +3,45 @@ package v1
 import (
 	"context"
 	"fmt"
+	"log/slog"
 	"net/http"
+	"sort"
+	"strconv"
 	"strings"
+	"unicode/utf8"
 
+	"altread-go/api/internal/api/apierror"
 	"altread-go/api/internal/constants"
+	"altread-go/api/internal/logging"
 	"altread-go/api/internal/schemas"
 	"altread-go/api/internal/services"
+	"altread-go/api/internal/tracing"
 
 	"github.com/labstack/echo/v4"
 )
 
 // VoiceHandler handles HTTP requests for TTS voice operations
 type VoiceHandler struct {
-	ttsService *services.OpenAITTSService
-	dbService  *services.DatabaseService
+	ttsService        *services.OpenAITTSService
+	dbService         *services.DatabaseService
+	eventService      *services.EventService
+	voiceUsageService *services.VoiceUsageService
 }
 
 // NewVoiceHandler creates a new voice handler instance
-func NewVoiceHandler(ttsService *services.OpenAITTSService, dbService *services.DatabaseService) *VoiceHandler {
+func NewVoiceHandler(ttsService *services.OpenAITTSService, dbService *services.DatabaseService, eventService *services.EventService, voiceUsageService *services.VoiceUsageService) *VoiceHandler {
 	return &VoiceHandler{
-		ttsService: ttsService,
-		dbService:  dbService,
+		ttsService:        ttsService,
+		dbService:         dbService,
+		eventService:      eventService,
+		voiceUsageService: voiceUsageService,
 	}
 }
 
 func (h *VoiceHandler) GenerateSpeech(c echo.Context) error {
 	var req schemas.TTSRequest
 	if err := c.Bind(&req); err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+		return apierror.JSON(c, http.StatusBadRequest, map[string]interface{}{
 			"success": false,
 			"error":   "Invalid request body",
 			"code":    constants.ErrCodeInvalidRequest,
@@ -38,7 +49,7 @@ func (h *VoiceHandler) GenerateSpeech(c echo.Context) error {
 	}
 
 	if req.Text == "" || strings.TrimSpace(req.Text) == "" {
-		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+		return apierror.JSON(c, http.StatusBadRequest, map[string]interface{}{
 			"success": false,
 			"error":   "Text is required for speech generation",
 			"code":    constants.ErrCodeMissingText,
@@ -46,7 +57,7 @@ func (h *VoiceHandler) GenerateSpeech(c echo.Context) error {
 	}
 
 	if req.Voice == "" {
-		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+		return apierror.JSON(c, http.StatusBadRequest, map[string]interface{}{
 			"success": false,
 			"error":   "Voice is required",
 			"code":    constants.ErrCodeMissingVoice,
@@ -54,15 +65,16 @@ func (h *VoiceHandler) GenerateSpeech(c echo.Context) error {
 	}
 
 	if !h.ttsService.ValidateVoice(req.Voice) {
-		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+		return apierror.JSON(c, http.StatusBadRequest, map[string]interface{}{
 			"success": false,
 			"error":   "Only OpenAI voices are supported. Valid voices: " + strings.Join(constants.OpenAIVoiceList, ", "),
 			"code":    constants.ErrCodeInvalidVoice,
 		})
 	}
 
-	if len(req.Text) > constants.MaxTextLength {
-		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+	textLength := utf8.RuneCountInString(req.Text)
+	if textLength > constants.MaxTextLength {
+		return apierror.JSON(c, http.StatusBadRequest, map[string]interface{}{
 			"success": false,
 			"error":   fmt.Sprintf("Text too long. Maximum %d characters allowed.", constants.MaxTextLength),
 			"code":    constants.ErrCodeTextTooLong,
@@ -71,8 +83,14 @@ func (h *VoiceHandler) GenerateSpeech(c echo.Context) error {
 
 	ctx := c.Request().Context()
 	response, err := h.ttsService.GenerateSpeech(ctx, &req)
+	h.trackVoicePlay(ctx, &req, response, err)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]interface{}{
+		recordEvent(c, h.eventService, constants.EventVoicePlayFailed, map[string]interface{}{
+			"voice_name":  req.Voice,
+			"text_length": textLength,
+			"error":       err.Error(),
+		})
+		return apierror.JSON(c, http.StatusInternalServerError, map[string]interface{}{
 			"success": false,
 			"error":   "Failed to generate speech",
 			"code":    constants.ErrCodeTTSGenerationError,
@@ -80,21 +98,29 @@ func (h *VoiceHandler) GenerateSpeech(c echo.Context) error {
 	}
 
 	if !response.Success {
+		data := map[string]interface{}{
+			"voice_name":  req.Voice,
+			"text_length": textLength,
+		}
+		if response.Error != nil {
+			data["error"] = *response.Error
+		}
+		recordEvent(c, h.eventService, constants.EventVoicePlayFailed, data)
+
+		response.RequestID = tracing.RequestIDFromContext(ctx)
+		if response.Code != nil && *response.Code == constants.ErrCodeProviderUnavailable {
+			return c.JSON(http.StatusServiceUnavailable, response)
+		}
 		return c.JSON(http.StatusBadRequest, response)
 	}
 
-	go func() {
-		event := &schemas.VoicePlayEvent{
-			VoiceName:    req.Voice,
-			TextLength:   len(req.Text),
-			DurationMS:   0,
-			Success:      true,
-			ErrorMessage: nil,
-		}
-		_ = h.dbService.TrackVoicePlayFromSchema(context.Background(), event)
-	}()
+	recordEvent(c, h.eventService, constants.EventVoicePlayStarted, map[string]interface{}{
+		"voice_name":  req.Voice,
+		"text_length": textLength,
+	})
 
-	c.Response().Header().Set("Content-Type", "audio/mpeg")
+	_, _, format := h.ttsService.SpeechOptions(&req)
+	c.Response().Header().Set("Content-Type", speechContentType(format))
 	c.Response().Header().Set("Content-Length", fmt.Sprintf("%d", len(response.AudioBuffer)))
 	c.Response().Header().Set("Cache-Control", "no-cache")
 
@@ -106,7 +132,138 @@ func (h *VoiceHandler) GenerateSpeech(c echo.Context) error {
 	return nil
 }
 
+// speechContentType returns the media type of audio in a TTS response format
+func speechContentType(format string) string {
+	switch format {
+	case "opus":
+		return "audio/ogg"
+	case "aac":
+		return "audio/aac"
+	case "flac":
+		return "audio/flac"
+	case "wav":
+		return "audio/wav"
+	case "pcm":
+		return "audio/L16;rate=24000;channels=1"
+	default:
+		return "audio/mpeg"
+	}
+}
+
+// trackVoicePlay records the outcome of a speech request in voice_plays in the
+// background, including failed ones
+func (h *VoiceHandler) trackVoicePlay(ctx context.Context, req *schemas.TTSRequest, response *schemas.TTSResponse, err error) {
+	model, speed, format := h.ttsService.SpeechOptions(req)
+	event := &schemas.VoicePlayEvent{
+		VoiceName:      req.Voice,
+		TextLength:     utf8.RuneCountInString(req.Text),
+		Model:          model,
+		Speed:          speed,
+		ResponseFormat: format,
+	}
+	switch {
+	case err != nil:
+		message := err.Error()
+		event.ErrorMessage = &message
+		event.ErrorCode = constants.ErrCodeTTSGenerationError
+	case response.Success:
+		event.Success = true
+		event.DurationMS = response.SynthesisTimeMS
+		event.AudioBytes = len(response.AudioBuffer)
+	default:
+		event.DurationMS = response.SynthesisTimeMS
+		event.ErrorMessage = response.Error
+		if response.Code != nil {
+			event.ErrorCode = *response.Code
+		}
+	}
+
+	trackCtx := context.WithoutCancel(ctx)
+	go func() {
+		if err := h.dbService.TrackVoicePlayFromSchema(trackCtx, event); err != nil {
+			slog.ErrorContext(trackCtx, "Failed to track voice play", logging.ServiceKey, "api",
+				"voice", event.VoiceName, "success", event.Success, "error", err)
+		}
+	}()
+}
+
+// GetOpenAIVoices lists the available voices. With sort=popular, the voices the
+// caller's tenant plays most come first, with their usage.
 func (h *VoiceHandler) GetOpenAIVoices(c echo.Context) error {
+	sortOrder := c.QueryParam("sort")
+	if sortOrder != "" && sortOrder != "popular" {
+		return apierror.JSON(c, http.StatusBadRequest, map[string]interface{}{
+			"success": false,
+			"error":   "Invalid sort parameter. Must be: popular",
+			"code":    constants.ErrCodeInvalidRequest,
+		})
+	}
+
+	voiceObjects := h.voiceInfos()
+	if sortOrder == "popular" {
+		usage, err := h.voiceUsageService.PopularVoices(c.Request().Context(), len(voiceObjects))
+		if err != nil {
+			return apierror.JSON(c, http.StatusInternalServerError, map[string]interface{}{
+				"success": false,
+				"error":   "Failed to retrieve voice usage",
+			})
+		}
+
+		withUsage(voiceObjects, usage)
+		sort.SliceStable(voiceObjects, func(i, j int) bool {
+			return usageCount(voiceObjects[i]) > usageCount(voiceObjects[j])
+		})
+	}
+
+	return c.JSON(http.StatusOK, GetVoicesResponse{
+		Success: true,
+		Voices:  voiceObjects,
+	})
+}
+
+// GetPopularVoices lists the voices the caller's tenant plays most, with their
+// play counts and when they were last used
+func (h *VoiceHandler) GetPopularVoices(c echo.Context) error {
+	voiceObjects := h.voiceInfos()
+
+	limit := len(voiceObjects)
+	if limitParam := c.QueryParam("limit"); limitParam != "" {
+		n, err := strconv.Atoi(limitParam)
+		if err != nil || n < 1 || n > len(voiceObjects) {
+			return apierror.JSON(c, http.StatusBadRequest, map[string]interface{}{
+				"success": false,
+				"error":   fmt.Sprintf("Invalid limit parameter. Must be between 1 and %d", len(voiceObjects)),
+				"code":    constants.ErrCodeInvalidRequest,
+			})
+		}
+		limit = n
+	}
+
+	usage, err := h.voiceUsageService.PopularVoices(c.Request().Context(), len(voiceObjects))
+	if err != nil {
+		return apierror.JSON(c, http.StatusInternalServerError, map[string]interface{}{
+			"success": false,
+			"error":   "Failed to retrieve voice usage",
+		})
+	}
+
+	withUsage(voiceObjects, usage)
+	popular := make([]VoiceInfo, 0, limit)
+	for _, u := range usage {
+		for _, v := range voiceObjects {
+			if v.ID == u.VoiceName && len(popular) < limit {
+				popular = append(popular, v)
+			}
+		}
+	}
+
+	return c.JSON(http.StatusOK, GetVoicesResponse{
+		Success: true,
+		Voices:  popular,
+	})
+}
+
+func (h *VoiceHandler) voiceInfos() []VoiceInfo {
 	voices := h.ttsService.GetAvailableVoices()
 
 	voiceObjects := make([]VoiceInfo, len(voices))
@@ -117,9 +274,29 @@ func (h *VoiceHandler) GetOpenAIVoices(c echo.Context) error {
 			Description: v["description"],
 		}
 	}
+	return voiceObjects
+}
 
-	return c.JSON(http.StatusOK, GetVoicesResponse{
-		Success: true,
-		Voices:  voiceObjects,
-	})
+// withUsage sets the usage of each voice; voices that were never played get a
+// zero count
+func withUsage(voiceObjects []VoiceInfo, usage []services.VoiceUsage) {
+	for i := range voiceObjects {
+		var count int64
+		for _, u := range usage {
+			if u.VoiceName == voiceObjects[i].ID {
+				count = u.UsageCount
+				lastUsed := u.LastUsed
+				voiceObjects[i].LastUsed = &lastUsed
+				break
+			}
+		}
+		voiceObjects[i].UsageCount = &count
+	}
+}
+
+func usageCount(v VoiceInfo) int64 {
+	if v.UsageCount == nil {
+		return 0
+	}
+	return *v.UsageCount
 }
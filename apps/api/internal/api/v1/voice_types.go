@@ -1,10 +1,14 @@
 package v1
 
+import "time"
+
 // VoiceInfo represents information about an available voice
 type VoiceInfo struct {
-	ID          string `json:"id"`
-	Name        string `json:"name"`
-	Description string `json:"description"`
+	ID          string     `json:"id"`
+	Name        string     `json:"name"`
+	Description string     `json:"description"`
+	UsageCount  *int64     `json:"usageCount,omitempty"`
+	LastUsed    *time.Time `json:"lastUsed,omitempty"`
 }
 
 // GetVoicesResponse represents the response for getting available voices
@@ -0,0 +1,57 @@
+package auth
+
+import (
+	"context"
+
+	"altread-go/api/internal/constants"
+)
+
+// Authentication methods
+const (
+	MethodAPIKey = "api_key"
+	MethodJWT    = "jwt"
+)
+
+// Principal identifies the authenticated caller of a request
+type Principal struct {
+	Subject  string
+	TenantID string
+	Scopes   []string
+	Method   string
+}
+
+// HasScope reports whether the principal was granted the scope; admin implies every scope
+func (p *Principal) HasScope(scope string) bool {
+	if p == nil {
+		return false
+	}
+	for _, s := range p.Scopes {
+		if s == scope || s == constants.ScopeAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+type principalKey struct{}
+
+// WithPrincipal returns a copy of ctx carrying the principal
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// PrincipalFromContext returns the principal stored in ctx, or nil for anonymous requests
+func PrincipalFromContext(ctx context.Context) *Principal {
+	p, _ := ctx.Value(principalKey{}).(*Principal)
+	return p
+}
+
+// TenantIDFromContext returns the tenant of the principal stored in ctx, or nil if there is none
+func TenantIDFromContext(ctx context.Context) *string {
+	p := PrincipalFromContext(ctx)
+	if p == nil || p.TenantID == "" {
+		return nil
+	}
+	tenantID := p.TenantID
+	return &tenantID
+}
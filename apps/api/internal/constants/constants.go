@@ -22,9 +22,10 @@ var OpenAIVoiceList = []string{
 
 // Validation limits
 const (
-	MaxTextLength     = 4096
-	MaxImageSizeBytes = 20 * 1024 * 1024
-	MaxFileSizeBytes  = 10 * 1024 * 1024
+	MaxTextLength        = 32768
+	MaxSpeechChunkLength = 4096 // characters per TTS provider request
+	MaxImageSizeBytes    = 20 * 1024 * 1024
+	MaxFileSizeBytes     = 10 * 1024 * 1024
 )
 
 // Error codes
@@ -46,6 +47,44 @@ const (
 	ErrCodeInvalidAPIKey        = "INVALID_API_KEY"
 	ErrCodeRateLimitExceeded    = "RATE_LIMIT_EXCEEDED"
 	ErrCodeRateLimitExceededAPI = "RATE_LIMIT_EXCEEDED"
+	ErrCodeUnauthorized         = "UNAUTHORIZED"
+	ErrCodeInsufficientScope    = "INSUFFICIENT_SCOPE"
+	ErrCodeProviderUnavailable  = "PROVIDER_UNAVAILABLE"
+	ErrCodeInvalidEvent         = "INVALID_EVENT"
+)
+
+// API key scopes
+const (
+	ScopeAltTextWrite  = "alt-text:write"
+	ScopeVoiceWrite    = "voice:write"
+	ScopeAnalyticsRead = "analytics:read"
+	ScopeEventsWrite   = "events:write"
+	ScopeAdmin         = "admin"
+)
+
+// APIScopeList contains all scopes that can be granted to an API key
+var APIScopeList = []string{
+	ScopeAltTextWrite,
+	ScopeVoiceWrite,
+	ScopeAnalyticsRead,
+	ScopeEventsWrite,
+	ScopeAdmin,
+}
+
+// Analytics event types recorded by the API
+const (
+	EventImageUploaded    = "image_uploaded"
+	EventAltTextGenerated = "alt_text_generated"
+	EventAltTextFailed    = "alt_text_failed"
+	EventVoicePlayStarted = "voice_play_started"
+	EventVoicePlayFailed  = "voice_play_failed"
+)
+
+// Analytics event types reported by clients
+const (
+	EventAltTextCopied          = "alt_text_copied"
+	EventVoicePlaybackCompleted = "voice_playback_completed"
+	EventVoicePlaybackAborted   = "voice_playback_aborted"
 )
 
 // OpenAI TTS defaults
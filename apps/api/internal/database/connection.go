@@ -2,11 +2,12 @@ package database
 
 import (
 	"fmt"
-	"log"
+	"log/slog"
 	"strings"
 	"time"
 
 	"altread-go/api/internal/config"
+	"altread-go/api/internal/logging"
 	"altread-go/api/internal/models"
 
 	"gorm.io/driver/postgres"
@@ -23,9 +24,9 @@ func Init(cfg *config.Config) error {
 
 	var gormLogger logger.Interface
 	if cfg.Debug {
-		gormLogger = logger.Default.LogMode(logger.Info)
+		gormLogger = logging.NewGormLogger(logger.Info)
 	} else {
-		gormLogger = logger.Default.LogMode(logger.Error)
+		gormLogger = logging.NewGormLogger(logger.Warn)
 	}
 
 	dsn := cfg.GetDSN()
@@ -39,6 +40,10 @@ func Init(cfg *config.Config) error {
 		return fmt.Errorf("failed to connect to database: %w", err)
 	}
 
+	if err := registerTracing(DB); err != nil {
+		return fmt.Errorf("failed to register tracing callbacks: %w", err)
+	}
+
 	sqlDB, err := DB.DB()
 	if err != nil {
 		return fmt.Errorf("failed to get database instance: %w", err)
@@ -52,7 +57,7 @@ func Init(cfg *config.Config) error {
 		return fmt.Errorf("failed to ping database: %w", err)
 	}
 
-	log.Println("Database connection established")
+	slog.Info("Database connection established", logging.ServiceKey, "database")
 	return nil
 }
 
@@ -76,13 +81,13 @@ func AutoMigrate() error {
 		// Check if error is just about table already existing
 		errStr := strings.ToLower(err.Error())
 		if strings.Contains(errStr, "already exists") {
-			log.Println("Database tables already exist, skipping migration")
+			slog.Info("Database tables already exist, skipping migration", logging.ServiceKey, "database")
 			return nil
 		}
 		return fmt.Errorf("failed to auto migrate: %w", err)
 	}
 
-	log.Println("Database migrations completed")
+	slog.Info("Database migrations completed", logging.ServiceKey, "database")
 	return nil
 }
 
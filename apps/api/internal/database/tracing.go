@@ -0,0 +1,71 @@
+package database
+
+import (
+	"context"
+	"errors"
+
+	"altread-go/api/internal/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+const parentContextKey = "tracing:parent_context"
+
+// registerTracing wraps every GORM operation in a client span named after the operation
+func registerTracing(db *gorm.DB) error {
+	cb := db.Callback()
+	return errors.Join(
+		cb.Create().Before("gorm:create").Register("tracing:before_create", startSpan("db.create")),
+		cb.Create().After("gorm:create").Register("tracing:after_create", endSpan),
+		cb.Query().Before("gorm:query").Register("tracing:before_query", startSpan("db.query")),
+		cb.Query().After("gorm:query").Register("tracing:after_query", endSpan),
+		cb.Update().Before("gorm:update").Register("tracing:before_update", startSpan("db.update")),
+		cb.Update().After("gorm:update").Register("tracing:after_update", endSpan),
+		cb.Delete().Before("gorm:delete").Register("tracing:before_delete", startSpan("db.delete")),
+		cb.Delete().After("gorm:delete").Register("tracing:after_delete", endSpan),
+		cb.Row().Before("gorm:row").Register("tracing:before_row", startSpan("db.row")),
+		cb.Row().After("gorm:row").Register("tracing:after_row", endSpan),
+		cb.Raw().Before("gorm:raw").Register("tracing:before_raw", startSpan("db.raw")),
+		cb.Raw().After("gorm:raw").Register("tracing:after_raw", endSpan),
+	)
+}
+
+func startSpan(name string) func(*gorm.DB) {
+	return func(tx *gorm.DB) {
+		parent := tx.Statement.Context
+		if parent == nil {
+			parent = context.Background()
+		}
+
+		ctx, _ := tracing.StartClientSpan(parent, name, attribute.String("db.system", "postgresql"))
+		tx.InstanceSet(parentContextKey, parent)
+		tx.Statement.Context = ctx
+	}
+}
+
+func endSpan(tx *gorm.DB) {
+	parent, ok := tx.InstanceGet(parentContextKey)
+	if !ok {
+		return
+	}
+
+	span := trace.SpanFromContext(tx.Statement.Context)
+	if span.IsRecording() {
+		span.SetAttributes(
+			attribute.String("db.collection.name", tx.Statement.Table),
+			attribute.String("db.query.text", tx.Statement.SQL.String()),
+			attribute.Int64("db.rows_affected", tx.Statement.RowsAffected),
+		)
+	}
+
+	err := tx.Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		err = nil
+	}
+	tracing.EndSpan(span, err)
+
+	// Later operations on the same statement should not be parented on this span
+	tx.Statement.Context = parent.(context.Context)
+}
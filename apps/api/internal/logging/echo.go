@@ -0,0 +1,114 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+
+	"github.com/labstack/gommon/log"
+)
+
+// EchoLogger adapts slog.Default() to echo.Logger so Echo's own messages reach
+// the same handler as the rest of the API. Install it with e.Logger = NewEchoLogger().
+type EchoLogger struct {
+	prefix string
+	level  log.Lvl
+}
+
+// NewEchoLogger creates an Echo logger backed by slog
+func NewEchoLogger() *EchoLogger {
+	return &EchoLogger{prefix: "echo", level: log.INFO}
+}
+
+func (l *EchoLogger) log(level slog.Level, msg string, args ...any) {
+	slog.Log(context.Background(), level, msg, append([]any{ServiceKey, l.prefix}, args...)...)
+}
+
+func jsonArgs(j log.JSON) []any {
+	args := make([]any, 0, len(j)*2)
+	for k, v := range j {
+		args = append(args, k, v)
+	}
+	return args
+}
+
+// Output implements echo.Logger; output is decided by the slog handler
+func (l *EchoLogger) Output() io.Writer { return os.Stdout }
+
+// SetOutput implements echo.Logger and is ignored
+func (l *EchoLogger) SetOutput(w io.Writer) {}
+
+// Prefix implements echo.Logger
+func (l *EchoLogger) Prefix() string { return l.prefix }
+
+// SetPrefix implements echo.Logger; the prefix is logged as the service
+func (l *EchoLogger) SetPrefix(p string) { l.prefix = p }
+
+// Level implements echo.Logger
+func (l *EchoLogger) Level() log.Lvl { return l.level }
+
+// SetLevel implements echo.Logger; filtering is decided by the slog handler
+func (l *EchoLogger) SetLevel(v log.Lvl) { l.level = v }
+
+// SetHeader implements echo.Logger and is ignored
+func (l *EchoLogger) SetHeader(h string) {}
+
+func (l *EchoLogger) Print(i ...interface{}) { l.log(slog.LevelInfo, fmt.Sprint(i...)) }
+func (l *EchoLogger) Printf(format string, args ...interface{}) {
+	l.log(slog.LevelInfo, fmt.Sprintf(format, args...))
+}
+func (l *EchoLogger) Printj(j log.JSON) { l.log(slog.LevelInfo, "", jsonArgs(j)...) }
+
+func (l *EchoLogger) Debug(i ...interface{}) { l.log(slog.LevelDebug, fmt.Sprint(i...)) }
+func (l *EchoLogger) Debugf(format string, args ...interface{}) {
+	l.log(slog.LevelDebug, fmt.Sprintf(format, args...))
+}
+func (l *EchoLogger) Debugj(j log.JSON) { l.log(slog.LevelDebug, "", jsonArgs(j)...) }
+
+func (l *EchoLogger) Info(i ...interface{}) { l.log(slog.LevelInfo, fmt.Sprint(i...)) }
+func (l *EchoLogger) Infof(format string, args ...interface{}) {
+	l.log(slog.LevelInfo, fmt.Sprintf(format, args...))
+}
+func (l *EchoLogger) Infoj(j log.JSON) { l.log(slog.LevelInfo, "", jsonArgs(j)...) }
+
+func (l *EchoLogger) Warn(i ...interface{}) { l.log(slog.LevelWarn, fmt.Sprint(i...)) }
+func (l *EchoLogger) Warnf(format string, args ...interface{}) {
+	l.log(slog.LevelWarn, fmt.Sprintf(format, args...))
+}
+func (l *EchoLogger) Warnj(j log.JSON) { l.log(slog.LevelWarn, "", jsonArgs(j)...) }
+
+func (l *EchoLogger) Error(i ...interface{}) { l.log(slog.LevelError, fmt.Sprint(i...)) }
+func (l *EchoLogger) Errorf(format string, args ...interface{}) {
+	l.log(slog.LevelError, fmt.Sprintf(format, args...))
+}
+func (l *EchoLogger) Errorj(j log.JSON) { l.log(slog.LevelError, "", jsonArgs(j)...) }
+
+func (l *EchoLogger) Fatal(i ...interface{}) {
+	l.log(slog.LevelError, fmt.Sprint(i...))
+	os.Exit(1)
+}
+func (l *EchoLogger) Fatalf(format string, args ...interface{}) {
+	l.log(slog.LevelError, fmt.Sprintf(format, args...))
+	os.Exit(1)
+}
+func (l *EchoLogger) Fatalj(j log.JSON) {
+	l.log(slog.LevelError, "", jsonArgs(j)...)
+	os.Exit(1)
+}
+
+func (l *EchoLogger) Panic(i ...interface{}) {
+	msg := fmt.Sprint(i...)
+	l.log(slog.LevelError, msg)
+	panic(msg)
+}
+func (l *EchoLogger) Panicf(format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	l.log(slog.LevelError, msg)
+	panic(msg)
+}
+func (l *EchoLogger) Panicj(j log.JSON) {
+	l.log(slog.LevelError, "", jsonArgs(j)...)
+	panic(j)
+}
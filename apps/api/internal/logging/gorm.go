@@ -0,0 +1,75 @@
+package logging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+const slowQueryThreshold = 200 * time.Millisecond
+
+// GormLogger routes GORM's logging through slog.Default(). Failed queries are
+// logged as errors, slow queries as warnings and, in Info mode, every query at
+// debug level.
+type GormLogger struct {
+	level gormlogger.LogLevel
+}
+
+// NewGormLogger creates a GORM logger at the given GORM log mode
+func NewGormLogger(level gormlogger.LogLevel) *GormLogger {
+	return &GormLogger{level: level}
+}
+
+// LogMode implements gorm's logger.Interface
+func (g *GormLogger) LogMode(level gormlogger.LogLevel) gormlogger.Interface {
+	return &GormLogger{level: level}
+}
+
+// Info implements gorm's logger.Interface
+func (g *GormLogger) Info(ctx context.Context, msg string, data ...interface{}) {
+	if g.level >= gormlogger.Info {
+		slog.InfoContext(ctx, fmt.Sprintf(msg, data...), ServiceKey, "database")
+	}
+}
+
+// Warn implements gorm's logger.Interface
+func (g *GormLogger) Warn(ctx context.Context, msg string, data ...interface{}) {
+	if g.level >= gormlogger.Warn {
+		slog.WarnContext(ctx, fmt.Sprintf(msg, data...), ServiceKey, "database")
+	}
+}
+
+// Error implements gorm's logger.Interface
+func (g *GormLogger) Error(ctx context.Context, msg string, data ...interface{}) {
+	if g.level >= gormlogger.Error {
+		slog.ErrorContext(ctx, fmt.Sprintf(msg, data...), ServiceKey, "database")
+	}
+}
+
+// Trace implements gorm's logger.Interface
+func (g *GormLogger) Trace(ctx context.Context, begin time.Time, fc func() (sql string, rowsAffected int64), err error) {
+	if g.level <= gormlogger.Silent {
+		return
+	}
+
+	elapsed := time.Since(begin)
+	switch {
+	case err != nil && g.level >= gormlogger.Error && !errors.Is(err, gorm.ErrRecordNotFound):
+		sql, rows := fc()
+		slog.ErrorContext(ctx, "Database query failed", ServiceKey, "database",
+			"error", err, "sql", sql, "rows", rows, "duration_ms", elapsed.Milliseconds())
+	case elapsed > slowQueryThreshold && g.level >= gormlogger.Warn:
+		sql, rows := fc()
+		slog.WarnContext(ctx, "Slow database query", ServiceKey, "database",
+			"sql", sql, "rows", rows, "duration_ms", elapsed.Milliseconds())
+	case g.level >= gormlogger.Info:
+		sql, rows := fc()
+		slog.DebugContext(ctx, "Database query", ServiceKey, "database",
+			"sql", sql, "rows", rows, "duration_ms", elapsed.Milliseconds())
+	}
+}
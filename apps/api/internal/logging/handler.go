@@ -0,0 +1,221 @@
+package logging
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"strings"
+	"time"
+
+	"altread-go/api/internal/auth"
+	"altread-go/api/internal/config"
+	"altread-go/api/internal/tracing"
+)
+
+// ServiceKey is the attribute naming the component that logged a record; it is
+// stored in application_logs.service rather than in the context column
+const ServiceKey = "service"
+
+const defaultService = "api"
+
+// Sink persists log records, e.g. to the application_logs table
+type Sink interface {
+	LogContext(ctx context.Context, level Level, service, message string, fields map[string]interface{})
+}
+
+type skipSinkKey struct{}
+
+// WithoutSink marks ctx so records logged with it only go to stdout. The log
+// writer uses it so failures to store logs cannot feed back into the sink.
+func WithoutSink(ctx context.Context) context.Context {
+	return context.WithValue(ctx, skipSinkKey{}, true)
+}
+
+func sinkSkipped(ctx context.Context) bool {
+	skip, _ := ctx.Value(skipSinkKey{}).(bool)
+	return skip
+}
+
+// Handler is an slog.Handler that writes every record to stdout and records at
+// or above the sink level to a Sink, adding the trace, tenant and route of the
+// request carried by the context
+type Handler struct {
+	stdout    slog.Handler
+	sink      Sink
+	sinkLevel slog.Level
+	attrs     []groupedAttr
+	groups    []string
+}
+
+type groupedAttr struct {
+	prefix string
+	attr   slog.Attr
+}
+
+// NewHandler creates a handler fanning out to stdout and sink; sink may be nil
+func NewHandler(stdout slog.Handler, sink Sink, sinkLevel Level) *Handler {
+	return &Handler{
+		stdout:    stdout,
+		sink:      sink,
+		sinkLevel: sinkLevel.Slog(),
+	}
+}
+
+// New builds a logger writing to w in cfg.LogFormat at cfg.LogLevel and storing
+// records at or above cfg.LogDBLevel in sink
+func New(cfg *config.Config, w io.Writer, sink Sink) *slog.Logger {
+	level, ok := ParseLevel(cfg.LogLevel)
+	if !ok {
+		level = LevelInfo
+	}
+	dbLevel, ok := ParseLevel(cfg.LogDBLevel)
+	if !ok {
+		dbLevel = LevelInfo
+	}
+
+	opts := &slog.HandlerOptions{Level: level.Slog(), ReplaceAttr: replaceLevel}
+	var stdout slog.Handler
+	if cfg.LogFormat == "text" {
+		stdout = slog.NewTextHandler(w, opts)
+	} else {
+		stdout = slog.NewJSONHandler(w, opts)
+	}
+
+	return slog.New(NewHandler(stdout, sink, dbLevel))
+}
+
+// replaceLevel prints levels with the same names used in application_logs
+func replaceLevel(groups []string, a slog.Attr) slog.Attr {
+	if len(groups) == 0 && a.Key == slog.LevelKey {
+		if level, ok := a.Value.Any().(slog.Level); ok {
+			a.Value = slog.StringValue(string(FromSlog(level)))
+		}
+	}
+	return a
+}
+
+// Enabled implements slog.Handler
+func (h *Handler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.stdout.Enabled(ctx, level) || (h.sink != nil && level >= h.sinkLevel)
+}
+
+// Handle implements slog.Handler
+func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
+	var err error
+	if h.stdout.Enabled(ctx, r.Level) {
+		out := r.Clone()
+		out.AddAttrs(contextAttrs(ctx)...)
+		err = h.stdout.Handle(ctx, out)
+	}
+
+	if h.sink != nil && r.Level >= h.sinkLevel && !sinkSkipped(ctx) {
+		service := defaultService
+		fields := make(map[string]interface{})
+		add := func(prefix string, a slog.Attr) {
+			if prefix == "" && a.Key == ServiceKey {
+				service = a.Value.Resolve().String()
+				return
+			}
+			flatten(fields, prefix, a)
+		}
+
+		for _, ga := range h.attrs {
+			add(ga.prefix, ga.attr)
+		}
+		prefix := groupPrefix(h.groups)
+		r.Attrs(func(a slog.Attr) bool {
+			add(prefix, a)
+			return true
+		})
+		if t := tracing.FromContext(ctx); t != nil && t.Route != "" {
+			fields["route"] = t.Route
+		}
+		if len(fields) == 0 {
+			fields = nil
+		}
+
+		h.sink.LogContext(ctx, FromSlog(r.Level), service, r.Message, fields)
+	}
+
+	return err
+}
+
+// WithAttrs implements slog.Handler
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	clone := *h
+	clone.stdout = h.stdout.WithAttrs(attrs)
+	clone.attrs = make([]groupedAttr, len(h.attrs), len(h.attrs)+len(attrs))
+	copy(clone.attrs, h.attrs)
+	prefix := groupPrefix(h.groups)
+	for _, a := range attrs {
+		clone.attrs = append(clone.attrs, groupedAttr{prefix: prefix, attr: a})
+	}
+	return &clone
+}
+
+// WithGroup implements slog.Handler
+func (h *Handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	clone := *h
+	clone.stdout = h.stdout.WithGroup(name)
+	clone.groups = append(append([]string(nil), h.groups...), name)
+	return &clone
+}
+
+// contextAttrs returns the request-scoped attributes carried by ctx
+func contextAttrs(ctx context.Context) []slog.Attr {
+	var attrs []slog.Attr
+	if t := tracing.FromContext(ctx); t != nil {
+		attrs = append(attrs, slog.String("trace_id", t.TraceID))
+		if t.RequestID != t.TraceID {
+			attrs = append(attrs, slog.String("request_id", t.RequestID))
+		}
+		if t.Route != "" {
+			attrs = append(attrs, slog.String("route", t.Route))
+		}
+	}
+	if tenantID := auth.TenantIDFromContext(ctx); tenantID != nil {
+		attrs = append(attrs, slog.String("tenant_id", *tenantID))
+	}
+	return attrs
+}
+
+func groupPrefix(groups []string) string {
+	if len(groups) == 0 {
+		return ""
+	}
+	return strings.Join(groups, ".") + "."
+}
+
+// flatten stores a in fields under a dotted key, expanding groups
+func flatten(fields map[string]interface{}, prefix string, a slog.Attr) {
+	v := a.Value.Resolve()
+	if v.Kind() == slog.KindGroup {
+		groupPrefix := prefix
+		if a.Key != "" {
+			groupPrefix = prefix + a.Key + "."
+		}
+		for _, ga := range v.Group() {
+			flatten(fields, groupPrefix, ga)
+		}
+		return
+	}
+	if a.Key == "" {
+		return
+	}
+
+	switch v.Kind() {
+	case slog.KindDuration:
+		fields[prefix+a.Key] = v.Duration().String()
+	case slog.KindTime:
+		fields[prefix+a.Key] = v.Time().Format(time.RFC3339Nano)
+	default:
+		if err, ok := v.Any().(error); ok {
+			fields[prefix+a.Key] = err.Error()
+		} else {
+			fields[prefix+a.Key] = v.Any()
+		}
+	}
+}
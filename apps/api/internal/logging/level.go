@@ -0,0 +1,71 @@
+package logging
+
+import (
+	"log/slog"
+	"strings"
+)
+
+// Level is a log level as stored in application_logs.level
+type Level string
+
+// Log levels, from least to most severe
+const (
+	LevelDebug   Level = "debug"
+	LevelInfo    Level = "info"
+	LevelWarning Level = "warning"
+	LevelError   Level = "error"
+)
+
+// ParseLevel accepts a level name or common alias such as "warn" or "err"
+func ParseLevel(s string) (Level, bool) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "debug":
+		return LevelDebug, true
+	case "info":
+		return LevelInfo, true
+	case "warning", "warn":
+		return LevelWarning, true
+	case "error", "err":
+		return LevelError, true
+	default:
+		return "", false
+	}
+}
+
+// Valid reports whether l is one of the defined levels
+func (l Level) Valid() bool {
+	switch l {
+	case LevelDebug, LevelInfo, LevelWarning, LevelError:
+		return true
+	default:
+		return false
+	}
+}
+
+// Slog returns the equivalent slog level
+func (l Level) Slog() slog.Level {
+	switch l {
+	case LevelDebug:
+		return slog.LevelDebug
+	case LevelWarning:
+		return slog.LevelWarn
+	case LevelError:
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
+
+// FromSlog maps an slog level onto the nearest Level at or below it
+func FromSlog(level slog.Level) Level {
+	switch {
+	case level >= slog.LevelError:
+		return LevelError
+	case level >= slog.LevelWarn:
+		return LevelWarning
+	case level >= slog.LevelInfo:
+		return LevelInfo
+	default:
+		return LevelDebug
+	}
+}
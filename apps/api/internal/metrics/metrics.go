@@ -0,0 +1,92 @@
+package metrics
+
+import (
+	"net/http"
+	"runtime"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Default is the registry served on /metrics
+var Default = NewRegistry()
+
+// HTTP server metrics
+var (
+	HTTPRequests = Default.NewCounterVec(
+		"altread_http_requests_total",
+		"Total HTTP requests by method, route template and status class.",
+		"method", "route", "status",
+	)
+	HTTPRequestDuration = Default.NewHistogramVec(
+		"altread_http_request_duration_seconds",
+		"HTTP request latency by method, route template and status class.",
+		DefaultBuckets,
+		"method", "route", "status",
+	)
+	HTTPRequestsInFlight = Default.NewGaugeVec(
+		"altread_http_requests_in_flight",
+		"HTTP requests currently being served.",
+	)
+)
+
+// Sliding-window latency covering the last hour in one-minute slots
+var (
+	EndpointLatency = NewWindowedLatency(time.Minute, 60, "method", "route")
+	ProviderLatency = NewWindowedLatency(time.Minute, 60, "operation", "model")
+)
+
+// Domain metrics
+var (
+	CacheRequests = Default.NewCounterVec(
+		"altread_cache_requests_total",
+		"Alt text cache lookups by result (hit, miss, error).",
+		"result",
+	)
+	AnalyticsCacheRequests = Default.NewCounterVec(
+		"altread_analytics_cache_requests_total",
+		"Analytics cache lookups by result (hit, miss, error).",
+		"result",
+	)
+	ProviderRequestDuration = Default.NewHistogramVec(
+		"altread_provider_request_duration_seconds",
+		"Latency of calls to the AI provider by operation, model and outcome.",
+		DefaultBuckets,
+		"provider", "operation", "model", "outcome",
+	)
+	TTSCharacters = Default.NewCounterVec(
+		"altread_tts_characters_total",
+		"Characters of text sent for speech synthesis by model and outcome; only successes are billed.",
+		"model", "outcome",
+	)
+	LogEntriesDropped = Default.NewCounterVec(
+		"altread_log_entries_dropped_total",
+		"Log entries dropped by reason (queue_full, write_failed).",
+		"reason",
+	)
+	LogEntriesSpilled = Default.NewCounterVec(
+		"altread_log_entries_spilled_total",
+		"Log entries written to stderr because the log queue was full.",
+	)
+	LogWriteRetries = Default.NewCounterVec(
+		"altread_log_write_retries_total",
+		"Retries of log batch inserts after transient database errors.",
+	)
+)
+
+func init() {
+	Default.NewGaugeFunc(
+		"altread_goroutines",
+		"Number of goroutines that currently exist.",
+		func() float64 { return float64(runtime.NumGoroutine()) },
+	)
+}
+
+// Handler serves the default registry in the Prometheus text exposition format
+func Handler() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		c.Response().Header().Set(echo.HeaderContentType, "text/plain; version=0.0.4; charset=utf-8")
+		c.Response().WriteHeader(http.StatusOK)
+		return Default.WriteText(c.Response())
+	}
+}
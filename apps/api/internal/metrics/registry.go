@@ -0,0 +1,413 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultBuckets are latency histogram bounds in seconds
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+
+// Registry holds metrics and renders them in the Prometheus text exposition format
+type Registry struct {
+	mu      sync.Mutex
+	metrics []metric
+	names   map[string]bool
+}
+
+type metric interface {
+	name() string
+	write(w *bufio.Writer)
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{names: make(map[string]bool)}
+}
+
+func (r *Registry) register(m metric) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.names[m.name()] {
+		panic(fmt.Sprintf("metrics: duplicate metric %q", m.name()))
+	}
+	r.names[m.name()] = true
+	r.metrics = append(r.metrics, m)
+}
+
+// WriteText writes all metrics in the Prometheus text exposition format (version 0.0.4)
+func (r *Registry) WriteText(w io.Writer) error {
+	r.mu.Lock()
+	metrics := make([]metric, len(r.metrics))
+	copy(metrics, r.metrics)
+	r.mu.Unlock()
+
+	sort.Slice(metrics, func(i, j int) bool { return metrics[i].name() < metrics[j].name() })
+
+	bw := bufio.NewWriter(w)
+	for _, m := range metrics {
+		m.write(bw)
+	}
+	return bw.Flush()
+}
+
+// desc holds the identity shared by every metric type
+type desc struct {
+	metricName string
+	help       string
+	labelNames []string
+}
+
+func (d *desc) name() string {
+	return d.metricName
+}
+
+func (d *desc) writeHeader(w *bufio.Writer, typ string) {
+	fmt.Fprintf(w, "# HELP %s %s\n", d.metricName, escapeHelp(d.help))
+	fmt.Fprintf(w, "# TYPE %s %s\n", d.metricName, typ)
+}
+
+func (d *desc) key(labelValues []string) string {
+	if len(labelValues) != len(d.labelNames) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", d.metricName, len(d.labelNames), len(labelValues)))
+	}
+	return strings.Join(labelValues, "\xff")
+}
+
+// CounterVec is a monotonically increasing value partitioned by labels
+type CounterVec struct {
+	desc
+	mu       sync.Mutex
+	children map[string]*Counter
+}
+
+// Counter is a single labelled counter series
+type Counter struct {
+	labelValues []string
+	mu          sync.Mutex
+	value       float64
+}
+
+// NewCounterVec registers a counter with the given label names
+func (r *Registry) NewCounterVec(name, help string, labelNames ...string) *CounterVec {
+	v := &CounterVec{
+		desc:     desc{metricName: name, help: help, labelNames: labelNames},
+		children: make(map[string]*Counter),
+	}
+	r.register(v)
+	return v
+}
+
+// WithLabelValues returns the series for the label values, creating it if needed
+func (v *CounterVec) WithLabelValues(labelValues ...string) *Counter {
+	key := v.key(labelValues)
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	c, ok := v.children[key]
+	if !ok {
+		c = &Counter{labelValues: append([]string(nil), labelValues...)}
+		v.children[key] = c
+	}
+	return c
+}
+
+// Inc adds one to the counter
+func (c *Counter) Inc() {
+	c.Add(1)
+}
+
+// Add adds a non-negative delta to the counter
+func (c *Counter) Add(delta float64) {
+	if delta < 0 {
+		return
+	}
+	c.mu.Lock()
+	c.value += delta
+	c.mu.Unlock()
+}
+
+// Value returns the current counter value
+func (c *Counter) Value() float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.value
+}
+
+func (v *CounterVec) write(w *bufio.Writer) {
+	v.writeHeader(w, "counter")
+	for _, c := range v.sortedChildren() {
+		writeSample(w, v.metricName, v.labelNames, c.labelValues, "", "", c.Value())
+	}
+}
+
+func (v *CounterVec) sortedChildren() []*Counter {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	children := make([]*Counter, 0, len(v.children))
+	for _, c := range v.children {
+		children = append(children, c)
+	}
+	sort.Slice(children, func(i, j int) bool {
+		return strings.Join(children[i].labelValues, "\xff") < strings.Join(children[j].labelValues, "\xff")
+	})
+	return children
+}
+
+// GaugeVec is a value that can go up and down, partitioned by labels
+type GaugeVec struct {
+	desc
+	mu       sync.Mutex
+	children map[string]*Gauge
+}
+
+// Gauge is a single labelled gauge series
+type Gauge struct {
+	labelValues []string
+	mu          sync.Mutex
+	value       float64
+}
+
+// NewGaugeVec registers a gauge with the given label names
+func (r *Registry) NewGaugeVec(name, help string, labelNames ...string) *GaugeVec {
+	v := &GaugeVec{
+		desc:     desc{metricName: name, help: help, labelNames: labelNames},
+		children: make(map[string]*Gauge),
+	}
+	r.register(v)
+	return v
+}
+
+// WithLabelValues returns the series for the label values, creating it if needed
+func (v *GaugeVec) WithLabelValues(labelValues ...string) *Gauge {
+	key := v.key(labelValues)
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	g, ok := v.children[key]
+	if !ok {
+		g = &Gauge{labelValues: append([]string(nil), labelValues...)}
+		v.children[key] = g
+	}
+	return g
+}
+
+// Set sets the gauge to value
+func (g *Gauge) Set(value float64) {
+	g.mu.Lock()
+	g.value = value
+	g.mu.Unlock()
+}
+
+// Add adds delta, which may be negative, to the gauge
+func (g *Gauge) Add(delta float64) {
+	g.mu.Lock()
+	g.value += delta
+	g.mu.Unlock()
+}
+
+// Inc adds one to the gauge
+func (g *Gauge) Inc() {
+	g.Add(1)
+}
+
+// Dec subtracts one from the gauge
+func (g *Gauge) Dec() {
+	g.Add(-1)
+}
+
+// Value returns the current gauge value
+func (g *Gauge) Value() float64 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.value
+}
+
+func (v *GaugeVec) write(w *bufio.Writer) {
+	v.writeHeader(w, "gauge")
+
+	v.mu.Lock()
+	children := make([]*Gauge, 0, len(v.children))
+	for _, g := range v.children {
+		children = append(children, g)
+	}
+	v.mu.Unlock()
+
+	sort.Slice(children, func(i, j int) bool {
+		return strings.Join(children[i].labelValues, "\xff") < strings.Join(children[j].labelValues, "\xff")
+	})
+	for _, g := range children {
+		writeSample(w, v.metricName, v.labelNames, g.labelValues, "", "", g.Value())
+	}
+}
+
+// GaugeFunc is an unlabelled gauge whose value is read at scrape time
+type GaugeFunc struct {
+	desc
+	fn func() float64
+}
+
+// NewGaugeFunc registers a gauge backed by fn
+func (r *Registry) NewGaugeFunc(name, help string, fn func() float64) *GaugeFunc {
+	g := &GaugeFunc{
+		desc: desc{metricName: name, help: help},
+		fn:   fn,
+	}
+	r.register(g)
+	return g
+}
+
+func (g *GaugeFunc) write(w *bufio.Writer) {
+	g.writeHeader(w, "gauge")
+	writeSample(w, g.metricName, nil, nil, "", "", g.fn())
+}
+
+// HistogramVec counts observations into cumulative buckets, partitioned by labels
+type HistogramVec struct {
+	desc
+	buckets  []float64
+	mu       sync.Mutex
+	children map[string]*Histogram
+}
+
+// Histogram is a single labelled histogram series
+type Histogram struct {
+	labelValues []string
+	buckets     []float64
+	mu          sync.Mutex
+	counts      []uint64
+	sum         float64
+	count       uint64
+}
+
+// NewHistogramVec registers a histogram with the given upper bounds and label names
+func (r *Registry) NewHistogramVec(name, help string, buckets []float64, labelNames ...string) *HistogramVec {
+	sorted := append([]float64(nil), buckets...)
+	sort.Float64s(sorted)
+
+	v := &HistogramVec{
+		desc:     desc{metricName: name, help: help, labelNames: labelNames},
+		buckets:  sorted,
+		children: make(map[string]*Histogram),
+	}
+	r.register(v)
+	return v
+}
+
+// WithLabelValues returns the series for the label values, creating it if needed
+func (v *HistogramVec) WithLabelValues(labelValues ...string) *Histogram {
+	key := v.key(labelValues)
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	h, ok := v.children[key]
+	if !ok {
+		h = &Histogram{
+			labelValues: append([]string(nil), labelValues...),
+			buckets:     v.buckets,
+			counts:      make([]uint64, len(v.buckets)),
+		}
+		v.children[key] = h
+	}
+	return h
+}
+
+// Observe records a single value
+func (h *Histogram) Observe(value float64) {
+	idx := sort.SearchFloat64s(h.buckets, value)
+
+	h.mu.Lock()
+	if idx < len(h.counts) {
+		h.counts[idx]++
+	}
+	h.sum += value
+	h.count++
+	h.mu.Unlock()
+}
+
+func (v *HistogramVec) write(w *bufio.Writer) {
+	v.writeHeader(w, "histogram")
+
+	v.mu.Lock()
+	children := make([]*Histogram, 0, len(v.children))
+	for _, h := range v.children {
+		children = append(children, h)
+	}
+	v.mu.Unlock()
+
+	sort.Slice(children, func(i, j int) bool {
+		return strings.Join(children[i].labelValues, "\xff") < strings.Join(children[j].labelValues, "\xff")
+	})
+
+	for _, h := range children {
+		h.mu.Lock()
+		counts := append([]uint64(nil), h.counts...)
+		sum, count := h.sum, h.count
+		h.mu.Unlock()
+
+		var cumulative uint64
+		for i, upper := range h.buckets {
+			cumulative += counts[i]
+			writeSample(w, v.metricName+"_bucket", v.labelNames, h.labelValues, "le", formatFloat(upper), float64(cumulative))
+		}
+		writeSample(w, v.metricName+"_bucket", v.labelNames, h.labelValues, "le", "+Inf", float64(count))
+		writeSample(w, v.metricName+"_sum", v.labelNames, h.labelValues, "", "", sum)
+		writeSample(w, v.metricName+"_count", v.labelNames, h.labelValues, "", "", float64(count))
+	}
+}
+
+func writeSample(w *bufio.Writer, name string, labelNames, labelValues []string, extraName, extraValue string, value float64) {
+	w.WriteString(name)
+	if len(labelNames) > 0 || extraName != "" {
+		w.WriteByte('{')
+		for i, label := range labelNames {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			fmt.Fprintf(w, "%s=\"%s\"", label, escapeLabelValue(labelValues[i]))
+		}
+		if extraName != "" {
+			if len(labelNames) > 0 {
+				w.WriteByte(',')
+			}
+			fmt.Fprintf(w, "%s=\"%s\"", extraName, extraValue)
+		}
+		w.WriteByte('}')
+	}
+	w.WriteByte(' ')
+	w.WriteString(formatFloat(value))
+	w.WriteByte('\n')
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var labelValueReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+var helpReplacer = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+
+func escapeLabelValue(s string) string {
+	return labelValueReplacer.Replace(s)
+}
+
+func escapeHelp(s string) string {
+	return helpReplacer.Replace(s)
+}
@@ -0,0 +1,265 @@
+package metrics
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Latency bucket layout for windowed quantiles: log-spaced bounds growing by
+// 5% from 0.1ms, which keeps quantile estimates within about 2.5% of the true
+// value and lets sub-windows be merged by adding counts.
+const (
+	latencyMinMs  = 0.1
+	latencyGrowth = 1.05
+	// LatencyBuckets is the number of buckets; the last covers ~85s and slower requests
+	LatencyBuckets = 280
+)
+
+var logLatencyGrowth = math.Log(latencyGrowth)
+
+// WindowedLatency tracks request counts, errors and latency quantiles per label
+// set over a sliding window made of fixed-length slots.
+type WindowedLatency struct {
+	labelNames []string
+	slot       time.Duration
+	slots      int
+
+	mu     sync.Mutex
+	series map[string]*windowSeries
+}
+
+type windowSeries struct {
+	labelValues []string
+	ring        []latencySlot
+}
+
+type latencySlot struct {
+	index  int64
+	counts []uint32
+	count  uint64
+	errors uint64
+	sumMs  float64
+}
+
+// WindowSnapshot summarises one label set over a window
+type WindowSnapshot struct {
+	Labels            map[string]string `json:"labels"`
+	Requests          uint64            `json:"requests"`
+	Errors            uint64            `json:"errors"`
+	ErrorRate         float64           `json:"errorRate"`
+	RequestsPerMinute float64           `json:"requestsPerMinute"`
+	AvgMs             float64           `json:"avgMs"`
+	P50Ms             float64           `json:"p50Ms"`
+	P90Ms             float64           `json:"p90Ms"`
+	P99Ms             float64           `json:"p99Ms"`
+}
+
+// NewWindowedLatency creates a tracker keeping slots slots of the given length
+func NewWindowedLatency(slot time.Duration, slots int, labelNames ...string) *WindowedLatency {
+	return &WindowedLatency{
+		labelNames: labelNames,
+		slot:       slot,
+		slots:      slots,
+		series:     make(map[string]*windowSeries),
+	}
+}
+
+// MaxWindow is the longest window the tracker can report on
+func (w *WindowedLatency) MaxWindow() time.Duration {
+	return w.slot * time.Duration(w.slots)
+}
+
+// CurrentSlot returns the index of the slot being filled now, to start
+// SnapshotSince from
+func (w *WindowedLatency) CurrentSlot() int64 {
+	return time.Now().UnixNano() / int64(w.slot)
+}
+
+// Observe records one request for the label values
+func (w *WindowedLatency) Observe(d time.Duration, isError bool, labelValues ...string) {
+	if len(labelValues) != len(w.labelNames) {
+		return
+	}
+
+	ms := float64(d) / float64(time.Millisecond)
+	idx := time.Now().UnixNano() / int64(w.slot)
+	key := strings.Join(labelValues, "\xff")
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	s, ok := w.series[key]
+	if !ok {
+		s = &windowSeries{
+			labelValues: append([]string(nil), labelValues...),
+			ring:        make([]latencySlot, w.slots),
+		}
+		w.series[key] = s
+	}
+
+	slot := &s.ring[idx%int64(w.slots)]
+	if slot.index != idx {
+		if slot.counts == nil {
+			slot.counts = make([]uint32, LatencyBuckets)
+		} else {
+			clear(slot.counts)
+		}
+		slot.index = idx
+		slot.count, slot.errors, slot.sumMs = 0, 0, 0
+	}
+
+	slot.counts[latencyBucket(ms)]++
+	slot.count++
+	slot.sumMs += ms
+	if isError {
+		slot.errors++
+	}
+}
+
+// Snapshot merges the slots covering the last window for every label set,
+// dropping series with no requests in the window
+func (w *WindowedLatency) Snapshot(window time.Duration) []WindowSnapshot {
+	slotsInWindow := int64(window / w.slot)
+	if slotsInWindow < 1 {
+		slotsInWindow = 1
+	}
+	if slotsInWindow > int64(w.slots) {
+		slotsInWindow = int64(w.slots)
+	}
+
+	current := time.Now().UnixNano() / int64(w.slot)
+	return w.merge(current-slotsInWindow+1, current, current)
+}
+
+// SnapshotSince merges the completed slots from slot index since onwards, as
+// far back as the ring reaches, and returns the index to pass next time so
+// every completed slot is reported exactly once. The current, partial slot
+// is left for the next call.
+func (w *WindowedLatency) SnapshotSince(since int64) ([]WindowSnapshot, int64) {
+	current := time.Now().UnixNano() / int64(w.slot)
+	oldest := max(since, current-int64(w.slots)+1)
+	if oldest > current-1 {
+		return nil, max(since, current)
+	}
+	return w.merge(oldest, current-1, current), current
+}
+
+// merge summarises the slots with indexes in [oldest, newest] for every label set
+func (w *WindowedLatency) merge(oldest, newest, current int64) []WindowSnapshot {
+	slotsInWindow := newest - oldest + 1
+	merged := make([]uint64, LatencyBuckets)
+	var snapshots []WindowSnapshot
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for key, s := range w.series {
+		clear(merged)
+		var count, errors uint64
+		var sumMs float64
+		latest := int64(math.MinInt64)
+
+		for i := range s.ring {
+			slot := &s.ring[i]
+			if slot.index > latest {
+				latest = slot.index
+			}
+			if slot.count == 0 || slot.index < oldest || slot.index > newest {
+				continue
+			}
+			for b, c := range slot.counts {
+				merged[b] += uint64(c)
+			}
+			count += slot.count
+			errors += slot.errors
+			sumMs += slot.sumMs
+		}
+
+		// Series idle for longer than the whole ring are forgotten
+		if latest < current-int64(w.slots) {
+			delete(w.series, key)
+			continue
+		}
+		if count == 0 {
+			continue
+		}
+
+		labels := make(map[string]string, len(w.labelNames))
+		for i, name := range w.labelNames {
+			labels[name] = s.labelValues[i]
+		}
+
+		snapshots = append(snapshots, WindowSnapshot{
+			Labels:            labels,
+			Requests:          count,
+			Errors:            errors,
+			ErrorRate:         float64(errors) / float64(count) * 100,
+			RequestsPerMinute: float64(count) / (float64(slotsInWindow) * w.slot.Minutes()),
+			AvgMs:             sumMs / float64(count),
+			P50Ms:             quantile(merged, count, 0.50),
+			P90Ms:             quantile(merged, count, 0.90),
+			P99Ms:             quantile(merged, count, 0.99),
+		})
+	}
+
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].Requests > snapshots[j].Requests
+	})
+
+	return snapshots
+}
+
+// LatencyBucketSQL returns a SQL expression placing the milliseconds in column
+// into the same buckets as WindowedLatency, for histograms kept in the database
+func LatencyBucketSQL(column string) string {
+	return fmt.Sprintf("LEAST(CEIL(LN(GREATEST(%s, %g) / %g) / LN(%g)), %d)::int",
+		column, latencyMinMs, latencyMinMs, latencyGrowth, LatencyBuckets-1)
+}
+
+// LatencyQuantile returns the q-th quantile of a histogram of LatencyBuckets counts
+func LatencyQuantile(counts []uint64, q float64) float64 {
+	var total uint64
+	for _, c := range counts {
+		total += c
+	}
+	if total == 0 {
+		return 0
+	}
+	return quantile(counts, total, q)
+}
+
+func latencyBucket(ms float64) int {
+	if ms <= latencyMinMs {
+		return 0
+	}
+	b := int(math.Ceil(math.Log(ms/latencyMinMs) / logLatencyGrowth))
+	if b >= LatencyBuckets {
+		return LatencyBuckets - 1
+	}
+	return b
+}
+
+// quantile returns the geometric midpoint of the bucket holding the q-th observation
+func quantile(counts []uint64, total uint64, q float64) float64 {
+	rank := uint64(math.Ceil(q * float64(total)))
+	if rank == 0 {
+		rank = 1
+	}
+
+	var cumulative uint64
+	for b, c := range counts {
+		cumulative += c
+		if cumulative >= rank {
+			if b == 0 {
+				return latencyMinMs
+			}
+			upper := latencyMinMs * math.Pow(latencyGrowth, float64(b))
+			return math.Round(upper/math.Sqrt(latencyGrowth)*100) / 100
+		}
+	}
+	return 0
+}
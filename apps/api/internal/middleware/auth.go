@@ -0,0 +1,133 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"altread-go/api/internal/api/apierror"
+	"altread-go/api/internal/auth"
+	"altread-go/api/internal/constants"
+	"altread-go/api/internal/services"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Authenticator resolves request credentials to a principal and enforces scopes.
+// API keys serve machine clients; JWT bearer tokens serve dashboard users.
+type Authenticator struct {
+	apiKeys  *services.APIKeyService
+	jwt      *services.JWTVerifier
+	required bool
+}
+
+// NewAuthenticator creates an authenticator; jwt may be nil when no JWKS is configured
+func NewAuthenticator(apiKeys *services.APIKeyService, jwt *services.JWTVerifier, required bool) *Authenticator {
+	return &Authenticator{
+		apiKeys:  apiKeys,
+		jwt:      jwt,
+		required: required,
+	}
+}
+
+// Middleware authenticates the request and attaches the principal to its context.
+// Anonymous requests are rejected only when authentication is required.
+func (a *Authenticator) Middleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			credential := extractCredential(c.Request())
+			if credential == "" {
+				if a.required {
+					return unauthorized(c, "Missing credentials")
+				}
+				return next(c)
+			}
+
+			ctx := c.Request().Context()
+			principal, err := a.authenticate(ctx, credential)
+			if errors.Is(err, services.ErrInvalidAPIKey) || errors.Is(err, services.ErrInvalidToken) {
+				return unauthorized(c, "Invalid credentials")
+			}
+			if err != nil {
+				return apierror.JSON(c, http.StatusInternalServerError, map[string]interface{}{
+					"success": false,
+					"error":   "Failed to verify credentials",
+					"code":    constants.ErrCodeInternalError,
+				})
+			}
+
+			c.SetRequest(c.Request().WithContext(auth.WithPrincipal(ctx, principal)))
+
+			return next(c)
+		}
+	}
+}
+
+// RequireScope rejects authenticated callers that were not granted the scope
+func (a *Authenticator) RequireScope(scope string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			principal := auth.PrincipalFromContext(c.Request().Context())
+			if principal == nil {
+				if a.required {
+					return unauthorized(c, "Authentication required")
+				}
+				return next(c)
+			}
+
+			if !principal.HasScope(scope) {
+				return apierror.JSON(c, http.StatusForbidden, map[string]interface{}{
+					"success": false,
+					"error":   "Missing required scope: " + scope,
+					"code":    constants.ErrCodeInsufficientScope,
+				})
+			}
+
+			return next(c)
+		}
+	}
+}
+
+func (a *Authenticator) authenticate(ctx context.Context, credential string) (*auth.Principal, error) {
+	if services.IsAPIKey(credential) {
+		key, err := a.apiKeys.Authenticate(ctx, credential)
+		if err != nil {
+			return nil, err
+		}
+		return &auth.Principal{
+			Subject:  key.ID.String(),
+			TenantID: key.TenantID,
+			Scopes:   key.ScopeList(),
+			Method:   auth.MethodAPIKey,
+		}, nil
+	}
+
+	if a.jwt != nil && services.IsJWT(credential) {
+		return a.jwt.Verify(ctx, credential)
+	}
+
+	return nil, services.ErrInvalidToken
+}
+
+func extractCredential(r *http.Request) string {
+	if key := strings.TrimSpace(r.Header.Get("X-API-Key")); key != "" {
+		return key
+	}
+
+	authHeader := r.Header.Get(echo.HeaderAuthorization)
+	if scheme, credential, ok := strings.Cut(authHeader, " "); ok && strings.EqualFold(scheme, "Bearer") {
+		return strings.TrimSpace(credential)
+	}
+
+	return ""
+}
+
+func unauthorized(c echo.Context, message string) error {
+	c.Response().Header().Set(echo.HeaderWWWAuthenticate, `Bearer realm="altread"`)
+	return apierror.JSON(c, http.StatusUnauthorized, map[string]interface{}{
+		"success": false,
+		"error":   message,
+		"code":    constants.ErrCodeUnauthorized,
+	})
+}
@@ -1,8 +1,12 @@
 package middleware
 
 import (
+	"log/slog"
 	"net/http"
 
+	"altread-go/api/internal/api/apierror"
+	"altread-go/api/internal/logging"
+
 	"github.com/labstack/echo/v4"
 )
 
@@ -55,8 +59,8 @@ func ErrorHandler(err error, c echo.Context) {
 			}
 		}
 
-		if err := c.JSON(code, response); err != nil {
-			c.Logger().Error(err)
+		if err := apierror.JSON(c, code, response); err != nil {
+			slog.ErrorContext(c.Request().Context(), "Failed to write error response", logging.ServiceKey, "api", "error", err)
 		}
 	}
 }
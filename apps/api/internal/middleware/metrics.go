@@ -1,86 +1,43 @@
 package middleware
 
 import (
-	"sync"
+	"strconv"
 	"time"
 
+	"altread-go/api/internal/metrics"
+
 	"github.com/labstack/echo/v4"
 )
 
-type RequestMetric struct {
-	Path         string    `json:"path"`
-	Method       string    `json:"method"`
-	StatusCode   int       `json:"status_code"`
-	ResponseTime float64   `json:"response_time_ms"`
-	IsError      bool      `json:"is_error"`
-	Timestamp    time.Time `json:"timestamp"`
-}
-
-type MetricsTracker struct {
-	metrics map[string][]RequestMetric
-	mu      sync.RWMutex
-	maxSize int
-}
-
-func NewMetricsTracker(maxSize int) *MetricsTracker {
-	return &MetricsTracker{
-		metrics: make(map[string][]RequestMetric),
-		maxSize: maxSize,
-	}
-}
-
-func (mt *MetricsTracker) Track(path, method string, statusCode int, responseTime time.Duration) {
-	mt.mu.Lock()
-	defer mt.mu.Unlock()
-
-	endpointKey := method + ":" + path
-	metric := RequestMetric{
-		Path:         path,
-		Method:       method,
-		StatusCode:   statusCode,
-		ResponseTime: float64(responseTime.Nanoseconds()) / 1e6, // Convert to milliseconds
-		IsError:      statusCode >= 400,
-		Timestamp:    time.Now(),
-	}
-
-	metrics := mt.metrics[endpointKey]
-	if len(metrics) >= mt.maxSize {
-		metrics = metrics[1:]
-	}
-	metrics = append(metrics, metric)
-	mt.metrics[endpointKey] = metrics
-}
-
-func (mt *MetricsTracker) GetRecentMetrics(endpointKey string, limit int) []RequestMetric {
-	mt.mu.RLock()
-	defer mt.mu.RUnlock()
-
-	metrics := mt.metrics[endpointKey]
-	if len(metrics) > limit {
-		return metrics[len(metrics)-limit:]
-	}
-	return metrics
+// unmeteredPaths are left out of request metrics: health probes, the Prometheus
+// scrape, and streams that stay open for minutes (log tail, analytics stream,
+// analytics export)
+var unmeteredPaths = map[string]struct{}{
+	"/health":                  {},
+	"/livez":                   {},
+	"/readyz":                  {},
+	"/metrics":                 {},
+	"/api/v1/admin/logs/tail":  {},
+	"/api/v1/analytics/stream": {},
+	"/api/v1/analytics/export": {},
 }
 
-func (mt *MetricsTracker) GetAllMetrics() map[string][]RequestMetric {
-	mt.mu.RLock()
-	defer mt.mu.RUnlock()
-
-	result := make(map[string][]RequestMetric)
-	for k, v := range mt.metrics {
-		result[k] = v
-	}
-	return result
-}
+// MetricsMiddleware records request counts, latency and in-flight requests, and
+// feeds the sliding-window percentiles served on /api/v1/system/metrics.
+// Requests are labelled by route template so path parameters do not create new series.
+func MetricsMiddleware() echo.MiddlewareFunc {
+	inFlight := metrics.HTTPRequestsInFlight.WithLabelValues()
 
-func MetricsMiddleware(tracker *MetricsTracker) echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
-			path := c.Request().URL.Path
-			if path == "/health" {
+			// Probes and scrapes would skew the latency data, as would long-lived streams
+			if _, ok := unmeteredPaths[c.Request().URL.Path]; ok {
 				return next(c)
 			}
 
+			inFlight.Inc()
+			defer inFlight.Dec()
+
 			start := time.Now()
 			err := next(c)
 			responseTime := time.Since(start)
@@ -89,14 +46,29 @@ func MetricsMiddleware(tracker *MetricsTracker) echo.MiddlewareFunc {
 			if err != nil {
 				if httpErr, ok := err.(*echo.HTTPError); ok {
 					statusCode = httpErr.Code
+				} else if customErr, ok := err.(*CustomHTTPError); ok {
+					statusCode = customErr.Code
 				} else {
 					statusCode = 500
 				}
 			}
 
-			tracker.Track(path, c.Request().Method, statusCode, responseTime)
+			route := c.Path()
+			if route == "" {
+				route = "unmatched"
+			}
+			method := c.Request().Method
+			status := statusClass(statusCode)
+
+			metrics.HTTPRequests.WithLabelValues(method, route, status).Inc()
+			metrics.HTTPRequestDuration.WithLabelValues(method, route, status).Observe(responseTime.Seconds())
+			metrics.EndpointLatency.Observe(responseTime, statusCode >= 400, method, route)
 
 			return err
 		}
 	}
 }
+
+func statusClass(statusCode int) string {
+	return strconv.Itoa(statusCode/100) + "xx"
+}
@@ -1,25 +1,115 @@
 package middleware
 
 import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
 	"net/http"
+	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"altread-go/api/internal/api/apierror"
+	"altread-go/api/internal/auth"
+	"altread-go/api/internal/config"
+	"altread-go/api/internal/constants"
+	"altread-go/api/internal/logging"
+
 	"github.com/labstack/echo/v4"
+	"github.com/redis/go-redis/v9"
+)
+
+// Rate limit route groups; each group has its own bucket per client
+const (
+	RateLimitGroupAltText   = "alt-text"
+	RateLimitGroupVoice     = "voice"
+	RateLimitGroupAnalytics = "analytics"
 )
 
+// tokenBucketScript atomically refills and takes a token from the bucket at KEYS[1].
+// It uses the Redis server clock so replicas agree on elapsed time.
+// Returns {allowed, remaining, retry_after_ms, reset_after_ms}.
+var tokenBucketScript = redis.NewScript(`
+local capacity = tonumber(ARGV[1])
+local refill_per_ms = tonumber(ARGV[2])
+
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+
+local bucket = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(bucket[1])
+local ts = tonumber(bucket[2])
+if tokens == nil or ts == nil then
+  tokens = capacity
+  ts = now
+end
+
+tokens = math.min(capacity, tokens + math.max(0, now - ts) * refill_per_ms)
+
+local allowed = 0
+local retry_after = 0
+if tokens >= 1 then
+  tokens = tokens - 1
+  allowed = 1
+else
+  retry_after = math.ceil((1 - tokens) / refill_per_ms)
+end
+
+local reset_after = math.ceil((capacity - tokens) / refill_per_ms)
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', now)
+redis.call('PEXPIRE', KEYS[1], math.max(reset_after, 1000))
+
+return {allowed, math.floor(tokens), retry_after, reset_after}
+`)
+
+// RateLimitResult describes the outcome of taking a token from a bucket
+type RateLimitResult struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	RetryAfter time.Duration
+	ResetAfter time.Duration
+}
+
+// RateLimiter is a token bucket limiter shared across replicas through Redis.
+// Buckets hold up to the group's request limit and refill continuously over
+// the configured window. When Redis is unavailable it falls back to
+// process-local buckets so limits still apply per replica.
 type RateLimiter struct {
-	requests          map[string][]time.Time
-	mu                sync.RWMutex
-	requestsPerMinute int
-	window            time.Duration
+	client *redis.Client
+	limits map[string]int
+	window time.Duration
+
+	local   map[string]*localBucket
+	localMu sync.Mutex
+
+	// redisDown records whether the last Redis call failed, so outages are
+	// logged once when Redis goes down and once when it comes back
+	redisDown atomic.Bool
 }
 
-func NewRateLimiter(requestsPerMinute int) *RateLimiter {
+type localBucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+func NewRateLimiter(cfg *config.Config, client *redis.Client) *RateLimiter {
+	window := time.Duration(cfg.RateLimitWindow) * time.Second
+	if window <= 0 {
+		window = time.Minute
+	}
+
 	rl := &RateLimiter{
-		requests:          make(map[string][]time.Time),
-		requestsPerMinute: requestsPerMinute,
-		window:            time.Minute,
+		client: client,
+		limits: map[string]int{
+			RateLimitGroupAltText:   cfg.RateLimitAltTextRequests,
+			RateLimitGroupVoice:     cfg.RateLimitVoiceRequests,
+			RateLimitGroupAnalytics: cfg.RateLimitAnalyticsRequests,
+		},
+		window: window,
+		local:  make(map[string]*localBucket),
 	}
 
 	go rl.cleanup()
@@ -27,64 +117,123 @@ func NewRateLimiter(requestsPerMinute int) *RateLimiter {
 	return rl
 }
 
-func (rl *RateLimiter) Middleware() echo.MiddlewareFunc {
+// Middleware limits requests in the route group, keyed by the authenticated
+// principal when present and by client IP otherwise. Every response carries the
+// IETF draft RateLimit-* headers; rejected requests also carry Retry-After.
+func (rl *RateLimiter) Middleware(group string) echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
-			clientIP := c.RealIP()
-
-			rl.mu.Lock()
-			now := time.Now()
-			cutoff := now.Add(-rl.window)
-
-			requests := rl.requests[clientIP]
-			var validRequests []time.Time
-			for _, reqTime := range requests {
-				if reqTime.After(cutoff) {
-					validRequests = append(validRequests, reqTime)
-				}
+			limit := rl.limits[group]
+			if limit <= 0 {
+				return next(c)
 			}
 
-			if len(validRequests) >= rl.requestsPerMinute {
-				rl.mu.Unlock()
-				return c.JSON(http.StatusTooManyRequests, map[string]interface{}{
-					"success": false,
-					"error":   "Rate limit exceeded",
-					"code":    "RATE_LIMIT_EXCEEDED",
+			key := "rate_limit:" + group + ":" + clientIdentity(c)
+			result := rl.Take(c.Request().Context(), key, limit)
+			rl.setHeaders(c.Response().Header(), result)
+
+			if !result.Allowed {
+				return apierror.JSON(c, http.StatusTooManyRequests, map[string]interface{}{
+					"success":     false,
+					"error":       "Rate limit exceeded",
+					"code":        constants.ErrCodeRateLimitExceeded,
+					"retry_after": ceilSeconds(result.RetryAfter),
 				})
 			}
 
-			validRequests = append(validRequests, now)
-			rl.requests[clientIP] = validRequests
-			rl.mu.Unlock()
-
 			return next(c)
 		}
 	}
 }
 
+func (rl *RateLimiter) setHeaders(h http.Header, result RateLimitResult) {
+	h.Set("RateLimit-Policy", fmt.Sprintf("%d;w=%d", result.Limit, int(rl.window.Seconds())))
+	h.Set("RateLimit-Limit", strconv.Itoa(result.Limit))
+	h.Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+	h.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.ResetAfter)))
+	if !result.Allowed {
+		h.Set("Retry-After", strconv.Itoa(ceilSeconds(result.RetryAfter)))
+	}
+}
+
+// Take removes one token from the bucket at key, which holds up to limit tokens
+func (rl *RateLimiter) Take(ctx context.Context, key string, limit int) RateLimitResult {
+	refillPerMs := float64(limit) / float64(rl.window.Milliseconds())
+
+	if rl.client != nil {
+		res, err := tokenBucketScript.Run(ctx, rl.client, []string{key}, limit, refillPerMs).Int64Slice()
+		if err == nil && len(res) == 4 {
+			if rl.redisDown.CompareAndSwap(true, false) {
+				slog.InfoContext(ctx, "Redis rate limiter recovered", logging.ServiceKey, "rate_limiter")
+			}
+			return RateLimitResult{
+				Allowed:    res[0] == 1,
+				Limit:      limit,
+				Remaining:  int(res[1]),
+				RetryAfter: time.Duration(res[2]) * time.Millisecond,
+				ResetAfter: time.Duration(res[3]) * time.Millisecond,
+			}
+		}
+		if ctx.Err() == nil && rl.redisDown.CompareAndSwap(false, true) {
+			slog.WarnContext(ctx, "Redis rate limiter unavailable, using local buckets", logging.ServiceKey, "rate_limiter", "error", err)
+		}
+	}
+
+	return rl.takeLocal(key, limit, refillPerMs)
+}
+
+func (rl *RateLimiter) takeLocal(key string, limit int, refillPerMs float64) RateLimitResult {
+	rl.localMu.Lock()
+	defer rl.localMu.Unlock()
+
+	now := time.Now()
+	bucket, ok := rl.local[key]
+	if !ok {
+		bucket = &localBucket{tokens: float64(limit), updated: now}
+		rl.local[key] = bucket
+	}
+
+	elapsedMs := float64(now.Sub(bucket.updated).Milliseconds())
+	bucket.tokens = math.Min(float64(limit), bucket.tokens+elapsedMs*refillPerMs)
+	bucket.updated = now
+
+	result := RateLimitResult{Limit: limit}
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = time.Duration(math.Ceil((1-bucket.tokens)/refillPerMs)) * time.Millisecond
+	}
+	result.Remaining = int(math.Floor(bucket.tokens))
+	result.ResetAfter = time.Duration(math.Ceil((float64(limit)-bucket.tokens)/refillPerMs)) * time.Millisecond
+
+	return result
+}
+
+// cleanup drops local buckets that have been idle long enough to refill completely
 func (rl *RateLimiter) cleanup() {
 	ticker := time.NewTicker(5 * time.Minute)
 	defer ticker.Stop()
 
 	for range ticker.C {
-		rl.mu.Lock()
-		now := time.Now()
-		cutoff := now.Add(-rl.window)
-
-		for ip, requests := range rl.requests {
-			var validRequests []time.Time
-			for _, reqTime := range requests {
-				if reqTime.After(cutoff) {
-					validRequests = append(validRequests, reqTime)
-				}
-			}
-
-			if len(validRequests) == 0 {
-				delete(rl.requests, ip)
-			} else {
-				rl.requests[ip] = validRequests
+		rl.localMu.Lock()
+		cutoff := time.Now().Add(-rl.window)
+		for key, bucket := range rl.local {
+			if bucket.updated.Before(cutoff) {
+				delete(rl.local, key)
 			}
 		}
-		rl.mu.Unlock()
+		rl.localMu.Unlock()
+	}
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+func clientIdentity(c echo.Context) string {
+	if principal := auth.PrincipalFromContext(c.Request().Context()); principal != nil {
+		return principal.Method + ":" + principal.Subject
 	}
+	return "ip:" + c.RealIP()
 }
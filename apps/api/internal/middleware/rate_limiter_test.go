@@ -0,0 +1,119 @@
+package middleware
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRateLimiterLocalBuckets(t *testing.T) {
+	// A step either lets time pass or takes a token and checks the result
+	type step struct {
+		wait       time.Duration
+		allowed    bool
+		remaining  int
+		retryAfter time.Duration
+		resetAfter time.Duration
+	}
+
+	tests := []struct {
+		name  string
+		limit int
+		steps []step
+	}{
+		{
+			name:  "allows up to the limit then rejects",
+			limit: 3,
+			steps: []step{
+				{allowed: true, remaining: 2, resetAfter: 20 * time.Second},
+				{allowed: true, remaining: 1, resetAfter: 40 * time.Second},
+				{allowed: true, remaining: 0, resetAfter: time.Minute},
+				{allowed: false, remaining: 0, retryAfter: 20 * time.Second, resetAfter: time.Minute},
+			},
+		},
+		{
+			name:  "refills continuously",
+			limit: 2,
+			steps: []step{
+				{allowed: true, remaining: 1, resetAfter: 30 * time.Second},
+				{allowed: true, remaining: 0, resetAfter: time.Minute},
+				{wait: 15 * time.Second},
+				{allowed: false, remaining: 0, retryAfter: 15 * time.Second, resetAfter: 45 * time.Second},
+				{wait: 15 * time.Second},
+				{allowed: true, remaining: 0, resetAfter: time.Minute},
+			},
+		},
+		{
+			name:  "never refills past the limit",
+			limit: 2,
+			steps: []step{
+				{allowed: true, remaining: 1, resetAfter: 30 * time.Second},
+				{wait: time.Hour},
+				{allowed: true, remaining: 1, resetAfter: 30 * time.Second},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Without a Redis client every take uses the local buckets
+			rl := &RateLimiter{window: time.Minute, local: make(map[string]*localBucket)}
+			key := "rate_limit:test:ip:192.0.2.1"
+			for i, s := range tt.steps {
+				if s.wait > 0 {
+					rl.local[key].updated = rl.local[key].updated.Add(-s.wait)
+					continue
+				}
+				got := rl.Take(context.Background(), key, tt.limit)
+				if got.Allowed != s.allowed || got.Limit != tt.limit || got.Remaining != s.remaining {
+					t.Fatalf("step %d: Take() = %+v, want allowed %v, remaining %d", i, got, s.allowed, s.remaining)
+				}
+				if !closeTo(got.RetryAfter, s.retryAfter) || !closeTo(got.ResetAfter, s.resetAfter) {
+					t.Fatalf("step %d: Take() retry after %v, reset after %v, want %v and %v",
+						i, got.RetryAfter, got.ResetAfter, s.retryAfter, s.resetAfter)
+				}
+			}
+		})
+	}
+}
+
+func TestRateLimiterLocalBucketsAreSeparate(t *testing.T) {
+	rl := &RateLimiter{window: time.Minute, local: make(map[string]*localBucket)}
+	ctx := context.Background()
+
+	if !rl.Take(ctx, "rate_limit:voice:ip:192.0.2.1", 1).Allowed {
+		t.Fatal("first take was rejected")
+	}
+	if rl.Take(ctx, "rate_limit:voice:ip:192.0.2.1", 1).Allowed {
+		t.Fatal("second take from the same key was allowed")
+	}
+	if !rl.Take(ctx, "rate_limit:voice:ip:192.0.2.2", 1).Allowed {
+		t.Fatal("take from another client was rejected")
+	}
+	if !rl.Take(ctx, "rate_limit:alt-text:ip:192.0.2.1", 1).Allowed {
+		t.Fatal("take from another group was rejected")
+	}
+}
+
+func TestCeilSeconds(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		want int
+	}{
+		{d: 0, want: 0},
+		{d: time.Millisecond, want: 1},
+		{d: time.Second, want: 1},
+		{d: 1001 * time.Millisecond, want: 2},
+	}
+	for _, tt := range tests {
+		if got := ceilSeconds(tt.d); got != tt.want {
+			t.Errorf("ceilSeconds(%v) = %d, want %d", tt.d, got, tt.want)
+		}
+	}
+}
+
+// closeTo allows for the milliseconds that pass while a test runs
+func closeTo(got, want time.Duration) bool {
+	diff := got - want
+	return diff > -50*time.Millisecond && diff < 50*time.Millisecond
+}
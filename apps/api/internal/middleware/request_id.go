@@ -0,0 +1,98 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"altread-go/api/internal/tracing"
+
+	"github.com/labstack/echo/v4"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// RequestID accepts or generates a request ID and W3C trace context, stores them
+// in the request context and echoes them in the X-Request-ID and traceparent
+// response headers. When OpenTelemetry is enabled the trace context comes from
+// the request's server span. It should run before every other middleware.
+func RequestID() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+
+			route := c.Path()
+			if route == "" {
+				route = "unmatched"
+			}
+
+			var span trace.Span
+			t := &tracing.Trace{SpanID: tracing.NewSpanID(), Route: route, Sampled: true}
+			if tracing.Enabled() {
+				var ctx context.Context
+				ctx, span = tracing.StartServerSpan(req.Context(), propagation.HeaderCarrier(req.Header), req.Method+" "+route,
+					semconv.HTTPRequestMethodKey.String(req.Method),
+					semconv.HTTPRoute(route),
+					semconv.URLPath(req.URL.Path),
+					semconv.ClientAddress(c.RealIP()),
+				)
+				req = req.WithContext(ctx)
+
+				sc := span.SpanContext()
+				t.TraceID = sc.TraceID().String()
+				t.SpanID = sc.SpanID().String()
+				t.Sampled = sc.IsSampled()
+				if parent := trace.SpanContextFromContext(ctx); parent.IsRemote() {
+					t.ParentID = parent.SpanID().String()
+				}
+			} else if traceID, parentID, sampled, ok := tracing.ParseTraceparent(req.Header.Get(tracing.HeaderTraceparent)); ok {
+				t.TraceID = traceID
+				t.ParentID = parentID
+				t.Sampled = sampled
+			} else {
+				t.TraceID = tracing.NewTraceID()
+			}
+
+			t.RequestID = tracing.SanitizeRequestID(req.Header.Get(tracing.HeaderRequestID))
+			if t.RequestID == "" {
+				t.RequestID = t.TraceID
+			}
+
+			c.SetRequest(req.WithContext(tracing.WithTrace(req.Context(), t)))
+			c.Response().Header().Set(tracing.HeaderRequestID, t.RequestID)
+			c.Response().Header().Set(tracing.HeaderTraceparent, t.Traceparent())
+
+			if span == nil {
+				return next(c)
+			}
+
+			span.SetAttributes(attribute.String("request.id", t.RequestID))
+			err := next(c)
+			endServerSpan(span, c, err)
+			return err
+		}
+	}
+}
+
+// endServerSpan records the response status, treating 5xx responses as span errors
+func endServerSpan(span trace.Span, c echo.Context, err error) {
+	status := c.Response().Status
+	if err != nil {
+		status = http.StatusInternalServerError
+		if httpErr, ok := err.(*echo.HTTPError); ok {
+			status = httpErr.Code
+		} else if customErr, ok := err.(*CustomHTTPError); ok {
+			status = customErr.Code
+		}
+		span.RecordError(err)
+	}
+
+	span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+	if status >= http.StatusInternalServerError {
+		span.SetStatus(codes.Error, fmt.Sprintf("HTTP %d", status))
+	}
+	span.End()
+}
@@ -3,6 +3,7 @@ package models
 import (
 	"database/sql/driver"
 	"encoding/json"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -31,16 +32,44 @@ func (j *JSONB) Scan(value interface{}) error {
 	return json.Unmarshal(bytes, j)
 }
 
+type AnalyticsEvent struct {
+	ID        uuid.UUID `gorm:"type:uuid;primary_key;default:uuid_generate_v4()"`
+	EventType string    `gorm:"type:varchar(50);not null;index"`
+	EventData JSONB     `gorm:"type:jsonb"`
+	UserID    *string   `gorm:"type:varchar(100);index"`
+	// ClientUserID is the unverified X-User-ID header sent by the client
+	ClientUserID *string   `gorm:"type:varchar(100)"`
+	SessionID    *string   `gorm:"type:varchar(100);index"`
+	IPAddress    *string   `gorm:"type:inet"`
+	UserAgent    *string   `gorm:"type:text"`
+	TenantID     *string   `gorm:"type:varchar(100);index"`
+	TraceID      *string   `gorm:"type:varchar(100);index"`
+	CreatedAt    time.Time `gorm:"type:timestamptz;default:now();index"`
+}
+
+func (AnalyticsEvent) TableName() string {
+	return "analytics_events"
+}
+
 type ImageUpload struct {
 	ID               uuid.UUID `gorm:"type:uuid;primary_key;default:uuid_generate_v4()"`
 	FileName         string    `gorm:"type:varchar(255)"`
 	FileSize         int       `gorm:"type:integer"`
-	FileType         string    `gorm:"type:varchar(100)"`
+	FileType         string    `gorm:"type:varchar(50)"`
 	ImageHash        string    `gorm:"type:varchar(64);index"`
 	AltText          string    `gorm:"type:text"`
 	ProcessingTimeMS *int      `gorm:"type:integer"`
 	Success          bool      `gorm:"type:boolean;default:false"`
 	ErrorMessage     *string   `gorm:"type:text"`
+	ModelUsed        *string   `gorm:"type:varchar(100);index"`
+	CacheHit         bool      `gorm:"type:boolean;not null;default:false"`
+	FallbackUsed     bool      `gorm:"type:boolean;not null;default:false"`
+	PromptOptions    JSONB     `gorm:"type:jsonb"`
+	Language         *string   `gorm:"type:varchar(35)"`
+	UserAgent        *string   `gorm:"type:text"`
+	AuthMethod       *string   `gorm:"type:varchar(20)"`
+	TenantID         *string   `gorm:"type:varchar(100);index"`
+	TraceID          *string   `gorm:"type:varchar(100);index"`
 	CreatedAt        time.Time `gorm:"type:timestamptz;default:now();index"`
 }
 
@@ -49,13 +78,20 @@ func (ImageUpload) TableName() string {
 }
 
 type VoicePlay struct {
-	ID           uuid.UUID `gorm:"type:uuid;primary_key;default:uuid_generate_v4()"`
-	VoiceName    string    `gorm:"type:varchar(100);index"`
-	TextLength   int       `gorm:"type:integer;not null"`
-	DurationMS   *int      `gorm:"type:integer"`
-	Success      bool      `gorm:"type:boolean;default:false"`
-	ErrorMessage *string   `gorm:"type:text"`
-	CreatedAt    time.Time `gorm:"type:timestamptz;default:now();index"`
+	ID             uuid.UUID `gorm:"type:uuid;primary_key;default:uuid_generate_v4()"`
+	VoiceName      string    `gorm:"type:varchar(100);index"`
+	TextLength     int       `gorm:"type:integer;not null"`
+	DurationMS     *int      `gorm:"type:integer"`
+	Success        bool      `gorm:"type:boolean;default:false"`
+	ErrorMessage   *string   `gorm:"type:text"`
+	ErrorCode      *string   `gorm:"type:varchar(50)"`
+	ModelUsed      *string   `gorm:"type:varchar(50);index"`
+	Speed          *float64  `gorm:"type:real"`
+	ResponseFormat *string   `gorm:"type:varchar(10)"`
+	AudioBytes     *int      `gorm:"type:integer"`
+	TenantID       *string   `gorm:"type:varchar(100);index"`
+	TraceID        *string   `gorm:"type:varchar(100);index"`
+	CreatedAt      time.Time `gorm:"type:timestamptz;default:now();index"`
 }
 
 func (VoicePlay) TableName() string {
@@ -69,6 +105,7 @@ type ApplicationLog struct {
 	Service   string    `gorm:"type:varchar(100);not null;index"`
 	Message   string    `gorm:"type:text;not null"`
 	TraceID   *string   `gorm:"type:varchar(100);index"`
+	TenantID  *string   `gorm:"type:varchar(100);index"`
 	Context   JSONB     `gorm:"type:jsonb"`
 	CreatedAt time.Time `gorm:"type:timestamptz;default:now();index"`
 }
@@ -76,3 +113,135 @@ type ApplicationLog struct {
 func (ApplicationLog) TableName() string {
 	return "application_logs"
 }
+
+type SystemPerformance struct {
+	ID          uuid.UUID `gorm:"type:uuid;primary_key;default:uuid_generate_v4()"`
+	MetricName  string    `gorm:"type:varchar(100);not null;index"`
+	MetricValue float64   `gorm:"type:decimal(15,4);not null"`
+	MetricUnit  *string   `gorm:"type:varchar(20)"`
+	Method      *string   `gorm:"type:varchar(10)"`
+	Route       *string   `gorm:"type:varchar(255)"`
+	Operation   *string   `gorm:"type:varchar(50)"`
+	Model       *string   `gorm:"type:varchar(100)"`
+	RecordedAt  time.Time `gorm:"type:timestamptz;default:now();index"`
+}
+
+func (SystemPerformance) TableName() string {
+	return "system_performance"
+}
+
+type APIKey struct {
+	ID         uuid.UUID  `gorm:"type:uuid;primary_key;default:uuid_generate_v4()"`
+	Name       string     `gorm:"type:varchar(100);not null"`
+	TenantID   string     `gorm:"type:varchar(100);not null;index"`
+	KeyPrefix  string     `gorm:"type:varchar(16);not null"`
+	KeyHash    string     `gorm:"type:varchar(64);not null;uniqueIndex"`
+	Scopes     string     `gorm:"type:text;not null"`
+	ExpiresAt  *time.Time `gorm:"type:timestamptz"`
+	RevokedAt  *time.Time `gorm:"type:timestamptz"`
+	LastUsedAt *time.Time `gorm:"type:timestamptz"`
+	CreatedAt  time.Time  `gorm:"type:timestamptz;default:now()"`
+}
+
+func (APIKey) TableName() string {
+	return "api_keys"
+}
+
+// ScopeList returns the space-separated scopes as a slice
+func (k *APIKey) ScopeList() []string {
+	return strings.Fields(k.Scopes)
+}
+
+// DailyStats is one day's rollup of image_uploads and voice_plays for a tenant;
+// TenantKey is empty for traffic without a tenant
+type DailyStats struct {
+	ID                    uuid.UUID `gorm:"type:uuid;primary_key;default:uuid_generate_v4()"`
+	Date                  time.Time `gorm:"type:date;not null"`
+	TenantKey             string    `gorm:"type:varchar(100);not null;default:''"`
+	TotalImages           int64     `gorm:"type:integer;default:0"`
+	TotalAltTexts         int64     `gorm:"type:integer;default:0"`
+	SuccessfulImages      int64     `gorm:"type:integer;default:0"`
+	FailedImages          int64     `gorm:"type:integer;default:0"`
+	TotalProcessingTimeMS int64     `gorm:"column:total_processing_time_ms;type:bigint;default:0"`
+	ProcessingTimeSamples int64     `gorm:"type:integer;default:0"`
+	AvgProcessingTimeMS   float64   `gorm:"column:avg_processing_time_ms;type:decimal(10,2);default:0"`
+	SuccessRate           float64   `gorm:"type:decimal(5,2);default:0"`
+	TotalVoicePlays       int64     `gorm:"type:integer;default:0"`
+	SuccessfulVoicePlays  int64     `gorm:"type:integer;default:0"`
+	CacheHits             int64     `gorm:"type:integer;default:0"`
+	CreatedAt             time.Time `gorm:"type:timestamptz;default:now()"`
+	UpdatedAt             time.Time `gorm:"type:timestamptz;default:now()"`
+}
+
+func (DailyStats) TableName() string {
+	return "daily_stats"
+}
+
+// DailyVoiceStats is one day's successful play count of a voice for a tenant
+type DailyVoiceStats struct {
+	Date      time.Time `gorm:"type:date;primaryKey"`
+	TenantKey string    `gorm:"type:varchar(100);primaryKey"`
+	VoiceName string    `gorm:"type:varchar(100);primaryKey"`
+	PlayCount int64     `gorm:"type:integer;not null;default:0"`
+}
+
+func (DailyVoiceStats) TableName() string {
+	return "daily_voice_stats"
+}
+
+// DailyModelStats is one day's generations by a model for a tenant
+type DailyModelStats struct {
+	Date                  time.Time `gorm:"type:date;primaryKey"`
+	TenantKey             string    `gorm:"type:varchar(100);primaryKey"`
+	ModelUsed             string    `gorm:"type:varchar(100);primaryKey"`
+	Total                 int64     `gorm:"type:integer;not null;default:0"`
+	Failed                int64     `gorm:"type:integer;not null;default:0"`
+	TotalProcessingTimeMS int64     `gorm:"column:total_processing_time_ms;type:bigint;not null;default:0"`
+	ProcessingTimeSamples int64     `gorm:"type:integer;not null;default:0"`
+	CacheHits             int64     `gorm:"type:integer;not null;default:0"`
+	Fallbacks             int64     `gorm:"type:integer;not null;default:0"`
+}
+
+func (DailyModelStats) TableName() string {
+	return "daily_model_stats"
+}
+
+// DailyErrorStats is one day's count of failed generations with an error message for a tenant
+type DailyErrorStats struct {
+	Date       time.Time `gorm:"type:date;primaryKey"`
+	TenantKey  string    `gorm:"type:varchar(100);primaryKey"`
+	Message    string    `gorm:"type:varchar(200);primaryKey"`
+	ErrorCount int64     `gorm:"type:integer;not null;default:0"`
+}
+
+func (DailyErrorStats) TableName() string {
+	return "daily_error_stats"
+}
+
+// DailyLatencyStats is one day's count of generations in a processing time bucket for a tenant
+type DailyLatencyStats struct {
+	Date                  time.Time `gorm:"type:date;primaryKey"`
+	TenantKey             string    `gorm:"type:varchar(100);primaryKey"`
+	LatencyBucket         int       `gorm:"type:smallint;primaryKey"`
+	RequestCount          int64     `gorm:"type:integer;not null;default:0"`
+	TotalProcessingTimeMS int64     `gorm:"column:total_processing_time_ms;type:bigint;not null;default:0"`
+}
+
+func (DailyLatencyStats) TableName() string {
+	return "daily_latency_stats"
+}
+
+// VoiceUsageStats is the running play count of a voice for a tenant
+type VoiceUsageStats struct {
+	ID         uuid.UUID `gorm:"type:uuid;primary_key;default:uuid_generate_v4()"`
+	TenantKey  string    `gorm:"type:varchar(100);not null;default:''"`
+	VoiceName  string    `gorm:"type:varchar(100);not null"`
+	UsageCount int64     `gorm:"type:integer;default:0"`
+	LastUsed   time.Time `gorm:"type:timestamptz;default:now()"`
+	CreatedAt  time.Time `gorm:"type:timestamptz;default:now()"`
+	UpdatedAt  time.Time `gorm:"type:timestamptz;default:now()"`
+}
+
+func (VoiceUsageStats) TableName() string {
+	return "voice_usage_stats"
+}
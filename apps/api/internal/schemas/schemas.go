@@ -1,8 +1,14 @@
 package schemas
 
+import "encoding/json"
+
 type GenerateAltTextRequest struct {
 	Image   string                 `json:"image" binding:"required"`
 	Options map[string]interface{} `json:"options"`
+
+	// Client details recorded with the request, set by the handler
+	UserAgent      string `json:"-"`
+	AcceptLanguage string `json:"-"`
 }
 
 type GenerateAltTextResponse struct {
@@ -11,6 +17,7 @@ type GenerateAltTextResponse struct {
 	Confidence     *float64 `json:"confidence,omitempty"`
 	ProcessingTime int      `json:"processing_time"`
 	Error          *string  `json:"error,omitempty"`
+	RequestID      string   `json:"request_id,omitempty"`
 }
 
 type TTSRequest struct {
@@ -26,14 +33,22 @@ type TTSResponse struct {
 	AudioBuffer []byte  `json:"-"`
 	Error       *string `json:"error,omitempty"`
 	Code        *string `json:"code,omitempty"`
+	RequestID   string  `json:"request_id,omitempty"`
+	// Time spent waiting on the TTS provider, zero if it was not called
+	SynthesisTimeMS int `json:"-"`
 }
 
 type VoicePlayEvent struct {
-	VoiceName    string  `json:"voice_name" binding:"required"`
-	TextLength   int     `json:"text_length"`
-	DurationMS   int     `json:"duration_ms"`
-	Success      bool    `json:"success"`
-	ErrorMessage *string `json:"error_message,omitempty"`
+	VoiceName      string  `json:"voice_name" binding:"required"`
+	TextLength     int     `json:"text_length"`
+	DurationMS     int     `json:"duration_ms"`
+	Success        bool    `json:"success"`
+	ErrorMessage   *string `json:"error_message,omitempty"`
+	ErrorCode      string  `json:"error_code,omitempty"`
+	Model          string  `json:"model,omitempty"`
+	Speed          float64 `json:"speed,omitempty"`
+	ResponseFormat string  `json:"response_format,omitempty"`
+	AudioBytes     int     `json:"audio_bytes,omitempty"`
 }
 
 type ImageUploadEvent struct {
@@ -47,6 +62,31 @@ type ImageUploadEvent struct {
 	ErrorMessage     *string
 }
 
+type ClientEventRequest struct {
+	Type      string          `json:"type"`
+	SessionID string          `json:"session_id"`
+	Data      json.RawMessage `json:"data"`
+}
+
+type AltTextCopiedEvent struct {
+	AltTextLength int    `json:"alt_text_length"`
+	ImageHash     string `json:"image_hash,omitempty"`
+}
+
+type VoicePlaybackCompletedEvent struct {
+	VoiceName  string `json:"voice_name"`
+	TextLength int    `json:"text_length"`
+	DurationMS int    `json:"duration_ms"`
+}
+
+type VoicePlaybackAbortedEvent struct {
+	VoiceName  string `json:"voice_name"`
+	TextLength int    `json:"text_length"`
+	PositionMS int    `json:"position_ms"`
+	DurationMS int    `json:"duration_ms,omitempty"`
+	Reason     string `json:"reason,omitempty"`
+}
+
 type APIResponse struct {
 	Success bool        `json:"success"`
 	Data    interface{} `json:"data,omitempty"`
@@ -2,6 +2,10 @@ package services
 
 import (
 	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
 	"time"
 
 	"altread-go/api/internal/database"
@@ -24,17 +28,30 @@ func NewAnalyticsService() *AnalyticsService {
 
 // AnalyticsData represents aggregated analytics data
 type AnalyticsData struct {
-	TotalImagesProcessed  int64                            `json:"totalImagesProcessed"`
-	TotalVoicePlays        int64                            `json:"totalVoicePlays"`
-	ImagesOverTime         []ImageCountByDate               `json:"imagesOverTime"`
-	VoiceUsage             []VoiceUsageStat                 `json:"voiceUsage"`
-	SuccessRate            float64                          `json:"successRate"`
-	AverageProcessingTime  float64                          `json:"averageProcessingTime"`
-	TotalSuccessful        int64                            `json:"totalSuccessful"`
-	TotalFailed            int64                            `json:"totalFailed"`
+	TotalImagesProcessed      int64                `json:"totalImagesProcessed"`
+	TotalVoicePlays           int64                `json:"totalVoicePlays"`
+	ImagesOverTime            []ImageCountByDate   `json:"imagesOverTime"`
+	VoiceUsage                []VoiceUsageStat     `json:"voiceUsage"`
+	SuccessRate               float64              `json:"successRate"`
+	AverageProcessingTime     float64              `json:"averageProcessingTime"`
+	TotalSuccessful           int64                `json:"totalSuccessful"`
+	TotalFailed               int64                `json:"totalFailed"`
+	ProcessingTimePercentiles LatencyStats         `json:"processingTimePercentiles"`
+	LatencyOverTime           []LatencyPoint       `json:"latencyOverTime"`
+	ErrorBreakdown            []ErrorCategoryStat  `json:"errorBreakdown"`
+	FailureRateByModel        []ModelStat          `json:"failureRateByModel"`
+	CacheHitRatio             float64              `json:"cacheHitRatio"`
+	CacheStats                CacheStats           `json:"cacheStats"`
+	VoiceModelBreakdown       []VoiceBreakdownStat `json:"voiceModelBreakdown"`
+	VoiceFormatBreakdown      []VoiceBreakdownStat `json:"voiceFormatBreakdown"`
+	TextLengthHistogram       []TextLengthBucket   `json:"textLengthHistogram"`
+	Granularity               string               `json:"granularity"`
+	TimeZone                  string               `json:"timeZone"`
+	From                      *time.Time           `json:"from,omitempty"`
+	To                        time.Time            `json:"to"`
 }
 
-// ImageCountByDate represents daily image count
+// ImageCountByDate represents the image count of one time bucket
 type ImageCountByDate struct {
 	Date  string `json:"date"`
 	Count int64  `json:"count"`
@@ -42,156 +59,432 @@ type ImageCountByDate struct {
 
 // VoiceUsageStat represents voice usage statistics
 type VoiceUsageStat struct {
-	VoiceName  string  `json:"voiceName"`
-	Count      int64   `json:"count"`
-	Percentage float64 `json:"percentage"`
+	VoiceName            string  `json:"voiceName"`
+	Count                int64   `json:"count"`
+	Percentage           float64 `json:"percentage"`
+	Failed               int64   `json:"failed"`
+	FailureRate          float64 `json:"failureRate"`
+	AverageSynthesisTime float64 `json:"averageSynthesisTime"`
 }
 
-// GetAnalytics retrieves aggregated analytics data for the specified time range
-func (as *AnalyticsService) GetAnalytics(ctx context.Context, timeRange string) (*AnalyticsData, error) {
-	// Calculate date filter
-	var dateFilter time.Time
-	switch timeRange {
-	case "7d":
-		dateFilter = time.Now().AddDate(0, 0, -7)
-	case "30d":
-		dateFilter = time.Now().AddDate(0, 0, -30)
-	case "90d":
-		dateFilter = time.Now().AddDate(0, 0, -90)
-	case "all":
-		dateFilter = time.Time{} // Zero time means no filter
-	default:
-		dateFilter = time.Now().AddDate(0, 0, -30) // Default to 30 days
+// GetAnalytics retrieves aggregated analytics data for the query's range, tenant
+// and time series granularity. When buckets fall on UTC days, days already rolled
+// up into daily_stats are read from the rollups and only the remaining spans are
+// aggregated from the event tables. Image totals leave out cache hits, which are
+// counted in CacheStats.
+func (as *AnalyticsService) GetAnalytics(ctx context.Context, q AnalyticsQuery) (*AnalyticsData, error) {
+	if err := q.Validate(); err != nil {
+		return nil, err
 	}
 
-	data := &AnalyticsData{}
+	p, err := as.plan(ctx, q)
+	if err != nil {
+		return nil, err
+	}
 
-	// Build base query for image uploads
-	baseImageQuery := as.db.WithContext(ctx).Session(&gorm.Session{PrepareStmt: false}).Model(&models.ImageUpload{})
-	if !dateFilter.IsZero() {
-		baseImageQuery = baseImageQuery.Where("created_at >= ?", dateFilter)
+	// Totals
+	var rolled, live analyticsTotals
+	if p.useRollups() {
+		err := as.rollupQuery(ctx, &models.DailyStats{}, p.rollFrom, p.rollTo, q.TenantID).
+			Select(`COALESCE(SUM(total_images), 0) AS total_images,
+				COALESCE(SUM(successful_images), 0) AS successful_images,
+				COALESCE(SUM(failed_images), 0) AS failed_images,
+				COALESCE(SUM(total_processing_time_ms), 0) AS processing_time_ms,
+				COALESCE(SUM(processing_time_samples), 0) AS processing_time_samples,
+				COALESCE(SUM(cache_hits), 0) AS cache_hits,
+				COALESCE(SUM(successful_voice_plays), 0) AS voice_plays`).
+			Scan(&rolled).Error
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	// Total images processed
-	var totalImages int64
-	if err := baseImageQuery.Count(&totalImages).Error; err != nil {
-		return nil, err
+	if len(p.live) > 0 {
+		err := as.liveQuery(ctx, &models.ImageUpload{}, p.live, q.TenantID).
+			Select(`COUNT(*) FILTER (WHERE NOT cache_hit) AS total_images,
+				COUNT(*) FILTER (WHERE NOT cache_hit AND success) AS successful_images,
+				COUNT(*) FILTER (WHERE NOT cache_hit AND NOT success) AS failed_images,
+				COALESCE(SUM(processing_time_ms) FILTER (WHERE NOT cache_hit), 0) AS processing_time_ms,
+				COUNT(processing_time_ms) FILTER (WHERE NOT cache_hit) AS processing_time_samples,
+				COUNT(*) FILTER (WHERE cache_hit) AS cache_hits`).
+			Scan(&live).Error
+		if err != nil {
+			return nil, err
+		}
+		if err := as.liveQuery(ctx, &models.VoicePlay{}, p.live, q.TenantID).Where("success").Count(&live.VoicePlays).Error; err != nil {
+			return nil, err
+		}
 	}
-	data.TotalImagesProcessed = totalImages
 
-	// Total successful and failed images
-	var totalSuccessful, totalFailed int64
-	successQuery := as.db.WithContext(ctx).Session(&gorm.Session{PrepareStmt: false}).Model(&models.ImageUpload{})
-	if !dateFilter.IsZero() {
-		successQuery = successQuery.Where("created_at >= ?", dateFilter)
+	totals := rolled.add(live)
+	data := &AnalyticsData{
+		TotalImagesProcessed: totals.TotalImages,
+		TotalVoicePlays:      totals.VoicePlays,
+		TotalSuccessful:      totals.SuccessfulImages,
+		TotalFailed:          totals.FailedImages,
+		Granularity:          q.Granularity,
+		TimeZone:             q.Location.String(),
+		To:                   q.To,
 	}
-	if err := successQuery.Where("success = ?", true).Count(&totalSuccessful).Error; err != nil {
+	if !q.From.IsZero() {
+		from := q.From
+		data.From = &from
+	}
+	if totals.TotalImages > 0 {
+		data.SuccessRate = float64(totals.SuccessfulImages) / float64(totals.TotalImages) * 100
+	}
+	if totals.ProcessingTimeSamples > 0 {
+		data.AverageProcessingTime = float64(totals.ProcessingTimeMS) / float64(totals.ProcessingTimeSamples)
+	}
+	data.CacheStats = CacheStats{Hits: totals.CacheHits, Misses: totals.TotalImages}
+	if requests := totals.CacheHits + totals.TotalImages; requests > 0 {
+		data.CacheHitRatio = float64(totals.CacheHits) / float64(requests) * 100
+	}
+
+	if data.ImagesOverTime, err = as.imagesOverTime(ctx, q, p); err != nil {
+		return nil, err
+	}
+	if data.VoiceUsage, err = as.voiceUsage(ctx, q, p, totals.VoicePlays); err != nil {
 		return nil, err
 	}
-	
-	failedQuery := as.db.WithContext(ctx).Session(&gorm.Session{PrepareStmt: false}).Model(&models.ImageUpload{})
-	if !dateFilter.IsZero() {
-		failedQuery = failedQuery.Where("created_at >= ?", dateFilter)
+	if err := as.addQualityStats(ctx, q, p, data); err != nil {
+		return nil, err
 	}
-	if err := failedQuery.Where("success = ?", false).Count(&totalFailed).Error; err != nil {
+	if err := as.addVoiceStats(ctx, q, data); err != nil {
 		return nil, err
 	}
-	data.TotalSuccessful = totalSuccessful
-	data.TotalFailed = totalFailed
 
-	// Success rate
-	if totalImages > 0 {
-		data.SuccessRate = float64(totalSuccessful) / float64(totalImages) * 100
+	return data, nil
+}
+
+// imagesOverTime counts images generated per bucket, leaving out cache hits,
+// with empty buckets filled with zero
+func (as *AnalyticsService) imagesOverTime(ctx context.Context, q AnalyticsQuery, p analyticsPlan) ([]ImageCountByDate, error) {
+	var rows []struct {
+		Bucket time.Time `gorm:"column:bucket"`
+		Count  int64     `gorm:"column:count"`
+	}
+	if p.useRollups() {
+		err := as.rollupQuery(ctx, &models.DailyStats{}, p.rollFrom, p.rollTo, q.TenantID).
+			Select(fmt.Sprintf("date_trunc('%s', date::timestamp) AS bucket, SUM(total_images) AS count", q.Granularity)).
+			Group("1").
+			Scan(&rows).Error
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	// Average processing time
-	avgQuery := as.db.WithContext(ctx).Session(&gorm.Session{PrepareStmt: false}).Model(&models.ImageUpload{}).
-		Select("COALESCE(AVG(processing_time_ms), 0) as avg_time").
-		Where("processing_time_ms IS NOT NULL")
-	if !dateFilter.IsZero() {
-		avgQuery = avgQuery.Where("created_at >= ?", dateFilter)
+	if len(p.live) > 0 {
+		var liveRows []struct {
+			Bucket time.Time `gorm:"column:bucket"`
+			Count  int64     `gorm:"column:count"`
+		}
+		err := as.liveQuery(ctx, &models.ImageUpload{}, p.live, q.TenantID).
+			Select(fmt.Sprintf("date_trunc('%s', created_at AT TIME ZONE ?) AS bucket, COUNT(*) AS count", q.Granularity), q.Location.String()).
+			Where("NOT cache_hit").
+			Group("1").
+			Scan(&liveRows).Error
+		if err != nil {
+			return nil, err
+		}
+		rows = append(rows, liveRows...)
 	}
-	
-	var result struct {
-		AvgTime float64 `gorm:"column:avg_time"`
+
+	counts := make(map[int64]int64, len(rows))
+	var first time.Time
+	for _, row := range rows {
+		bucket := wallClock(row.Bucket, q.Location)
+		counts[bucket.Unix()] += row.Count
+		if first.IsZero() || bucket.Before(first) {
+			first = bucket
+		}
 	}
-	if err := avgQuery.Scan(&result).Error; err != nil {
+
+	starts, err := q.seriesStarts(first)
+	if err != nil {
 		return nil, err
 	}
-	data.AverageProcessingTime = result.AvgTime
 
-	// Images over time (daily aggregation)
-	var imagesOverTime []struct {
-		Date  string `gorm:"column:date"`
-		Count int64  `gorm:"column:count"`
+	series := make([]ImageCountByDate, len(starts))
+	for i, start := range starts {
+		series[i] = ImageCountByDate{
+			Date:  q.formatBucket(start),
+			Count: counts[start.Unix()],
+		}
 	}
-	
-	dateQuery := as.db.WithContext(ctx).Session(&gorm.Session{PrepareStmt: false}).Model(&models.ImageUpload{}).
-		Select("created_at::date as date, COUNT(*) as count").
-		Group("created_at::date")
-	if !dateFilter.IsZero() {
-		dateQuery = dateQuery.Where("created_at >= ?", dateFilter)
+	return series, nil
+}
+
+// voiceUsage returns the ten most played voices
+func (as *AnalyticsService) voiceUsage(ctx context.Context, q AnalyticsQuery, p analyticsPlan, totalPlays int64) ([]VoiceUsageStat, error) {
+	type voiceCount struct {
+		VoiceName string `gorm:"column:voice_name"`
+		Count     int64  `gorm:"column:count"`
 	}
-	dateQuery = dateQuery.Order("created_at::date ASC")
-	
-	if err := dateQuery.Scan(&imagesOverTime).Error; err != nil {
-		return nil, err
+	var rolled, live []voiceCount
+	if p.useRollups() {
+		err := as.rollupQuery(ctx, &models.DailyVoiceStats{}, p.rollFrom, p.rollTo, q.TenantID).
+			Select("voice_name, SUM(play_count) as count").
+			Group("voice_name").
+			Scan(&rolled).Error
+		if err != nil {
+			return nil, err
+		}
 	}
+	if len(p.live) > 0 {
+		err := as.liveQuery(ctx, &models.VoicePlay{}, p.live, q.TenantID).
+			Select("voice_name, COUNT(*) as count").
+			Where("success").
+			Group("voice_name").
+			Scan(&live).Error
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	counts := make(map[string]int64, len(rolled)+len(live))
+	for _, item := range append(rolled, live...) {
+		counts[item.VoiceName] += item.Count
+	}
+
+	usage := make([]VoiceUsageStat, 0, len(counts))
+	for voiceName, count := range counts {
+		var percentage float64
+		if totalPlays > 0 {
+			percentage = float64(count) / float64(totalPlays) * 100
+		}
+		usage = append(usage, VoiceUsageStat{
+			VoiceName:  voiceName,
+			Count:      count,
+			Percentage: percentage,
+		})
+	}
+	sort.Slice(usage, func(i, j int) bool {
+		if usage[i].Count != usage[j].Count {
+			return usage[i].Count > usage[j].Count
+		}
+		return usage[i].VoiceName < usage[j].VoiceName
+	})
+	if len(usage) > 10 {
+		usage = usage[:10]
+	}
+	return usage, nil
+}
 
-	data.ImagesOverTime = make([]ImageCountByDate, len(imagesOverTime))
-	for i, item := range imagesOverTime {
-		data.ImagesOverTime[i] = ImageCountByDate{
-			Date:  item.Date,
-			Count: item.Count,
+// analyticsPlan splits a query's range into rolled-up UTC days [rollFrom, rollTo)
+// and the spans read from the event tables
+type analyticsPlan struct {
+	rollFrom time.Time
+	rollTo   time.Time
+	live     []timeSpan
+}
+
+func (p analyticsPlan) useRollups() bool {
+	return !p.rollTo.IsZero()
+}
+
+func (as *AnalyticsService) plan(ctx context.Context, q AnalyticsQuery) (analyticsPlan, error) {
+	var p analyticsPlan
+	if q.utc() {
+		through, err := as.rolledThrough(ctx)
+		if err != nil {
+			return p, err
+		}
+		p.rollFrom = q.From
+		if !p.rollFrom.IsZero() && !p.rollFrom.Equal(startOfDay(p.rollFrom)) {
+			p.rollFrom = startOfDay(p.rollFrom).AddDate(0, 0, 1)
+		}
+		p.rollTo = startOfDay(q.To)
+		if through.Before(p.rollTo) {
+			p.rollTo = through
 		}
 	}
 
-	// Build base query for voice plays
-	voiceQuery := as.db.WithContext(ctx).Session(&gorm.Session{PrepareStmt: false}).Model(&models.VoicePlay{})
-	if !dateFilter.IsZero() {
-		voiceQuery = voiceQuery.Where("created_at >= ?", dateFilter)
+	if p.rollTo.IsZero() || !p.rollFrom.Before(p.rollTo) {
+		return analyticsPlan{live: []timeSpan{{from: q.From, to: q.To}}}, nil
+	}
+	if !q.From.Equal(p.rollFrom) {
+		p.live = append(p.live, timeSpan{from: q.From, to: p.rollFrom})
 	}
+	if p.rollTo.Before(q.To) {
+		p.live = append(p.live, timeSpan{from: p.rollTo, to: q.To})
+	}
+	return p, nil
+}
 
-	// Total voice plays
-	var totalVoicePlays int64
-	if err := voiceQuery.Count(&totalVoicePlays).Error; err != nil {
-		return nil, err
+// analyticsTotals are the summed image and voice counters of a period
+type analyticsTotals struct {
+	TotalImages           int64 `gorm:"column:total_images"`
+	SuccessfulImages      int64 `gorm:"column:successful_images"`
+	FailedImages          int64 `gorm:"column:failed_images"`
+	ProcessingTimeMS      int64 `gorm:"column:processing_time_ms"`
+	ProcessingTimeSamples int64 `gorm:"column:processing_time_samples"`
+	CacheHits             int64 `gorm:"column:cache_hits"`
+	VoicePlays            int64 `gorm:"column:voice_plays"`
+}
+
+func (t analyticsTotals) add(o analyticsTotals) analyticsTotals {
+	return analyticsTotals{
+		TotalImages:           t.TotalImages + o.TotalImages,
+		SuccessfulImages:      t.SuccessfulImages + o.SuccessfulImages,
+		FailedImages:          t.FailedImages + o.FailedImages,
+		ProcessingTimeMS:      t.ProcessingTimeMS + o.ProcessingTimeMS,
+		ProcessingTimeSamples: t.ProcessingTimeSamples + o.ProcessingTimeSamples,
+		CacheHits:             t.CacheHits + o.CacheHits,
+		VoicePlays:            t.VoicePlays + o.VoicePlays,
 	}
-	data.TotalVoicePlays = totalVoicePlays
+}
 
-	// Voice usage breakdown (top 10)
-	var voiceUsage []struct {
-		VoiceName string `gorm:"column:voice_name"`
-		Count     int64  `gorm:"column:count"`
+// rolledThrough returns the end of the last rolled-up day, or zero time if
+// nothing has been rolled up yet
+func (as *AnalyticsService) rolledThrough(ctx context.Context) (time.Time, error) {
+	var last sql.NullTime
+	if err := as.db.WithContext(ctx).Raw("SELECT MAX(date) FROM daily_stats").Scan(&last).Error; err != nil {
+		return time.Time{}, err
+	}
+	if !last.Valid {
+		return time.Time{}, nil
+	}
+	return startOfDay(last.Time).AddDate(0, 0, 1), nil
+}
+
+// PerformanceSeries is one system_performance metric charted over time, for
+// one endpoint or provider model where the metric has them
+type PerformanceSeries struct {
+	MetricName string             `json:"metricName"`
+	Method     string             `json:"method,omitempty"`
+	Route      string             `json:"route,omitempty"`
+	Operation  string             `json:"operation,omitempty"`
+	Model      string             `json:"model,omitempty"`
+	Unit       string             `json:"unit"`
+	Points     []PerformancePoint `json:"points"`
+}
+
+// PerformancePoint is the aggregate of a metric's samples within one time bucket
+type PerformancePoint struct {
+	Time time.Time `json:"time"`
+	Avg  float64   `json:"avg"`
+	Max  float64   `json:"max"`
+}
+
+// GetPerformanceSeries charts sampled system metrics whose names start with
+// metricPrefix, optionally for one route or provider model, in hourly buckets
+// for 7d and daily buckets for longer ranges
+func (as *AnalyticsService) GetPerformanceSeries(ctx context.Context, timeRange, metricPrefix, route, model string) ([]PerformanceSeries, error) {
+	bucket := "day"
+	if timeRange == "7d" {
+		bucket = "hour"
 	}
 
-	voiceUsageQuery := as.db.WithContext(ctx).Session(&gorm.Session{PrepareStmt: false}).Model(&models.VoicePlay{}).
-		Select("voice_name, COUNT(*) as count").
-		Group("voice_name").
-		Order("count DESC").
-		Limit(10)
-	if !dateFilter.IsZero() {
-		voiceUsageQuery = voiceUsageQuery.Where("created_at >= ?", dateFilter)
+	query := as.db.WithContext(ctx).Session(&gorm.Session{PrepareStmt: false}).Model(&models.SystemPerformance{}).
+		Select(`metric_name, COALESCE(method, '') as method, COALESCE(route, '') as route,
+			COALESCE(operation, '') as operation, COALESCE(model, '') as model,
+			COALESCE(metric_unit, '') as metric_unit, date_trunc(?, recorded_at) as bucket,
+			AVG(metric_value) as avg_value, MAX(metric_value) as max_value`, bucket).
+		Group("metric_name, method, route, operation, model, metric_unit, bucket").
+		Order("metric_name ASC, method ASC, route ASC, operation ASC, model ASC, bucket ASC")
+	if dateFilter := timeRangeStart(timeRange); !dateFilter.IsZero() {
+		query = query.Where("recorded_at >= ?", dateFilter)
+	}
+	if metricPrefix != "" {
+		query = query.Where("metric_name LIKE ?", escapeLike(metricPrefix)+"%")
+	}
+	if route != "" {
+		query = query.Where("route = ?", route)
+	}
+	if model != "" {
+		query = query.Where("model = ?", model)
 	}
 
-	if err := voiceUsageQuery.Scan(&voiceUsage).Error; err != nil {
+	var rows []struct {
+		MetricName string    `gorm:"column:metric_name"`
+		Method     string    `gorm:"column:method"`
+		Route      string    `gorm:"column:route"`
+		Operation  string    `gorm:"column:operation"`
+		Model      string    `gorm:"column:model"`
+		MetricUnit string    `gorm:"column:metric_unit"`
+		Bucket     time.Time `gorm:"column:bucket"`
+		AvgValue   float64   `gorm:"column:avg_value"`
+		MaxValue   float64   `gorm:"column:max_value"`
+	}
+	if err := query.Scan(&rows).Error; err != nil {
 		return nil, err
 	}
 
-	// Calculate percentages
-	data.VoiceUsage = make([]VoiceUsageStat, len(voiceUsage))
-	for i, item := range voiceUsage {
-		var percentage float64
-		if totalVoicePlays > 0 {
-			percentage = float64(item.Count) / float64(totalVoicePlays) * 100
+	series := []PerformanceSeries{}
+	for _, row := range rows {
+		key := PerformanceSeries{
+			MetricName: row.MetricName,
+			Method:     row.Method,
+			Route:      row.Route,
+			Operation:  row.Operation,
+			Model:      row.Model,
+			Unit:       row.MetricUnit,
 		}
-		data.VoiceUsage[i] = VoiceUsageStat{
-			VoiceName:  item.VoiceName,
-			Count:      item.Count,
-			Percentage: percentage,
+		if len(series) == 0 || !series[len(series)-1].sameSeries(key) {
+			series = append(series, key)
 		}
+		current := &series[len(series)-1]
+		current.Points = append(current.Points, PerformancePoint{
+			Time: row.Bucket,
+			Avg:  row.AvgValue,
+			Max:  row.MaxValue,
+		})
 	}
 
-	return data, nil
+	return series, nil
+}
+
+// sameSeries reports whether two series are the same metric with the same labels
+func (ps PerformanceSeries) sameSeries(other PerformanceSeries) bool {
+	return ps.MetricName == other.MetricName && ps.Method == other.Method && ps.Route == other.Route &&
+		ps.Operation == other.Operation && ps.Model == other.Model && ps.Unit == other.Unit
 }
 
+// timeRangeStart returns the start of a 7d/30d/90d/all range; zero time means no filter
+func timeRangeStart(timeRange string) time.Time {
+	switch timeRange {
+	case "7d":
+		return time.Now().AddDate(0, 0, -7)
+	case "30d":
+		return time.Now().AddDate(0, 0, -30)
+	case "90d":
+		return time.Now().AddDate(0, 0, -90)
+	case "all":
+		return time.Time{}
+	default:
+		return time.Now().AddDate(0, 0, -30) // Default to 30 days
+	}
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+func escapeLike(s string) string {
+	return likeEscaper.Replace(s)
+}
+
+// liveQuery builds a query on an event table model for the events in spans
+// and the tenant, when set
+func (as *AnalyticsService) liveQuery(ctx context.Context, model interface{}, spans []timeSpan, tenantID string) *gorm.DB {
+	cond, args := spanCondition("created_at", spans)
+	query := as.db.WithContext(ctx).Session(&gorm.Session{PrepareStmt: false}).Model(model).
+		Where(cond, args...)
+	if tenantID != "" {
+		query = query.Where("tenant_id = ?", tenantID)
+	}
+	return query
+}
+
+// rollupQuery builds a query on a daily rollup model for the days in [from, to)
+// and the tenant, when set
+func (as *AnalyticsService) rollupQuery(ctx context.Context, model interface{}, from, to time.Time, tenantID string) *gorm.DB {
+	query := as.db.WithContext(ctx).Session(&gorm.Session{PrepareStmt: false}).Model(model).
+		Where("date < ?", to)
+	if !from.IsZero() {
+		query = query.Where("date >= ?", from)
+	}
+	if tenantID != "" {
+		query = query.Where("tenant_key = ?", tenantID)
+	}
+	return query
+}
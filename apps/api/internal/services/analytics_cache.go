@@ -0,0 +1,208 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log/slog"
+	"strconv"
+	"sync"
+	"time"
+
+	"altread-go/api/internal/config"
+	"altread-go/api/internal/logging"
+	"altread-go/api/internal/metrics"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Prefixes of the Redis counters bumped by writes and of the times they were
+// last bumped, one per UTC day across tenants and one per UTC day and tenant
+const (
+	analyticsVersionKey  = "analytics:version"
+	analyticsModifiedKey = "analytics:modified"
+)
+
+// CachedAnalytics is an analytics result with the validators of its response
+type CachedAnalytics struct {
+	Data         *AnalyticsData `json:"data"`
+	ETag         string         `json:"etag"`
+	LastModified time.Time      `json:"lastModified"`
+}
+
+// AnalyticsCache caches GetAnalytics results in Redis for a short TTL. Writes
+// bump a version counter for their tenant and UTC day, and another for the day
+// across tenants. Writes always land on the current day, so only queries whose
+// range reaches it include the day's counter of their scope in the cache key;
+// a write makes the entries it affects unreachable and they expire on their
+// own, while entries for earlier ranges are kept for the TTL.
+type AnalyticsCache struct {
+	redis *redis.Client
+	ttl   time.Duration
+}
+
+var globalAnalyticsCache *AnalyticsCache
+var analyticsCacheOnce sync.Once
+
+// GetAnalyticsCache returns the singleton analytics cache, creating one that
+// never caches if InitAnalyticsCache has not been called
+func GetAnalyticsCache() *AnalyticsCache {
+	return InitAnalyticsCache(nil)
+}
+
+// InitAnalyticsCache returns the singleton analytics cache, storing results in
+// the Redis server in cfg for cfg.AnalyticsCacheTTL seconds. With a nil cfg, a
+// zero TTL or Redis unavailable, results are not cached and Invalidate does
+// nothing. Settings only apply if it is called before GetAnalyticsCache.
+func InitAnalyticsCache(cfg *config.Config) *AnalyticsCache {
+	analyticsCacheOnce.Do(func() {
+		cache := &AnalyticsCache{}
+		if cfg != nil && cfg.AnalyticsCacheTTL > 0 {
+			cache.redis = GetCacheService(cfg).Client()
+			cache.ttl = time.Duration(cfg.AnalyticsCacheTTL) * time.Second
+		}
+		globalAnalyticsCache = cache
+	})
+	return globalAnalyticsCache
+}
+
+// GetAnalytics returns the cached result of q, or computes it with as and
+// caches it. Redis errors fall back to computing the result.
+func (ac *AnalyticsCache) GetAnalytics(ctx context.Context, as *AnalyticsService, q AnalyticsQuery) (*CachedAnalytics, error) {
+	if ac.redis == nil {
+		return newCachedAnalytics(ctx, as, q, time.Time{})
+	}
+
+	version, lastModified, err := ac.version(ctx, q)
+	if err != nil {
+		metrics.AnalyticsCacheRequests.WithLabelValues("error").Inc()
+		slog.WarnContext(ctx, "Failed to read analytics cache version", logging.ServiceKey, "analytics_cache", "error", err)
+		return newCachedAnalytics(ctx, as, q, time.Time{})
+	}
+	key := "analytics:data:" + version + ":" + hashKey(q.cacheKey())
+
+	if raw, err := ac.redis.Get(ctx, key).Bytes(); err == nil {
+		var cached CachedAnalytics
+		if err := json.Unmarshal(raw, &cached); err == nil {
+			metrics.AnalyticsCacheRequests.WithLabelValues("hit").Inc()
+			return &cached, nil
+		}
+	}
+	metrics.AnalyticsCacheRequests.WithLabelValues("miss").Inc()
+
+	cached, err := newCachedAnalytics(ctx, as, q, lastModified)
+	if err != nil {
+		return nil, err
+	}
+	if raw, err := json.Marshal(cached); err == nil {
+		if err := ac.redis.Set(ctx, key, raw, ac.ttl).Err(); err != nil {
+			slog.WarnContext(ctx, "Failed to cache analytics", logging.ServiceKey, "analytics_cache", "error", err)
+		}
+	}
+	return cached, nil
+}
+
+// version returns the cache version of q's results and when they last
+// changed. Ranges ending before the current UTC day cannot receive writes; the
+// others depend on the day's counter for the query's scope and change when it
+// was last bumped, or at the start of the day if it has not been.
+func (ac *AnalyticsCache) version(ctx context.Context, q AnalyticsQuery) (string, time.Time, error) {
+	today := startOfDay(time.Now())
+	if !q.To.After(today) {
+		return "closed", q.To.UTC().Truncate(time.Second), nil
+	}
+
+	scope := versionScope(q.TenantID, today)
+	values, err := ac.redis.MGet(ctx, analyticsVersionKey+scope, analyticsModifiedKey+scope).Result()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	version := today.Format(time.DateOnly) + ".0"
+	if counter, ok := values[0].(string); ok {
+		version = today.Format(time.DateOnly) + "." + counter
+	}
+	lastModified := today
+	if modified, ok := values[1].(string); ok {
+		if unix, err := strconv.ParseInt(modified, 10, 64); err == nil {
+			lastModified = time.Unix(unix, 0).UTC()
+		}
+	}
+	return version, lastModified, nil
+}
+
+// Invalidate makes the cached results covering a write by tenantID now stale:
+// the tenant's own and those across all tenants that reach the current day
+func (ac *AnalyticsCache) Invalidate(ctx context.Context, tenantID string) {
+	if ac.redis == nil {
+		return
+	}
+
+	now := time.Now()
+	today := startOfDay(now)
+	scopes := []string{versionScope("", today)}
+	if tenantID != "" {
+		scopes = append(scopes, versionScope(tenantID, today))
+	}
+
+	// Counters outlive their day by more than any cache TTL
+	pipe := ac.redis.Pipeline()
+	for _, scope := range scopes {
+		pipe.Incr(ctx, analyticsVersionKey+scope)
+		pipe.Expire(ctx, analyticsVersionKey+scope, 48*time.Hour)
+		pipe.Set(ctx, analyticsModifiedKey+scope, now.Unix(), 48*time.Hour)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		slog.WarnContext(ctx, "Failed to invalidate analytics cache", logging.ServiceKey, "analytics_cache", "error", err)
+	}
+}
+
+// newCachedAnalytics computes the result of q. The ETag hashes the data
+// without its end time, which moves on every request for preset ranges, so
+// it only changes with the figures. A zero lastModified means the compute time.
+func newCachedAnalytics(ctx context.Context, as *AnalyticsService, q AnalyticsQuery, lastModified time.Time) (*CachedAnalytics, error) {
+	data, err := as.GetAnalytics(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	etag, err := analyticsETag(data)
+	if err != nil {
+		return nil, err
+	}
+	if lastModified.IsZero() {
+		lastModified = time.Now().UTC().Truncate(time.Second)
+	}
+	return &CachedAnalytics{
+		Data:         data,
+		ETag:         etag,
+		LastModified: lastModified,
+	}, nil
+}
+
+// analyticsETag returns a strong ETag of data, leaving out its end time
+func analyticsETag(data *AnalyticsData) (string, error) {
+	hashed := *data
+	hashed.To = time.Time{}
+	raw, err := json.Marshal(hashed)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(raw)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`, nil
+}
+
+// versionScope returns the key suffix of the version counter of a tenant, or
+// of all tenants, for writes on the UTC day
+func versionScope(tenantID string, day time.Time) string {
+	scope := ":" + day.Format(time.DateOnly)
+	if tenantID == "" {
+		return scope
+	}
+	return scope + ":" + tenantID
+}
+
+func hashKey(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,149 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestVersionScope(t *testing.T) {
+	day := time.Date(2026, 3, 14, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		tenantID string
+		want     string
+	}{
+		{name: "all tenants", want: ":2026-03-14"},
+		{name: "tenant", tenantID: "acme", want: ":2026-03-14:acme"},
+		{name: "tenant named like a key prefix", tenantID: "modified", want: ":2026-03-14:modified"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := versionScope(tt.tenantID, day); got != tt.want {
+				t.Errorf("versionScope(%q) = %q, want %q", tt.tenantID, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAnalyticsQueryCacheKey(t *testing.T) {
+	from := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2026, 3, 8, 0, 0, 0, 0, time.UTC)
+	base := AnalyticsQuery{From: from, To: to, Granularity: GranularityDay, Location: time.UTC, TenantID: "acme"}
+
+	tests := []struct {
+		name string
+		a, b func(q AnalyticsQuery) AnalyticsQuery
+		same bool
+	}{
+		{
+			name: "identical queries",
+			a:    func(q AnalyticsQuery) AnalyticsQuery { return q },
+			b:    func(q AnalyticsQuery) AnalyticsQuery { return q },
+			same: true,
+		},
+		{
+			name: "preset ranges ignore the moving end",
+			a:    func(q AnalyticsQuery) AnalyticsQuery { q.Preset = "7d"; return q },
+			b:    func(q AnalyticsQuery) AnalyticsQuery { q.Preset = "7d"; q.To = q.To.Add(time.Minute); return q },
+			same: true,
+		},
+		{
+			name: "custom ranges differ by end",
+			a:    func(q AnalyticsQuery) AnalyticsQuery { return q },
+			b:    func(q AnalyticsQuery) AnalyticsQuery { q.To = q.To.Add(time.Hour); return q },
+		},
+		{
+			name: "tenants differ",
+			a:    func(q AnalyticsQuery) AnalyticsQuery { return q },
+			b:    func(q AnalyticsQuery) AnalyticsQuery { q.TenantID = "globex"; return q },
+		},
+		{
+			name: "all tenants differ from one tenant",
+			a:    func(q AnalyticsQuery) AnalyticsQuery { return q },
+			b:    func(q AnalyticsQuery) AnalyticsQuery { q.TenantID = ""; return q },
+		},
+		{
+			name: "granularities differ",
+			a:    func(q AnalyticsQuery) AnalyticsQuery { return q },
+			b:    func(q AnalyticsQuery) AnalyticsQuery { q.Granularity = GranularityHour; return q },
+		},
+		{
+			name: "time zones differ",
+			a:    func(q AnalyticsQuery) AnalyticsQuery { return q },
+			b: func(q AnalyticsQuery) AnalyticsQuery {
+				q.Location = time.FixedZone("UTC+2", 2*60*60)
+				return q
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, b := tt.a(base).cacheKey(), tt.b(base).cacheKey()
+			if (a == b) != tt.same {
+				t.Errorf("cacheKey() = %q and %q, want same = %v", a, b, tt.same)
+			}
+		})
+	}
+}
+
+func TestAnalyticsCacheVersionClosedRange(t *testing.T) {
+	ac := &AnalyticsCache{}
+	today := startOfDay(time.Now())
+	tests := []struct {
+		name string
+		to   time.Time
+	}{
+		{name: "ends at the start of today", to: today},
+		{name: "ends yesterday", to: today.Add(-90 * time.Minute).Add(123 * time.Millisecond)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			version, lastModified, err := ac.version(context.Background(), AnalyticsQuery{To: tt.to})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if version != "closed" {
+				t.Errorf("version = %q, want closed", version)
+			}
+			if want := tt.to.Truncate(time.Second); !lastModified.Equal(want) {
+				t.Errorf("lastModified = %v, want %v", lastModified, want)
+			}
+		})
+	}
+}
+
+func TestAnalyticsETag(t *testing.T) {
+	base := AnalyticsData{TotalImagesProcessed: 10, TotalVoicePlays: 4, To: time.Date(2026, 3, 8, 12, 0, 0, 0, time.UTC)}
+
+	tests := []struct {
+		name   string
+		change func(d *AnalyticsData)
+		same   bool
+	}{
+		{name: "unchanged", change: func(d *AnalyticsData) {}, same: true},
+		{name: "end time moved", change: func(d *AnalyticsData) { d.To = d.To.Add(time.Minute) }, same: true},
+		{name: "figures changed", change: func(d *AnalyticsData) { d.TotalImagesProcessed++ }},
+	}
+
+	want, err := analyticsETag(&base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(want) != 34 || want[0] != '"' || want[len(want)-1] != '"' {
+		t.Fatalf("analyticsETag() = %s, want a quoted 32-digit hex string", want)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := base
+			tt.change(&data)
+			got, err := analyticsETag(&data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (got == want) != tt.same {
+				t.Errorf("analyticsETag() = %s, base %s, want same = %v", got, want, tt.same)
+			}
+		})
+	}
+}
@@ -0,0 +1,549 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"altread-go/api/internal/metrics"
+	"altread-go/api/internal/models"
+)
+
+// Error categories of failed alt text generations
+const (
+	ErrorCategoryValidation          = "validation"
+	ErrorCategoryProviderQuota       = "provider_quota"
+	ErrorCategoryProviderUnavailable = "provider_unavailable"
+	ErrorCategoryTimeout             = "timeout"
+	ErrorCategoryEmptyOutput         = "empty_output"
+	ErrorCategoryOther               = "other"
+)
+
+const (
+	// maxErrorGroups bounds the distinct error messages read from the rollups
+	// and from the event table; the rest are counted as other
+	maxErrorGroups = 500
+	// topErrorMessages is how many normalized messages are listed per category
+	topErrorMessages = 5
+)
+
+// LatencyStats are processing time percentiles in milliseconds
+type LatencyStats struct {
+	P50 float64 `json:"p50"`
+	P90 float64 `json:"p90"`
+	P99 float64 `json:"p99"`
+}
+
+// LatencyPoint is the processing time of the images generated in one time bucket
+type LatencyPoint struct {
+	Date  string  `json:"date"`
+	Count int64   `json:"count"`
+	Avg   float64 `json:"avg"`
+	P50   float64 `json:"p50"`
+	P90   float64 `json:"p90"`
+	P99   float64 `json:"p99"`
+}
+
+// ErrorCategoryStat counts failed generations in one error category
+type ErrorCategoryStat struct {
+	Category    string             `json:"category"`
+	Count       int64              `json:"count"`
+	Percentage  float64            `json:"percentage"`
+	TopMessages []ErrorMessageStat `json:"topMessages"`
+}
+
+// ErrorMessageStat counts one normalized error message
+type ErrorMessageStat struct {
+	Message string `json:"message"`
+	Count   int64  `json:"count"`
+}
+
+// ModelStat is the failure rate and latency of generations by one model, with
+// the requests it answered from cache and as the fallback model
+type ModelStat struct {
+	Model                 string  `json:"model"`
+	Total                 int64   `json:"total"`
+	Failed                int64   `json:"failed"`
+	FailureRate           float64 `json:"failureRate"`
+	AverageProcessingTime float64 `json:"averageProcessingTime"`
+	CacheHits             int64   `json:"cacheHits"`
+	Fallbacks             int64   `json:"fallbacks"`
+	Percentage            float64 `json:"percentage"`
+}
+
+// CacheStats counts requests served from and missing the result cache; the hit
+// ratio is AnalyticsData.CacheHitRatio
+type CacheStats struct {
+	Hits     int64        `json:"hits"`
+	Misses   int64        `json:"misses"`
+	OverTime []CachePoint `json:"overTime"`
+}
+
+// CachePoint counts cache hits and misses in one time bucket
+type CachePoint struct {
+	Date   string `json:"date"`
+	Hits   int64  `json:"hits"`
+	Misses int64  `json:"misses"`
+}
+
+// addQualityStats fills in processing time percentiles, latency over time, the
+// error breakdown, failure rates by model and cache hits over time. Like the
+// totals, rolled-up days are read from the daily rollups and only the remaining
+// spans from image_uploads. Cache hits are left out of latency, error and
+// failure figures. Percentiles are exact for live spans; rolled-up days only
+// keep log-spaced histograms, so percentiles that include them are estimates
+// within about 2.5% of the exact value.
+func (as *AnalyticsService) addQualityStats(ctx context.Context, q AnalyticsQuery, p analyticsPlan, data *AnalyticsData) error {
+	var err error
+	if data.ProcessingTimePercentiles, data.LatencyOverTime, err = as.latencyStats(ctx, q, p); err != nil {
+		return err
+	}
+	if data.ErrorBreakdown, err = as.errorBreakdown(ctx, q, p, data.TotalFailed); err != nil {
+		return err
+	}
+	if data.FailureRateByModel, err = as.failureRateByModel(ctx, q, p); err != nil {
+		return err
+	}
+	if data.CacheStats.OverTime, err = as.cacheOverTime(ctx, q, p); err != nil {
+		return err
+	}
+	return nil
+}
+
+// latencyHistogram counts processing times in metrics latency buckets
+type latencyHistogram struct {
+	counts  []uint64
+	count   int64
+	totalMS int64
+}
+
+func (h *latencyHistogram) add(bucket int, count, totalMS int64) {
+	if h.counts == nil {
+		h.counts = make([]uint64, metrics.LatencyBuckets)
+	}
+	h.counts[bucket] += uint64(count)
+	h.count += count
+	h.totalMS += totalMS
+}
+
+// latencyStats returns the processing time percentiles over the whole range and
+// per bucket, with empty buckets filled with zero. Buckets made up only of live
+// spans get exact percentile_cont values from image_uploads, as does the whole
+// range when no rollups are read. Buckets and ranges that include rolled-up
+// days are estimated from the daily latency histograms.
+func (as *AnalyticsService) latencyStats(ctx context.Context, q AnalyticsQuery, p analyticsPlan) (LatencyStats, []LatencyPoint, error) {
+	var percentiles LatencyStats
+	exact := make(map[int64]LatencyPoint)
+	var first time.Time
+	addBucket := func(bucket time.Time) {
+		if first.IsZero() || bucket.Before(first) {
+			first = bucket
+		}
+	}
+
+	if len(p.live) > 0 {
+		var rows []struct {
+			Bucket time.Time `gorm:"column:bucket"`
+			Count  int64     `gorm:"column:count"`
+			Avg    float64   `gorm:"column:avg"`
+			P50    float64   `gorm:"column:p50"`
+			P90    float64   `gorm:"column:p90"`
+			P99    float64   `gorm:"column:p99"`
+		}
+		err := as.liveQuery(ctx, &models.ImageUpload{}, p.live, q.TenantID).
+			Select(fmt.Sprintf(`date_trunc('%s', created_at AT TIME ZONE ?) AS bucket,
+				COUNT(*) AS count,
+				AVG(processing_time_ms) AS avg,
+				percentile_cont(0.5) WITHIN GROUP (ORDER BY processing_time_ms) AS p50,
+				percentile_cont(0.9) WITHIN GROUP (ORDER BY processing_time_ms) AS p90,
+				percentile_cont(0.99) WITHIN GROUP (ORDER BY processing_time_ms) AS p99`, q.Granularity), q.Location.String()).
+			Where("NOT cache_hit AND processing_time_ms IS NOT NULL").
+			Group("1").
+			Scan(&rows).Error
+		if err != nil {
+			return LatencyStats{}, nil, err
+		}
+		for _, row := range rows {
+			bucket := wallClock(row.Bucket, q.Location)
+			exact[bucket.Unix()] = LatencyPoint{Count: row.Count, Avg: row.Avg, P50: row.P50, P90: row.P90, P99: row.P99}
+			addBucket(bucket)
+		}
+
+		if !p.useRollups() {
+			var stats struct {
+				P50 sql.NullFloat64 `gorm:"column:p50"`
+				P90 sql.NullFloat64 `gorm:"column:p90"`
+				P99 sql.NullFloat64 `gorm:"column:p99"`
+			}
+			err := as.liveQuery(ctx, &models.ImageUpload{}, p.live, q.TenantID).
+				Select(`percentile_cont(0.5) WITHIN GROUP (ORDER BY processing_time_ms) AS p50,
+					percentile_cont(0.9) WITHIN GROUP (ORDER BY processing_time_ms) AS p90,
+					percentile_cont(0.99) WITHIN GROUP (ORDER BY processing_time_ms) AS p99`).
+				Where("NOT cache_hit AND processing_time_ms IS NOT NULL").
+				Scan(&stats).Error
+			if err != nil {
+				return LatencyStats{}, nil, err
+			}
+			percentiles = LatencyStats{P50: stats.P50.Float64, P90: stats.P90.Float64, P99: stats.P99.Float64}
+		}
+	}
+
+	histograms := make(map[int64]*latencyHistogram)
+	if p.useRollups() {
+		type latencyRow struct {
+			Bucket        time.Time `gorm:"column:bucket"`
+			LatencyBucket int       `gorm:"column:latency_bucket"`
+			Count         int64     `gorm:"column:count"`
+			TotalMS       int64     `gorm:"column:total_ms"`
+		}
+		var rows, liveRows []latencyRow
+		err := as.rollupQuery(ctx, &models.DailyLatencyStats{}, p.rollFrom, p.rollTo, q.TenantID).
+			Select(fmt.Sprintf(`date_trunc('%s', date::timestamp) AS bucket, latency_bucket,
+				SUM(request_count) AS count, SUM(total_processing_time_ms) AS total_ms`, q.Granularity)).
+			Group("1, 2").
+			Scan(&rows).Error
+		if err != nil {
+			return LatencyStats{}, nil, err
+		}
+		if len(p.live) > 0 {
+			err := as.liveQuery(ctx, &models.ImageUpload{}, p.live, q.TenantID).
+				Select(fmt.Sprintf(`date_trunc('%s', created_at AT TIME ZONE ?) AS bucket, %s AS latency_bucket,
+					COUNT(*) AS count, SUM(processing_time_ms) AS total_ms`, q.Granularity, metrics.LatencyBucketSQL("processing_time_ms")), q.Location.String()).
+				Where("NOT cache_hit AND processing_time_ms IS NOT NULL").
+				Group("1, 2").
+				Scan(&liveRows).Error
+			if err != nil {
+				return LatencyStats{}, nil, err
+			}
+		}
+
+		// Live rows only join the histogram of a bucket that has rolled-up days;
+		// buckets without any keep their exact values
+		var overall latencyHistogram
+		add := func(row latencyRow, rolledUp bool) {
+			if row.LatencyBucket < 0 || row.LatencyBucket >= metrics.LatencyBuckets {
+				return
+			}
+			overall.add(row.LatencyBucket, row.Count, row.TotalMS)
+			bucket := wallClock(row.Bucket, q.Location)
+			h, ok := histograms[bucket.Unix()]
+			if !ok {
+				if !rolledUp {
+					return
+				}
+				h = &latencyHistogram{}
+				histograms[bucket.Unix()] = h
+			}
+			h.add(row.LatencyBucket, row.Count, row.TotalMS)
+			addBucket(bucket)
+		}
+		for _, row := range rows {
+			add(row, true)
+		}
+		for _, row := range liveRows {
+			add(row, false)
+		}
+		percentiles = LatencyStats{
+			P50: metrics.LatencyQuantile(overall.counts, 0.50),
+			P90: metrics.LatencyQuantile(overall.counts, 0.90),
+			P99: metrics.LatencyQuantile(overall.counts, 0.99),
+		}
+	}
+
+	starts, err := q.seriesStarts(first)
+	if err != nil {
+		return LatencyStats{}, nil, err
+	}
+	series := make([]LatencyPoint, len(starts))
+	for i, start := range starts {
+		point := exact[start.Unix()]
+		if h, ok := histograms[start.Unix()]; ok && h.count > 0 {
+			point = LatencyPoint{
+				Count: h.count,
+				Avg:   float64(h.totalMS) / float64(h.count),
+				P50:   metrics.LatencyQuantile(h.counts, 0.50),
+				P90:   metrics.LatencyQuantile(h.counts, 0.90),
+				P99:   metrics.LatencyQuantile(h.counts, 0.99),
+			}
+		}
+		point.Date = q.formatBucket(start)
+		series[i] = point
+	}
+	return percentiles, series, nil
+}
+
+// errorBreakdown groups failed generations into error categories with their
+// most common normalized messages. Failures served from cache are not counted
+// again.
+func (as *AnalyticsService) errorBreakdown(ctx context.Context, q AnalyticsQuery, p analyticsPlan, totalFailed int64) ([]ErrorCategoryStat, error) {
+	type errorRow struct {
+		Message string `gorm:"column:message"`
+		Count   int64  `gorm:"column:count"`
+	}
+	var rows, liveRows []errorRow
+	if p.useRollups() {
+		err := as.rollupQuery(ctx, &models.DailyErrorStats{}, p.rollFrom, p.rollTo, q.TenantID).
+			Select("message, SUM(error_count) AS count").
+			Group("message").
+			Order("count DESC").
+			Limit(maxErrorGroups).
+			Scan(&rows).Error
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(p.live) > 0 {
+		err := as.liveQuery(ctx, &models.ImageUpload{}, p.live, q.TenantID).
+			Select("COALESCE(LEFT(error_message, 200), '') AS message, COUNT(*) AS count").
+			Where("NOT success AND NOT cache_hit").
+			Group("1").
+			Order("count DESC").
+			Limit(maxErrorGroups).
+			Scan(&liveRows).Error
+		if err != nil {
+			return nil, err
+		}
+		rows = append(rows, liveRows...)
+	}
+
+	categories := make(map[string]*ErrorCategoryStat)
+	messages := make(map[string]map[string]int64)
+	add := func(category, message string, count int64) {
+		stat, ok := categories[category]
+		if !ok {
+			stat = &ErrorCategoryStat{Category: category}
+			categories[category] = stat
+			messages[category] = make(map[string]int64)
+		}
+		stat.Count += count
+		if message != "" {
+			messages[category][message] += count
+		}
+	}
+
+	var listed int64
+	for _, row := range rows {
+		add(categorizeError(row.Message), normalizeErrorMessage(row.Message), row.Count)
+		listed += row.Count
+	}
+	if rest := totalFailed - listed; rest > 0 {
+		add(ErrorCategoryOther, "", rest)
+	}
+
+	breakdown := make([]ErrorCategoryStat, 0, len(categories))
+	for category, stat := range categories {
+		if totalFailed > 0 {
+			stat.Percentage = float64(stat.Count) / float64(totalFailed) * 100
+		}
+		stat.TopMessages = make([]ErrorMessageStat, 0, len(messages[category]))
+		for message, count := range messages[category] {
+			stat.TopMessages = append(stat.TopMessages, ErrorMessageStat{Message: message, Count: count})
+		}
+		sort.Slice(stat.TopMessages, func(i, j int) bool {
+			if stat.TopMessages[i].Count != stat.TopMessages[j].Count {
+				return stat.TopMessages[i].Count > stat.TopMessages[j].Count
+			}
+			return stat.TopMessages[i].Message < stat.TopMessages[j].Message
+		})
+		if len(stat.TopMessages) > topErrorMessages {
+			stat.TopMessages = stat.TopMessages[:topErrorMessages]
+		}
+		breakdown = append(breakdown, *stat)
+	}
+	sort.Slice(breakdown, func(i, j int) bool {
+		if breakdown[i].Count != breakdown[j].Count {
+			return breakdown[i].Count > breakdown[j].Count
+		}
+		return breakdown[i].Category < breakdown[j].Category
+	})
+	return breakdown, nil
+}
+
+// failureRateByModel returns the failure rate and average latency of each model,
+// along with its cache hits, fallbacks and share of requests, most used first
+func (as *AnalyticsService) failureRateByModel(ctx context.Context, q AnalyticsQuery, p analyticsPlan) ([]ModelStat, error) {
+	type modelRow struct {
+		Model     string `gorm:"column:model_used"`
+		Total     int64  `gorm:"column:total"`
+		Failed    int64  `gorm:"column:failed"`
+		TotalMS   int64  `gorm:"column:total_ms"`
+		Samples   int64  `gorm:"column:samples"`
+		CacheHits int64  `gorm:"column:cache_hits"`
+		Fallbacks int64  `gorm:"column:fallbacks"`
+	}
+	var rows, liveRows []modelRow
+	if p.useRollups() {
+		err := as.rollupQuery(ctx, &models.DailyModelStats{}, p.rollFrom, p.rollTo, q.TenantID).
+			Select(`model_used, SUM(total) AS total, SUM(failed) AS failed,
+				SUM(total_processing_time_ms) AS total_ms, SUM(processing_time_samples) AS samples,
+				SUM(cache_hits) AS cache_hits, SUM(fallbacks) AS fallbacks`).
+			Group("model_used").
+			Scan(&rows).Error
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(p.live) > 0 {
+		err := as.liveQuery(ctx, &models.ImageUpload{}, p.live, q.TenantID).
+			Select(`model_used, COUNT(*) FILTER (WHERE NOT cache_hit) AS total,
+				COUNT(*) FILTER (WHERE NOT cache_hit AND NOT success) AS failed,
+				COALESCE(SUM(processing_time_ms) FILTER (WHERE NOT cache_hit), 0) AS total_ms,
+				COUNT(processing_time_ms) FILTER (WHERE NOT cache_hit) AS samples,
+				COUNT(*) FILTER (WHERE cache_hit) AS cache_hits,
+				COUNT(*) FILTER (WHERE fallback_used) AS fallbacks`).
+			Where("model_used IS NOT NULL").
+			Group("model_used").
+			Scan(&liveRows).Error
+		if err != nil {
+			return nil, err
+		}
+		rows = append(rows, liveRows...)
+	}
+
+	merged := make(map[string]*modelRow, len(rows))
+	var requests int64
+	for _, row := range rows {
+		m, ok := merged[row.Model]
+		if !ok {
+			m = &modelRow{Model: row.Model}
+			merged[row.Model] = m
+		}
+		m.Total += row.Total
+		m.Failed += row.Failed
+		m.TotalMS += row.TotalMS
+		m.Samples += row.Samples
+		m.CacheHits += row.CacheHits
+		m.Fallbacks += row.Fallbacks
+		requests += row.Total + row.CacheHits
+	}
+
+	stats := make([]ModelStat, 0, len(merged))
+	for _, m := range merged {
+		stat := ModelStat{
+			Model:     m.Model,
+			Total:     m.Total,
+			Failed:    m.Failed,
+			CacheHits: m.CacheHits,
+			Fallbacks: m.Fallbacks,
+		}
+		if m.Total > 0 {
+			stat.FailureRate = float64(m.Failed) / float64(m.Total) * 100
+		}
+		if m.Samples > 0 {
+			stat.AverageProcessingTime = float64(m.TotalMS) / float64(m.Samples)
+		}
+		if requests > 0 {
+			stat.Percentage = float64(m.Total+m.CacheHits) / float64(requests) * 100
+		}
+		stats = append(stats, stat)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if a, b := stats[i].Total+stats[i].CacheHits, stats[j].Total+stats[j].CacheHits; a != b {
+			return a > b
+		}
+		return stats[i].Model < stats[j].Model
+	})
+	return stats, nil
+}
+
+// cacheOverTime returns cache hits and misses per bucket, with empty buckets
+// filled with zero
+func (as *AnalyticsService) cacheOverTime(ctx context.Context, q AnalyticsQuery, p analyticsPlan) ([]CachePoint, error) {
+	type cacheRow struct {
+		Bucket time.Time `gorm:"column:bucket"`
+		Hits   int64     `gorm:"column:hits"`
+		Misses int64     `gorm:"column:misses"`
+	}
+	var rows, liveRows []cacheRow
+	if p.useRollups() {
+		err := as.rollupQuery(ctx, &models.DailyStats{}, p.rollFrom, p.rollTo, q.TenantID).
+			Select(fmt.Sprintf(`date_trunc('%s', date::timestamp) AS bucket,
+				SUM(cache_hits) AS hits, SUM(total_images) AS misses`, q.Granularity)).
+			Group("1").
+			Scan(&rows).Error
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(p.live) > 0 {
+		err := as.liveQuery(ctx, &models.ImageUpload{}, p.live, q.TenantID).
+			Select(fmt.Sprintf(`date_trunc('%s', created_at AT TIME ZONE ?) AS bucket,
+				COUNT(*) FILTER (WHERE cache_hit) AS hits,
+				COUNT(*) FILTER (WHERE NOT cache_hit) AS misses`, q.Granularity), q.Location.String()).
+			Group("1").
+			Scan(&liveRows).Error
+		if err != nil {
+			return nil, err
+		}
+		rows = append(rows, liveRows...)
+	}
+
+	points := make(map[int64]CachePoint, len(rows))
+	var first time.Time
+	for _, row := range rows {
+		bucket := wallClock(row.Bucket, q.Location)
+		point := points[bucket.Unix()]
+		point.Hits += row.Hits
+		point.Misses += row.Misses
+		points[bucket.Unix()] = point
+		if first.IsZero() || bucket.Before(first) {
+			first = bucket
+		}
+	}
+
+	starts, err := q.seriesStarts(first)
+	if err != nil {
+		return nil, err
+	}
+	series := make([]CachePoint, len(starts))
+	for i, start := range starts {
+		point := points[start.Unix()]
+		point.Date = q.formatBucket(start)
+		series[i] = point
+	}
+	return series, nil
+}
+
+// categorizeError maps a stored error message to an error category
+func categorizeError(message string) string {
+	m := strings.ToLower(message)
+	switch {
+	case containsAny(m, "no image data", "invalid image format", "invalid base64", "image too large", "unsupported image format"):
+		return ErrorCategoryValidation
+	case containsAny(m, "quota", "rate limit", "429", "billing"):
+		return ErrorCategoryProviderQuota
+	case containsAny(m, "timeout", "timed out", "deadline exceeded", "context canceled"):
+		return ErrorCategoryTimeout
+	case containsAny(m, "failed to generate alt text", "no choices in response"):
+		return ErrorCategoryEmptyOutput
+	case containsAny(m, "temporarily unavailable", "api key", "status code: 5", "connection refused", "no such host"):
+		return ErrorCategoryProviderUnavailable
+	default:
+		return ErrorCategoryOther
+	}
+}
+
+var (
+	errorIDPattern     = regexp.MustCompile(`\b(?:req_)?[0-9a-fA-F-]{16,}\b`)
+	errorNumberPattern = regexp.MustCompile(`\b\d+\b`)
+)
+
+// normalizeErrorMessage replaces request IDs and numbers so that otherwise
+// identical messages group together
+func normalizeErrorMessage(message string) string {
+	message = errorIDPattern.ReplaceAllString(message, "<id>")
+	return errorNumberPattern.ReplaceAllString(message, "<n>")
+}
+
+func containsAny(s string, substrs ...string) bool {
+	for _, substr := range substrs {
+		if strings.Contains(s, substr) {
+			return true
+		}
+	}
+	return false
+}
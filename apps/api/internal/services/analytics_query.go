@@ -0,0 +1,202 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Analytics time series granularities
+const (
+	GranularityHour  = "hour"
+	GranularityDay   = "day"
+	GranularityWeek  = "week"
+	GranularityMonth = "month"
+)
+
+// MaxAnalyticsBuckets caps the number of points in an analytics time series
+const MaxAnalyticsBuckets = 2000
+
+// ErrTooManyBuckets is returned when a range holds more than MaxAnalyticsBuckets buckets
+var ErrTooManyBuckets = fmt.Errorf("range spans more than %d buckets", MaxAnalyticsBuckets)
+
+// ValidGranularity reports whether g is a supported time series granularity
+func ValidGranularity(g string) bool {
+	switch g {
+	case GranularityHour, GranularityDay, GranularityWeek, GranularityMonth:
+		return true
+	}
+	return false
+}
+
+// AnalyticsQuery selects the events aggregated by GetAnalytics. Events in
+// [From, To) are counted; a zero From means since the first event. Series are
+// bucketed by Granularity on the wall clock of Location. Preset names the
+// range a query built by NewAnalyticsQuery ends now with.
+type AnalyticsQuery struct {
+	From        time.Time
+	To          time.Time
+	Granularity string
+	Location    *time.Location
+	TenantID    string
+	Preset      string
+}
+
+// NewAnalyticsQuery builds a query for a 7d/30d/90d/all range ending now,
+// starting at midnight in loc
+func NewAnalyticsQuery(timeRange, granularity string, loc *time.Location, tenantID string) AnalyticsQuery {
+	q := AnalyticsQuery{
+		To:          time.Now(),
+		Granularity: granularity,
+		Location:    loc,
+		TenantID:    tenantID,
+		Preset:      timeRange,
+	}
+	if start := timeRangeStart(timeRange); !start.IsZero() {
+		q.From = truncateToBucket(start.In(loc), GranularityDay)
+	}
+	return q
+}
+
+// Validate checks the range and that it fits in MaxAnalyticsBuckets buckets
+func (q AnalyticsQuery) Validate() error {
+	if q.Location == nil {
+		return errors.New("time zone is required")
+	}
+	if !ValidGranularity(q.Granularity) {
+		return fmt.Errorf("unsupported granularity %q", q.Granularity)
+	}
+	if !q.From.IsZero() && !q.From.Before(q.To) {
+		return errors.New("from must be before to")
+	}
+	if !q.From.IsZero() {
+		if _, err := bucketStarts(q.From.In(q.Location), q.To, q.Granularity); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// utc reports whether the query's buckets fall on UTC day boundaries, so
+// daily rollups can stand in for the event tables
+func (q AnalyticsQuery) utc() bool {
+	if q.Granularity == GranularityHour {
+		return false
+	}
+	switch q.Location.String() {
+	case "UTC", "Etc/UTC":
+		return true
+	}
+	return false
+}
+
+// cacheKey identifies the query's results. Preset ranges are keyed by name,
+// since their end moves with every request.
+func (q AnalyticsQuery) cacheKey() string {
+	rangeKey := fmt.Sprintf("%d-%d", q.From.Unix(), q.To.Unix())
+	if q.Preset != "" {
+		rangeKey = q.Preset
+	}
+	return strings.Join([]string{q.TenantID, rangeKey, q.Granularity, q.Location.String()}, "|")
+}
+
+// formatBucket formats a bucket start as a date, or a timestamp for hourly buckets
+func (q AnalyticsQuery) formatBucket(t time.Time) string {
+	if q.Granularity == GranularityHour {
+		return t.Format(time.RFC3339)
+	}
+	return t.Format(time.DateOnly)
+}
+
+// seriesStarts lists the bucket starts of a series over the query range. With
+// no From, the series starts at first, the earliest bucket holding data, and is
+// empty if first is zero.
+func (q AnalyticsQuery) seriesStarts(first time.Time) ([]time.Time, error) {
+	from := q.From
+	if from.IsZero() {
+		if first.IsZero() {
+			return []time.Time{}, nil
+		}
+		from = first
+	}
+	return bucketStarts(from.In(q.Location), q.To, q.Granularity)
+}
+
+// timeSpan is a half-open range of event times; a zero from is unbounded
+type timeSpan struct {
+	from time.Time
+	to   time.Time
+}
+
+// spanCondition returns a SQL condition on column matching any of spans, or
+// false if there are none
+func spanCondition(column string, spans []timeSpan) (string, []interface{}) {
+	if len(spans) == 0 {
+		return "1 = 0", nil
+	}
+
+	var conds []string
+	var args []interface{}
+	for _, span := range spans {
+		if span.from.IsZero() {
+			conds = append(conds, column+" < ?")
+			args = append(args, span.to)
+			continue
+		}
+		conds = append(conds, fmt.Sprintf("(%[1]s >= ? AND %[1]s < ?)", column))
+		args = append(args, span.from, span.to)
+	}
+	return "(" + strings.Join(conds, " OR ") + ")", args
+}
+
+// truncateToBucket returns the start of the bucket containing t, on t's wall clock.
+// Weeks start on Monday, as with Postgres date_trunc.
+func truncateToBucket(t time.Time, granularity string) time.Time {
+	y, m, d := t.Date()
+	loc := t.Location()
+	switch granularity {
+	case GranularityHour:
+		return time.Date(y, m, d, t.Hour(), 0, 0, 0, loc)
+	case GranularityWeek:
+		return time.Date(y, m, d-(int(t.Weekday())+6)%7, 0, 0, 0, 0, loc)
+	case GranularityMonth:
+		return time.Date(y, m, 1, 0, 0, 0, 0, loc)
+	default:
+		return time.Date(y, m, d, 0, 0, 0, 0, loc)
+	}
+}
+
+// nextBucket returns the start of the bucket after the one starting at t
+func nextBucket(t time.Time, granularity string) time.Time {
+	y, m, d := t.Date()
+	loc := t.Location()
+	switch granularity {
+	case GranularityHour:
+		return time.Date(y, m, d, t.Hour()+1, 0, 0, 0, loc)
+	case GranularityWeek:
+		return time.Date(y, m, d+7, 0, 0, 0, 0, loc)
+	case GranularityMonth:
+		return time.Date(y, m+1, 1, 0, 0, 0, 0, loc)
+	default:
+		return time.Date(y, m, d+1, 0, 0, 0, 0, loc)
+	}
+}
+
+// bucketStarts lists the starts of the buckets covering [from, to)
+func bucketStarts(from, to time.Time, granularity string) ([]time.Time, error) {
+	var starts []time.Time
+	for t := truncateToBucket(from, granularity); t.Before(to); t = nextBucket(t, granularity) {
+		if len(starts) == MaxAnalyticsBuckets {
+			return nil, ErrTooManyBuckets
+		}
+		starts = append(starts, t)
+	}
+	return starts, nil
+}
+
+// wallClock reinterprets a timestamp returned by Postgres for a timestamp
+// without time zone as a wall-clock time in loc
+func wallClock(t time.Time, loc *time.Location) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), loc)
+}
@@ -0,0 +1,369 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log/slog"
+	"sync"
+	"time"
+
+	"altread-go/api/internal/config"
+	"altread-go/api/internal/logging"
+	"altread-go/api/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+)
+
+// Analytics update types
+const (
+	AnalyticsUpdateImageProcessed = "image_processed"
+	AnalyticsUpdateVoicePlayed    = "voice_played"
+)
+
+// analyticsUpdatesChannel is the Redis channel updates are fanned out on
+const analyticsUpdatesChannel = "analytics:updates"
+
+// runningTotalsDedupWindow is how far back RunningTotals notes the rows it
+// counted, which must exceed the delay between a write and its update arriving
+const runningTotalsDedupWindow = time.Minute
+
+// AnalyticsUpdate describes one tracked image or voice play as it happens
+type AnalyticsUpdate struct {
+	ID        string               `json:"id"`
+	Type      string               `json:"type"`
+	Timestamp time.Time            `json:"timestamp"`
+	TenantID  string               `json:"tenantId,omitempty"`
+	Image     *ImageProcessedEvent `json:"image,omitempty"`
+	Voice     *VoicePlayedEvent    `json:"voice,omitempty"`
+}
+
+// ImageProcessedEvent is the outcome of one alt text request
+type ImageProcessedEvent struct {
+	Success        bool   `json:"success"`
+	ProcessingTime int    `json:"processingTime"`
+	Model          string `json:"model,omitempty"`
+	CacheHit       bool   `json:"cacheHit"`
+	ErrorCategory  string `json:"errorCategory,omitempty"`
+}
+
+// VoicePlayedEvent is the outcome of one speech request
+type VoicePlayedEvent struct {
+	VoiceName  string `json:"voiceName"`
+	TextLength int    `json:"textLength"`
+	Success    bool   `json:"success"`
+}
+
+// analyticsUpdateMessage is an update as published to Redis. Origin names the
+// publishing bus, so a replica skips its own updates when they come back.
+type analyticsUpdateMessage struct {
+	Origin string          `json:"origin"`
+	Update AnalyticsUpdate `json:"update"`
+}
+
+// RunningTotals are counts since a point in time, kept up to date from the
+// analytics stream
+type RunningTotals struct {
+	Since                time.Time `json:"since"`
+	ImagesProcessed      int64     `json:"imagesProcessed"`
+	SuccessfulImages     int64     `json:"successfulImages"`
+	SuccessRate          float64   `json:"successRate"`
+	VoicePlays           int64     `json:"voicePlays"`
+	SuccessfulVoicePlays int64     `json:"successfulVoicePlays"`
+
+	// IDs of recent rows already counted, which the stream may still deliver
+	counted map[string]struct{}
+}
+
+// Add counts an update and recomputes the success rate. It returns false, and
+// counts nothing, for an update of a row created before Since or already
+// counted when the totals were read.
+func (t *RunningTotals) Add(update AnalyticsUpdate) bool {
+	if update.Timestamp.Before(t.Since) {
+		return false
+	}
+	if _, ok := t.counted[update.ID]; ok {
+		delete(t.counted, update.ID)
+		return false
+	}
+
+	switch {
+	case update.Image != nil:
+		// Cache hits are left out, as in the analytics totals
+		if update.Image.CacheHit {
+			return true
+		}
+		t.ImagesProcessed++
+		if update.Image.Success {
+			t.SuccessfulImages++
+		}
+	case update.Voice != nil:
+		t.VoicePlays++
+		if update.Voice.Success {
+			t.SuccessfulVoicePlays++
+		}
+	}
+	t.updateSuccessRate()
+	return true
+}
+
+func (t *RunningTotals) updateSuccessRate() {
+	t.SuccessRate = 0
+	if t.ImagesProcessed > 0 {
+		t.SuccessRate = float64(t.SuccessfulImages) / float64(t.ImagesProcessed) * 100
+	}
+}
+
+// RunningTotals counts the images and voice plays of a tenant, or of every
+// tenant if tenantID is empty, since the given time. The IDs of the rows
+// created in the last minute are read in the same database snapshot, so that
+// Add skips updates for rows the counts already include.
+func (as *AnalyticsService) RunningTotals(ctx context.Context, tenantID string, since time.Time) (*RunningTotals, error) {
+	spans := []timeSpan{{from: since, to: time.Now().Add(time.Hour)}}
+	recent := []timeSpan{{from: since, to: spans[0].to}}
+	if dedupFrom := time.Now().Add(-runningTotalsDedupWindow); dedupFrom.After(since) {
+		recent[0].from = dedupFrom
+	}
+	totals := &RunningTotals{Since: since, counted: make(map[string]struct{})}
+
+	err := as.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		snapshot := &AnalyticsService{db: tx}
+
+		var images struct {
+			Total      int64
+			Successful int64
+		}
+		err := snapshot.liveQuery(ctx, &models.ImageUpload{}, spans, tenantID).
+			Select("COUNT(*) AS total, COUNT(*) FILTER (WHERE success) AS successful").
+			Where("NOT cache_hit").
+			Scan(&images).Error
+		if err != nil {
+			return err
+		}
+
+		var plays struct {
+			Total      int64
+			Successful int64
+		}
+		err = snapshot.liveQuery(ctx, &models.VoicePlay{}, spans, tenantID).
+			Select("COUNT(*) AS total, COUNT(*) FILTER (WHERE success) AS successful").
+			Scan(&plays).Error
+		if err != nil {
+			return err
+		}
+
+		for _, model := range []interface{}{&models.ImageUpload{}, &models.VoicePlay{}} {
+			var ids []uuid.UUID
+			if err := snapshot.liveQuery(ctx, model, recent, tenantID).Pluck("id", &ids).Error; err != nil {
+				return err
+			}
+			for _, id := range ids {
+				totals.counted[id.String()] = struct{}{}
+			}
+		}
+
+		totals.ImagesProcessed = images.Total
+		totals.SuccessfulImages = images.Successful
+		totals.VoicePlays = plays.Total
+		totals.SuccessfulVoicePlays = plays.Successful
+		return nil
+	}, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+
+	totals.updateSuccessRate()
+	return totals, nil
+}
+
+// AnalyticsBus delivers analytics updates to stream subscribers. With Redis,
+// updates are fanned out so every replica's subscribers see every update;
+// without it, subscribers only see updates tracked by their own replica.
+type AnalyticsBus struct {
+	id     string
+	redis  *redis.Client
+	subsMu sync.RWMutex
+	subs   map[chan AnalyticsUpdate]struct{}
+
+	stopCh   chan struct{}
+	wg       sync.WaitGroup
+	stopOnce sync.Once
+}
+
+var globalAnalyticsBus *AnalyticsBus
+var analyticsBusOnce sync.Once
+
+// GetAnalyticsBus returns the singleton analytics bus, creating a bus without
+// Redis fan-out if InitAnalyticsBus has not been called
+func GetAnalyticsBus() *AnalyticsBus {
+	return InitAnalyticsBus(nil)
+}
+
+// InitAnalyticsBus returns the singleton analytics bus, fanning out updates
+// over the Redis server in cfg. With a nil cfg, or if Redis is unavailable,
+// updates only reach this replica's subscribers. Settings only apply if it is
+// called before GetAnalyticsBus.
+func InitAnalyticsBus(cfg *config.Config) *AnalyticsBus {
+	analyticsBusOnce.Do(func() {
+		bus := &AnalyticsBus{
+			id:     uuid.NewString(),
+			stopCh: make(chan struct{}),
+		}
+		if cfg != nil {
+			bus.redis = GetCacheService(cfg).Client()
+		}
+		if bus.redis != nil {
+			bus.wg.Add(1)
+			go bus.receive()
+		} else {
+			slog.Warn("Analytics updates are not fanned out to other replicas without Redis", logging.ServiceKey, "analytics_stream")
+		}
+		globalAnalyticsBus = bus
+	})
+	return globalAnalyticsBus
+}
+
+// Stop stops receiving updates from other replicas
+func (b *AnalyticsBus) Stop() {
+	b.stopOnce.Do(func() {
+		close(b.stopCh)
+	})
+	b.wg.Wait()
+}
+
+// Subscribe returns a channel receiving updates as they are tracked, and a
+// function to unsubscribe. Updates are dropped for subscribers that fall more
+// than buffer updates behind rather than slowing the tracking path.
+func (b *AnalyticsBus) Subscribe(buffer int) (<-chan AnalyticsUpdate, func()) {
+	ch := make(chan AnalyticsUpdate, buffer)
+
+	b.subsMu.Lock()
+	if b.subs == nil {
+		b.subs = make(map[chan AnalyticsUpdate]struct{})
+	}
+	b.subs[ch] = struct{}{}
+	b.subsMu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			b.subsMu.Lock()
+			delete(b.subs, ch)
+			b.subsMu.Unlock()
+		})
+	}
+}
+
+// Publish delivers an update to this replica's subscribers and, with Redis,
+// to every other replica
+func (b *AnalyticsBus) Publish(ctx context.Context, update AnalyticsUpdate) {
+	b.deliver(update)
+
+	if b.redis == nil {
+		return
+	}
+	payload, err := json.Marshal(analyticsUpdateMessage{Origin: b.id, Update: update})
+	if err != nil {
+		return
+	}
+	if err := b.redis.Publish(ctx, analyticsUpdatesChannel, payload).Err(); err != nil {
+		slog.WarnContext(ctx, "Failed to publish analytics update", logging.ServiceKey, "analytics_stream", "error", err)
+	}
+}
+
+func (b *AnalyticsBus) deliver(update AnalyticsUpdate) {
+	b.subsMu.RLock()
+	defer b.subsMu.RUnlock()
+	for ch := range b.subs {
+		select {
+		case ch <- update:
+		default:
+		}
+	}
+}
+
+// receive delivers updates published by other replicas until Stop is called.
+// The Redis client reconnects and resubscribes on its own after errors.
+func (b *AnalyticsBus) receive() {
+	defer b.wg.Done()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-b.stopCh:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	pubsub := b.redis.Subscribe(ctx, analyticsUpdatesChannel)
+	defer pubsub.Close()
+
+	messages := pubsub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-messages:
+			if !ok {
+				return
+			}
+			var message analyticsUpdateMessage
+			if err := json.Unmarshal([]byte(msg.Payload), &message); err != nil {
+				slog.Warn("Ignoring malformed analytics update", logging.ServiceKey, "analytics_stream", "error", err)
+				continue
+			}
+			if message.Origin == b.id {
+				continue
+			}
+			b.deliver(message.Update)
+		}
+	}
+}
+
+// publishImageProcessed publishes a tracked image upload to the analytics bus
+func publishImageProcessed(ctx context.Context, upload *models.ImageUpload) {
+	update := AnalyticsUpdate{
+		ID:        upload.ID.String(),
+		Type:      AnalyticsUpdateImageProcessed,
+		Timestamp: upload.CreatedAt,
+		Image: &ImageProcessedEvent{
+			Success:  upload.Success,
+			CacheHit: upload.CacheHit,
+		},
+	}
+	if upload.TenantID != nil {
+		update.TenantID = *upload.TenantID
+	}
+	if upload.ProcessingTimeMS != nil {
+		update.Image.ProcessingTime = *upload.ProcessingTimeMS
+	}
+	if upload.ModelUsed != nil {
+		update.Image.Model = *upload.ModelUsed
+	}
+	if !upload.Success && upload.ErrorMessage != nil {
+		update.Image.ErrorCategory = categorizeError(*upload.ErrorMessage)
+	}
+	GetAnalyticsBus().Publish(ctx, update)
+}
+
+// publishVoicePlayed publishes a tracked voice play to the analytics bus
+func publishVoicePlayed(ctx context.Context, play *models.VoicePlay) {
+	update := AnalyticsUpdate{
+		ID:        play.ID.String(),
+		Type:      AnalyticsUpdateVoicePlayed,
+		Timestamp: play.CreatedAt,
+		Voice: &VoicePlayedEvent{
+			VoiceName:  play.VoiceName,
+			TextLength: play.TextLength,
+			Success:    play.Success,
+		},
+	}
+	if play.TenantID != nil {
+		update.TenantID = *play.TenantID
+	}
+	GetAnalyticsBus().Publish(ctx, update)
+}
@@ -0,0 +1,179 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+
+	"altread-go/api/internal/models"
+)
+
+// textLengthEdges are the lower bounds of the text length histogram buckets
+// after the first, which starts at zero
+var textLengthEdges = []int{100, 250, 500, 1000, 2000}
+
+// VoiceBreakdownStat counts the speech requests made with one TTS model or
+// response format
+type VoiceBreakdownStat struct {
+	Name                 string  `json:"name"`
+	Count                int64   `json:"count"`
+	Failed               int64   `json:"failed"`
+	FailureRate          float64 `json:"failureRate"`
+	Percentage           float64 `json:"percentage"`
+	AverageSynthesisTime float64 `json:"averageSynthesisTime"`
+	AverageAudioBytes    float64 `json:"averageAudioBytes"`
+}
+
+// TextLengthBucket counts the speech requests whose text length falls in
+// [Min, Max]; Max is nil for the last bucket
+type TextLengthBucket struct {
+	Label  string `json:"label"`
+	Min    int    `json:"min"`
+	Max    *int   `json:"max"`
+	Count  int64  `json:"count"`
+	Failed int64  `json:"failed"`
+}
+
+// addVoiceStats fills in failure rates and synthesis times per voice, the
+// breakdowns by TTS model and format and the text length histogram. Failed
+// requests are not rolled up, so these always read voice_plays over the
+// whole range.
+func (as *AnalyticsService) addVoiceStats(ctx context.Context, q AnalyticsQuery, data *AnalyticsData) error {
+	spans := []timeSpan{{from: q.From, to: q.To}}
+
+	var voices []struct {
+		VoiceName string          `gorm:"column:voice_name"`
+		Total     int64           `gorm:"column:total"`
+		Failed    int64           `gorm:"column:failed"`
+		Avg       sql.NullFloat64 `gorm:"column:avg"`
+	}
+	err := as.liveQuery(ctx, &models.VoicePlay{}, spans, q.TenantID).
+		Select("voice_name, COUNT(*) AS total, COUNT(*) FILTER (WHERE NOT success) AS failed, AVG(duration_ms) FILTER (WHERE success) AS avg").
+		Group("voice_name").
+		Scan(&voices).Error
+	if err != nil {
+		return err
+	}
+	for i := range data.VoiceUsage {
+		usage := &data.VoiceUsage[i]
+		for _, voice := range voices {
+			if voice.VoiceName != usage.VoiceName {
+				continue
+			}
+			usage.Failed = voice.Failed
+			if voice.Total > 0 {
+				usage.FailureRate = float64(voice.Failed) / float64(voice.Total) * 100
+			}
+			usage.AverageSynthesisTime = voice.Avg.Float64
+		}
+	}
+
+	if data.VoiceModelBreakdown, err = as.voiceBreakdown(ctx, q, spans, "model_used"); err != nil {
+		return err
+	}
+	if data.VoiceFormatBreakdown, err = as.voiceBreakdown(ctx, q, spans, "response_format"); err != nil {
+		return err
+	}
+	if data.TextLengthHistogram, err = as.textLengthHistogram(ctx, q, spans); err != nil {
+		return err
+	}
+	return nil
+}
+
+// voiceBreakdown groups speech requests by a voice_plays column, most used
+// first. Requests tracked before the column was recorded are left out.
+func (as *AnalyticsService) voiceBreakdown(ctx context.Context, q AnalyticsQuery, spans []timeSpan, column string) ([]VoiceBreakdownStat, error) {
+	var rows []struct {
+		Name       string          `gorm:"column:name"`
+		Total      int64           `gorm:"column:total"`
+		Failed     int64           `gorm:"column:failed"`
+		AvgLatency sql.NullFloat64 `gorm:"column:avg_latency"`
+		AvgBytes   sql.NullFloat64 `gorm:"column:avg_bytes"`
+	}
+	err := as.liveQuery(ctx, &models.VoicePlay{}, spans, q.TenantID).
+		Select(fmt.Sprintf(`%s AS name, COUNT(*) AS total,
+			COUNT(*) FILTER (WHERE NOT success) AS failed,
+			AVG(duration_ms) FILTER (WHERE success) AS avg_latency,
+			AVG(audio_bytes) FILTER (WHERE success) AS avg_bytes`, column)).
+		Where(column + " IS NOT NULL").
+		Group(column).
+		Order("total DESC").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var total int64
+	for _, row := range rows {
+		total += row.Total
+	}
+	stats := make([]VoiceBreakdownStat, len(rows))
+	for i, row := range rows {
+		stats[i] = VoiceBreakdownStat{
+			Name:                 row.Name,
+			Count:                row.Total,
+			Failed:               row.Failed,
+			AverageSynthesisTime: row.AvgLatency.Float64,
+			AverageAudioBytes:    row.AvgBytes.Float64,
+		}
+		if row.Total > 0 {
+			stats[i].FailureRate = float64(row.Failed) / float64(row.Total) * 100
+		}
+		if total > 0 {
+			stats[i].Percentage = float64(row.Total) / float64(total) * 100
+		}
+	}
+	return stats, nil
+}
+
+// textLengthHistogram counts speech requests by text length, with every
+// bucket present
+func (as *AnalyticsService) textLengthHistogram(ctx context.Context, q AnalyticsQuery, spans []timeSpan) ([]TextLengthBucket, error) {
+	var rows []struct {
+		Bucket int   `gorm:"column:bucket"`
+		Total  int64 `gorm:"column:total"`
+		Failed int64 `gorm:"column:failed"`
+	}
+	err := as.liveQuery(ctx, &models.VoicePlay{}, spans, q.TenantID).
+		Select("width_bucket(text_length, ?::int[]) AS bucket, COUNT(*) AS total, COUNT(*) FILTER (WHERE NOT success) AS failed", pgIntArray(textLengthEdges)).
+		Group("1").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	histogram := make([]TextLengthBucket, len(textLengthEdges)+1)
+	for i := range histogram {
+		bucket := &histogram[i]
+		if i > 0 {
+			bucket.Min = textLengthEdges[i-1]
+		}
+		if i < len(textLengthEdges) {
+			max := textLengthEdges[i] - 1
+			bucket.Max = &max
+			bucket.Label = fmt.Sprintf("%d-%d", bucket.Min, max)
+		} else {
+			bucket.Label = strconv.Itoa(bucket.Min) + "+"
+		}
+	}
+	for _, row := range rows {
+		if row.Bucket >= 0 && row.Bucket < len(histogram) {
+			histogram[row.Bucket].Count += row.Total
+			histogram[row.Bucket].Failed += row.Failed
+		}
+	}
+	return histogram, nil
+}
+
+// pgIntArray formats ints as a Postgres array literal
+func pgIntArray(values []int) string {
+	literal := "{"
+	for i, v := range values {
+		if i > 0 {
+			literal += ","
+		}
+		literal += strconv.Itoa(v)
+	}
+	return literal + "}"
+}
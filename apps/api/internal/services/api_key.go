@@ -0,0 +1,188 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"altread-go/api/internal/constants"
+	"altread-go/api/internal/database"
+	"altread-go/api/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	apiKeyPrefix    = "ak_"
+	apiKeyCacheTTL  = time.Minute
+	apiKeyPrefixLen = 8
+)
+
+// ErrInvalidAPIKey is returned when a key is unknown, revoked or expired
+var ErrInvalidAPIKey = errors.New("invalid API key")
+
+// APIKeyService handles issuing and verifying hashed API keys
+type APIKeyService struct {
+	db    *gorm.DB
+	cache map[string]*cachedAPIKey
+	mu    sync.RWMutex
+}
+
+type cachedAPIKey struct {
+	key       *models.APIKey
+	expiresAt time.Time
+}
+
+// NewAPIKeyService creates a new API key service instance
+func NewAPIKeyService() *APIKeyService {
+	return &APIKeyService{
+		db:    database.DB,
+		cache: make(map[string]*cachedAPIKey),
+	}
+}
+
+// IsAPIKey reports whether the credential looks like a key issued by this service
+func IsAPIKey(credential string) bool {
+	return strings.HasPrefix(credential, apiKeyPrefix)
+}
+
+// CreateAPIKey issues a new key for the tenant and returns the raw key, which is never stored
+func (s *APIKeyService) CreateAPIKey(ctx context.Context, name, tenantID string, scopes []string, expiresAt *time.Time) (string, *models.APIKey, error) {
+	if name == "" || tenantID == "" {
+		return "", nil, fmt.Errorf("name and tenant are required")
+	}
+	if len(scopes) == 0 {
+		return "", nil, fmt.Errorf("at least one scope is required")
+	}
+	for _, scope := range scopes {
+		if !isValidScope(scope) {
+			return "", nil, fmt.Errorf("unknown scope %q. Valid scopes: %s", scope, strings.Join(constants.APIScopeList, ", "))
+		}
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", nil, fmt.Errorf("failed to generate key: %w", err)
+	}
+	rawKey := apiKeyPrefix + base64.RawURLEncoding.EncodeToString(secret)
+
+	key := &models.APIKey{
+		ID:        uuid.New(),
+		Name:      name,
+		TenantID:  tenantID,
+		KeyPrefix: rawKey[:len(apiKeyPrefix)+apiKeyPrefixLen],
+		KeyHash:   hashAPIKey(rawKey),
+		Scopes:    strings.Join(scopes, " "),
+		ExpiresAt: expiresAt,
+		CreatedAt: time.Now(),
+	}
+
+	if err := s.db.WithContext(ctx).Create(key).Error; err != nil {
+		return "", nil, err
+	}
+
+	return rawKey, key, nil
+}
+
+// Authenticate resolves a raw key to its stored record
+func (s *APIKeyService) Authenticate(ctx context.Context, rawKey string) (*models.APIKey, error) {
+	if !IsAPIKey(rawKey) {
+		return nil, ErrInvalidAPIKey
+	}
+
+	hash := hashAPIKey(rawKey)
+	now := time.Now()
+
+	s.mu.RLock()
+	cached, ok := s.cache[hash]
+	s.mu.RUnlock()
+	if ok && now.Before(cached.expiresAt) {
+		if !isKeyActive(cached.key, now) {
+			return nil, ErrInvalidAPIKey
+		}
+		return cached.key, nil
+	}
+
+	var key models.APIKey
+	err := s.db.WithContext(ctx).Where("key_hash = ?", hash).First(&key).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrInvalidAPIKey
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	s.cache[hash] = &cachedAPIKey{key: &key, expiresAt: now.Add(apiKeyCacheTTL)}
+	s.mu.Unlock()
+
+	if !isKeyActive(&key, now) {
+		return nil, ErrInvalidAPIKey
+	}
+
+	// last_used_at is refreshed at most once per cache period
+	go s.db.WithContext(context.Background()).Model(&models.APIKey{}).
+		Where("id = ?", key.ID).
+		Update("last_used_at", now)
+
+	return &key, nil
+}
+
+// RevokeAPIKey marks a key as revoked; cached lookups expire within a minute
+func (s *APIKeyService) RevokeAPIKey(ctx context.Context, id uuid.UUID) error {
+	result := s.db.WithContext(ctx).Model(&models.APIKey{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("API key %s not found or already revoked", id)
+	}
+	return nil
+}
+
+// ListAPIKeys returns the keys issued to a tenant, or all keys if tenantID is empty
+func (s *APIKeyService) ListAPIKeys(ctx context.Context, tenantID string) ([]models.APIKey, error) {
+	query := s.db.WithContext(ctx).Order("created_at DESC")
+	if tenantID != "" {
+		query = query.Where("tenant_id = ?", tenantID)
+	}
+
+	var keys []models.APIKey
+	if err := query.Find(&keys).Error; err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+func hashAPIKey(rawKey string) string {
+	hash := sha256.Sum256([]byte(rawKey))
+	return fmt.Sprintf("%x", hash)
+}
+
+func isKeyActive(key *models.APIKey, now time.Time) bool {
+	if key.RevokedAt != nil {
+		return false
+	}
+	if key.ExpiresAt != nil && !now.Before(*key.ExpiresAt) {
+		return false
+	}
+	return true
+}
+
+func isValidScope(scope string) bool {
+	for _, s := range constants.APIScopeList {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
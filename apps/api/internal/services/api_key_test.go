@@ -0,0 +1,107 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"altread-go/api/internal/models"
+
+	"github.com/google/uuid"
+)
+
+func TestHashAPIKey(t *testing.T) {
+	tests := []struct {
+		key  string
+		want string
+	}{
+		{key: "abc", want: "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"},
+		{key: "", want: "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"},
+	}
+	for _, tt := range tests {
+		if got := hashAPIKey(tt.key); got != tt.want {
+			t.Errorf("hashAPIKey(%q) = %s, want %s", tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestIsAPIKey(t *testing.T) {
+	tests := []struct {
+		credential string
+		want       bool
+	}{
+		{credential: "ak_abcdefgh", want: true},
+		{credential: "ak_", want: true},
+		{credential: "AK_abcdefgh", want: false},
+		{credential: "eyJhbGciOiJSUzI1NiJ9.e30.sig", want: false},
+		{credential: "", want: false},
+	}
+	for _, tt := range tests {
+		if got := IsAPIKey(tt.credential); got != tt.want {
+			t.Errorf("IsAPIKey(%q) = %v, want %v", tt.credential, got, tt.want)
+		}
+	}
+}
+
+func TestIsKeyActive(t *testing.T) {
+	now := time.Date(2026, 3, 8, 12, 0, 0, 0, time.UTC)
+	past, future := now.Add(-time.Hour), now.Add(time.Hour)
+
+	tests := []struct {
+		name string
+		key  models.APIKey
+		want bool
+	}{
+		{name: "no expiry", key: models.APIKey{}, want: true},
+		{name: "expires later", key: models.APIKey{ExpiresAt: &future}, want: true},
+		{name: "expired", key: models.APIKey{ExpiresAt: &past}, want: false},
+		{name: "expires now", key: models.APIKey{ExpiresAt: &now}, want: false},
+		{name: "revoked", key: models.APIKey{RevokedAt: &past}, want: false},
+		{name: "revoked before expiry", key: models.APIKey{ExpiresAt: &future, RevokedAt: &past}, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isKeyActive(&tt.key, now); got != tt.want {
+				t.Errorf("isKeyActive() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAPIKeyAuthenticateCached(t *testing.T) {
+	past := time.Now().Add(-time.Hour)
+	active := &models.APIKey{ID: uuid.New(), TenantID: "acme", Scopes: "alt-text:write"}
+	revoked := &models.APIKey{ID: uuid.New(), TenantID: "acme", RevokedAt: &past}
+	expired := &models.APIKey{ID: uuid.New(), TenantID: "acme", ExpiresAt: &past}
+
+	// Cached lookups never reach the database, which is left nil
+	s := &APIKeyService{cache: map[string]*cachedAPIKey{}}
+	for raw, key := range map[string]*models.APIKey{"ak_active": active, "ak_revoked": revoked, "ak_expired": expired} {
+		s.cache[hashAPIKey(raw)] = &cachedAPIKey{key: key, expiresAt: time.Now().Add(time.Minute)}
+	}
+
+	tests := []struct {
+		name    string
+		raw     string
+		want    *models.APIKey
+		wantErr error
+	}{
+		{name: "active", raw: "ak_active", want: active},
+		{name: "revoked", raw: "ak_revoked", wantErr: ErrInvalidAPIKey},
+		{name: "expired", raw: "ak_expired", wantErr: ErrInvalidAPIKey},
+		{name: "not an API key", raw: "active", wantErr: ErrInvalidAPIKey},
+		{name: "hash of a key is not a key", raw: hashAPIKey("ak_active"), wantErr: ErrInvalidAPIKey},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := s.Authenticate(context.Background(), tt.raw)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Authenticate() error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Authenticate() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
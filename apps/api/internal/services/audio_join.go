@@ -0,0 +1,710 @@
+package services
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// joinSpeechAudio joins audio files synthesized from consecutive chunks of a
+// text into one file of the same format. Formats whose files are a plain
+// sequence of self-delimiting frames (aac as ADTS, raw pcm) are concatenated;
+// the others are joined at the frame or container level.
+func joinSpeechAudio(format string, parts [][]byte) ([]byte, error) {
+	if len(parts) == 1 {
+		return parts[0], nil
+	}
+
+	switch format {
+	case "mp3":
+		return joinMP3(parts)
+	case "wav":
+		return joinWAV(parts)
+	case "flac":
+		return joinFLAC(parts)
+	case "opus":
+		return joinOpus(parts)
+	case "aac", "pcm":
+		return bytes.Join(parts, nil), nil
+	default:
+		return nil, fmt.Errorf("joining %s audio is not supported", format)
+	}
+}
+
+// MPEG-1, MPEG-2 and MPEG-2.5 Layer III bitrates in kbit/s and sample rates in Hz
+var (
+	mp3BitratesV1 = [16]int{0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 0}
+	mp3BitratesV2 = [16]int{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160, 0}
+	mp3SampleRate = map[byte][3]int{
+		3: {44100, 48000, 32000}, // MPEG-1
+		2: {22050, 24000, 16000}, // MPEG-2
+		0: {11025, 12000, 8000},  // MPEG-2.5
+	}
+)
+
+// joinMP3 concatenates the MPEG audio frames of each part. ID3 tags are
+// dropped, as are Xing/Info/VBRI header frames, whose frame counts and seek
+// tables only describe their own part.
+func joinMP3(parts [][]byte) ([]byte, error) {
+	var out bytes.Buffer
+	sampleRate := 0
+	for i, part := range parts {
+		frames, rate := mp3Frames(part)
+		if len(frames) == 0 {
+			return nil, fmt.Errorf("mp3 part %d has no audio frames", i)
+		}
+		if sampleRate == 0 {
+			sampleRate = rate
+		} else if rate != sampleRate {
+			return nil, fmt.Errorf("mp3 part %d has sample rate %d, want %d", i, rate, sampleRate)
+		}
+
+		if isMP3InfoFrame(frames[0]) {
+			frames = frames[1:]
+		}
+		for _, frame := range frames {
+			out.Write(frame)
+		}
+	}
+	return out.Bytes(), nil
+}
+
+// mp3Frames returns the Layer III frames of an MP3 file and their sample rate.
+// Bytes that are not part of a valid frame, such as tags, are skipped.
+func mp3Frames(data []byte) ([][]byte, int) {
+	pos := 0
+	if len(data) >= 10 && string(data[:3]) == "ID3" {
+		size := int(data[6]&0x7f)<<21 | int(data[7]&0x7f)<<14 | int(data[8]&0x7f)<<7 | int(data[9]&0x7f)
+		pos = 10 + size
+		if data[5]&0x10 != 0 {
+			pos += 10
+		}
+	}
+
+	var frames [][]byte
+	sampleRate := 0
+	for pos+4 <= len(data) {
+		length, rate := mp3FrameHeader(data[pos:])
+		if length == 0 || pos+length > len(data) {
+			pos++
+			continue
+		}
+		frames = append(frames, data[pos:pos+length])
+		if sampleRate == 0 {
+			sampleRate = rate
+		}
+		pos += length
+	}
+	return frames, sampleRate
+}
+
+// mp3FrameHeader returns the length and sample rate of the Layer III frame
+// starting at b, or zero if b does not start with a valid frame header
+func mp3FrameHeader(b []byte) (int, int) {
+	if b[0] != 0xff || b[1]&0xe0 != 0xe0 {
+		return 0, 0
+	}
+	version := (b[1] >> 3) & 0x03
+	layer := (b[1] >> 1) & 0x03
+	bitrateIndex := b[2] >> 4
+	rateIndex := (b[2] >> 2) & 0x03
+	padding := int((b[2] >> 1) & 0x01)
+	rates, ok := mp3SampleRate[version]
+	if !ok || layer != 1 || rateIndex == 3 {
+		return 0, 0
+	}
+
+	bitrate := mp3BitratesV2[bitrateIndex]
+	coefficient := 72000
+	if version == 3 {
+		bitrate = mp3BitratesV1[bitrateIndex]
+		coefficient = 144000
+	}
+	if bitrate == 0 {
+		return 0, 0
+	}
+	rate := rates[rateIndex]
+	return coefficient*bitrate/rate + padding, rate
+}
+
+// isMP3InfoFrame reports whether a frame carries a Xing, Info or VBRI header
+// instead of audio
+func isMP3InfoFrame(frame []byte) bool {
+	head := frame[:min(len(frame), 64)]
+	return bytes.Contains(head, []byte("Xing")) || bytes.Contains(head, []byte("Info")) || bytes.Contains(head, []byte("VBRI"))
+}
+
+// joinWAV joins the sample data of RIFF/WAVE files with identical formats
+// under a single header
+func joinWAV(parts [][]byte) ([]byte, error) {
+	var format []byte
+	var samples [][]byte
+	total := 0
+	for i, part := range parts {
+		fmtChunk, data, err := parseWAV(part)
+		if err != nil {
+			return nil, fmt.Errorf("wav part %d: %w", i, err)
+		}
+		if format == nil {
+			format = fmtChunk
+		} else if !bytes.Equal(fmtChunk, format) {
+			return nil, fmt.Errorf("wav part %d has a different sample format", i)
+		}
+		samples = append(samples, data)
+		total += len(data)
+	}
+
+	riffSize := uint64(4+8+len(format)+len(format)%2+8) + uint64(total) + uint64(total%2)
+	if riffSize > math.MaxUint32 {
+		return nil, errors.New("joined wav is too large")
+	}
+
+	out := bytes.NewBuffer(make([]byte, 0, int(riffSize)+8))
+	out.WriteString("RIFF")
+	binary.Write(out, binary.LittleEndian, uint32(riffSize))
+	out.WriteString("WAVEfmt ")
+	binary.Write(out, binary.LittleEndian, uint32(len(format)))
+	out.Write(format)
+	if len(format)%2 == 1 {
+		out.WriteByte(0)
+	}
+	out.WriteString("data")
+	binary.Write(out, binary.LittleEndian, uint32(total))
+	for _, data := range samples {
+		out.Write(data)
+	}
+	if total%2 == 1 {
+		out.WriteByte(0)
+	}
+	return out.Bytes(), nil
+}
+
+// parseWAV returns the fmt chunk and sample data of a RIFF/WAVE file. A data
+// chunk with an unknown or overlong size, as written by streaming encoders,
+// runs to the end of the file.
+func parseWAV(data []byte) ([]byte, []byte, error) {
+	if len(data) < 12 || string(data[:4]) != "RIFF" || string(data[8:12]) != "WAVE" {
+		return nil, nil, errors.New("not a RIFF/WAVE file")
+	}
+
+	var format []byte
+	for pos := 12; pos+8 <= len(data); {
+		id := string(data[pos : pos+4])
+		size := binary.LittleEndian.Uint32(data[pos+4 : pos+8])
+		body := pos + 8
+		end := uint64(body) + uint64(size)
+		if id == "data" {
+			if format == nil {
+				return nil, nil, errors.New("data chunk before fmt chunk")
+			}
+			if size == 0 || size == math.MaxUint32 || end > uint64(len(data)) {
+				end = uint64(len(data))
+			}
+			return format, data[body:end], nil
+		}
+		if end > uint64(len(data)) {
+			break
+		}
+		if id == "fmt " {
+			format = data[body:end]
+		}
+		pos = int(end) + int(size%2)
+	}
+	return nil, nil, errors.New("no data chunk")
+}
+
+// flacStreamInfo holds the STREAMINFO sample format the parts of a joined
+// stream must share
+type flacStreamInfo struct {
+	sampleRate    int
+	channels      int
+	bitsPerSample int
+}
+
+// joinFLAC joins FLAC streams with identical sample formats. The joined stream
+// has a single STREAMINFO block and uses the variable blocking strategy, since
+// each part ends with a short frame; every frame header is rewritten with its
+// position in the joined stream and both frame checksums are recomputed.
+func joinFLAC(parts [][]byte) ([]byte, error) {
+	var info *flacStreamInfo
+	var frames bytes.Buffer
+	var sample uint64
+	minBlock, maxBlock := math.MaxInt, 0
+
+	for i, part := range parts {
+		partInfo, audio, err := parseFLAC(part)
+		if err != nil {
+			return nil, fmt.Errorf("flac part %d: %w", i, err)
+		}
+		if info == nil {
+			info = partInfo
+		} else if partInfo.sampleRate != info.sampleRate || partInfo.channels != info.channels || partInfo.bitsPerSample != info.bitsPerSample {
+			return nil, fmt.Errorf("flac part %d has a different sample format", i)
+		}
+
+		for len(audio) > 0 {
+			frame, blockSize, err := nextFLACFrame(audio)
+			if err != nil {
+				return nil, fmt.Errorf("flac part %d: %w", i, err)
+			}
+			audio = audio[len(frame):]
+			frames.Write(renumberFLACFrame(frame, sample))
+			sample += uint64(blockSize)
+			minBlock = min(minBlock, blockSize)
+			maxBlock = max(maxBlock, blockSize)
+		}
+	}
+	if maxBlock == 0 {
+		return nil, errors.New("flac parts have no audio frames")
+	}
+
+	// STREAMINFO with unknown frame sizes and MD5 signature
+	streamInfo := make([]byte, 34)
+	binary.BigEndian.PutUint16(streamInfo[0:2], uint16(minBlock))
+	binary.BigEndian.PutUint16(streamInfo[2:4], uint16(maxBlock))
+	packed := uint64(info.sampleRate)<<44 | uint64(info.channels-1)<<41 | uint64(info.bitsPerSample-1)<<36 | sample&(1<<36-1)
+	binary.BigEndian.PutUint64(streamInfo[10:18], packed)
+
+	out := bytes.NewBuffer(make([]byte, 0, 42+frames.Len()))
+	out.WriteString("fLaC")
+	out.Write([]byte{0x80, 0, 0, 34}) // last metadata block, STREAMINFO, 34 bytes
+	out.Write(streamInfo)
+	out.Write(frames.Bytes())
+	return out.Bytes(), nil
+}
+
+// parseFLAC returns the stream info of a FLAC file and the frames after its
+// metadata blocks
+func parseFLAC(data []byte) (*flacStreamInfo, []byte, error) {
+	if len(data) < 8 || string(data[:4]) != "fLaC" {
+		return nil, nil, errors.New("not a FLAC file")
+	}
+
+	var info *flacStreamInfo
+	pos := 4
+	for {
+		if pos+4 > len(data) {
+			return nil, nil, errors.New("truncated metadata")
+		}
+		last := data[pos]&0x80 != 0
+		blockType := data[pos] & 0x7f
+		size := int(data[pos+1])<<16 | int(data[pos+2])<<8 | int(data[pos+3])
+		body := pos + 4
+		if body+size > len(data) {
+			return nil, nil, errors.New("truncated metadata")
+		}
+		if blockType == 0 && size >= 18 {
+			b := data[body:]
+			packed := binary.BigEndian.Uint64(b[10:18])
+			info = &flacStreamInfo{
+				sampleRate:    int(packed >> 44),
+				channels:      int(packed>>41&0x07) + 1,
+				bitsPerSample: int(packed>>36&0x1f) + 1,
+			}
+		}
+		pos = body + size
+		if last {
+			break
+		}
+	}
+	if info == nil {
+		return nil, nil, errors.New("no STREAMINFO block")
+	}
+	return info, data[pos:], nil
+}
+
+// nextFLACFrame returns the frame at the start of audio and its block size.
+// A frame ends where the next valid frame header begins and the frame's
+// CRC-16 checks out.
+func nextFLACFrame(audio []byte) ([]byte, int, error) {
+	headerLen, blockSize, ok := parseFLACFrameHeader(audio)
+	if !ok {
+		return nil, 0, errors.New("invalid frame header")
+	}
+
+	var crc uint16
+	for i := 0; i < len(audio); i++ {
+		if i > headerLen && crc == 0 && audio[i] == 0xff && i+1 < len(audio) && audio[i+1]&0xfe == 0xf8 {
+			if _, _, ok := parseFLACFrameHeader(audio[i:]); ok {
+				return audio[:i], blockSize, nil
+			}
+		}
+		crc = crc16FLAC(crc, audio[i])
+	}
+	if crc != 0 {
+		return nil, 0, errors.New("truncated frame")
+	}
+	return audio, blockSize, nil
+}
+
+// parseFLACFrameHeader returns the length and block size of the frame header
+// at the start of b, checking its CRC-8
+func parseFLACFrameHeader(b []byte) (int, int, bool) {
+	if len(b) < 6 || b[0] != 0xff || b[1]&0xfe != 0xf8 {
+		return 0, 0, false
+	}
+	blockCode := b[2] >> 4
+	rateCode := b[2] & 0x0f
+	if blockCode == 0 || rateCode == 0x0f || b[3]&0x01 != 0 || b[3]>>4 > 10 {
+		return 0, 0, false
+	}
+
+	_, n := decodeFLACNumber(b[4:])
+	if n == 0 {
+		return 0, 0, false
+	}
+	pos := 4 + n
+
+	var blockSize int
+	switch {
+	case blockCode == 1:
+		blockSize = 192
+	case blockCode <= 5:
+		blockSize = 576 << (blockCode - 2)
+	case blockCode == 6:
+		if pos+1 > len(b) {
+			return 0, 0, false
+		}
+		blockSize = int(b[pos]) + 1
+		pos++
+	case blockCode == 7:
+		if pos+2 > len(b) {
+			return 0, 0, false
+		}
+		blockSize = int(binary.BigEndian.Uint16(b[pos:])) + 1
+		pos += 2
+	default:
+		blockSize = 256 << (blockCode - 8)
+	}
+
+	switch rateCode {
+	case 12:
+		pos++
+	case 13, 14:
+		pos += 2
+	}
+	if pos+1 > len(b) {
+		return 0, 0, false
+	}
+
+	var crc byte
+	for _, c := range b[:pos] {
+		crc = crc8FLAC(crc, c)
+	}
+	if crc != b[pos] {
+		return 0, 0, false
+	}
+	return pos + 1, blockSize, true
+}
+
+// renumberFLACFrame rewrites a frame for the variable blocking strategy,
+// starting at the given sample, and recomputes its checksums
+func renumberFLACFrame(frame []byte, sample uint64) []byte {
+	_, n := decodeFLACNumber(frame[4:])
+	headerLen, _, _ := parseFLACFrameHeader(frame)
+
+	out := make([]byte, 0, len(frame)+7)
+	out = append(out, 0xff, 0xf9, frame[2], frame[3])
+	out = append(out, encodeFLACNumber(sample)...)
+	out = append(out, frame[4+n:headerLen-1]...)
+
+	var crc8 byte
+	for _, c := range out {
+		crc8 = crc8FLAC(crc8, c)
+	}
+	out = append(out, crc8)
+	out = append(out, frame[headerLen:len(frame)-2]...)
+
+	var crc16 uint16
+	for _, c := range out {
+		crc16 = crc16FLAC(crc16, c)
+	}
+	return binary.BigEndian.AppendUint16(out, crc16)
+}
+
+// decodeFLACNumber decodes the UTF-8-like coded frame or sample number at the
+// start of b and returns it with its length, or a zero length if it is invalid
+func decodeFLACNumber(b []byte) (uint64, int) {
+	if len(b) == 0 {
+		return 0, 0
+	}
+	first := b[0]
+	n := 0
+	for n < 8 && first&(0x80>>n) != 0 {
+		n++
+	}
+	switch {
+	case n == 0:
+		return uint64(first), 1
+	case n == 1 || n > 7 || len(b) < n:
+		return 0, 0
+	}
+
+	v := uint64(first & (0x7f >> n))
+	for _, c := range b[1:n] {
+		if c&0xc0 != 0x80 {
+			return 0, 0
+		}
+		v = v<<6 | uint64(c&0x3f)
+	}
+	return v, n
+}
+
+// encodeFLACNumber encodes a sample number in FLAC's UTF-8-like coding
+func encodeFLACNumber(v uint64) []byte {
+	if v < 0x80 {
+		return []byte{byte(v)}
+	}
+	n := 2
+	for n < 7 && v >= 1<<(5*n+1) {
+		n++
+	}
+	out := make([]byte, n)
+	for i := n - 1; i > 0; i-- {
+		out[i] = 0x80 | byte(v&0x3f)
+		v >>= 6
+	}
+	out[0] = byte(0xff<<(8-n)) | byte(v)
+	return out
+}
+
+func crc8FLAC(crc, b byte) byte {
+	crc ^= b
+	for i := 0; i < 8; i++ {
+		if crc&0x80 != 0 {
+			crc = crc<<1 ^ 0x07
+		} else {
+			crc <<= 1
+		}
+	}
+	return crc
+}
+
+func crc16FLAC(crc uint16, b byte) uint16 {
+	crc ^= uint16(b) << 8
+	for i := 0; i < 8; i++ {
+		if crc&0x8000 != 0 {
+			crc = crc<<1 ^ 0x8005
+		} else {
+			crc <<= 1
+		}
+	}
+	return crc
+}
+
+// joinOpus remuxes Ogg Opus files into one logical stream, since chained
+// streams are not played by many browsers. The headers of the first part are
+// kept, the audio packets of all parts are repaginated with continuous granule
+// positions and only the end trimming of the last part is preserved. The
+// pre-skip of later parts is played rather than discarded, a few milliseconds
+// of encoder warm-up at each chunk boundary.
+func joinOpus(parts [][]byte) ([]byte, error) {
+	var head []byte
+	var tags []byte
+	var audio [][]byte
+	var granule, trim uint64
+	for i, part := range parts {
+		packets, lastGranule, err := oggPackets(part)
+		if err != nil {
+			return nil, fmt.Errorf("opus part %d: %w", i, err)
+		}
+		if len(packets) < 2 || !bytes.HasPrefix(packets[0], []byte("OpusHead")) || len(packets[0]) < 19 {
+			return nil, fmt.Errorf("opus part %d: missing Opus headers", i)
+		}
+		if head == nil {
+			head, tags = packets[0], packets[1]
+		} else if packets[0][9] != head[9] || packets[0][18] != head[18] {
+			return nil, fmt.Errorf("opus part %d has a different channel layout", i)
+		}
+
+		var samples uint64
+		for _, packet := range packets[2:] {
+			samples += uint64(opusPacketSamples(packet))
+		}
+		audio = append(audio, packets[2:]...)
+		granule += samples
+		trim = 0
+		if lastGranule <= samples {
+			trim = samples - lastGranule
+		}
+	}
+
+	pager := &oggPager{serial: binary.LittleEndian.Uint32(parts[0][14:18])}
+	pager.add(head, 0)
+	pager.flush(false)
+	pager.add(tags, 0)
+	pager.flush(false)
+
+	var position uint64
+	for i, packet := range audio {
+		position += uint64(opusPacketSamples(packet))
+		if i == len(audio)-1 {
+			position = granule - trim
+		}
+		pager.add(packet, position)
+	}
+	pager.flush(true)
+	return pager.out.Bytes(), nil
+}
+
+// oggPackets returns the packets of a single Ogg logical stream and the
+// granule position of its last page
+func oggPackets(data []byte) ([][]byte, uint64, error) {
+	var packets [][]byte
+	var packet []byte
+	var granule uint64
+	for pos := 0; pos < len(data); {
+		page := data[pos:]
+		if len(page) < 27 || string(page[:4]) != "OggS" {
+			return nil, 0, errors.New("invalid page")
+		}
+		segments := int(page[26])
+		if len(page) < 27+segments {
+			return nil, 0, errors.New("truncated page")
+		}
+		body := 27 + segments
+		size := body
+		for _, lacing := range page[27:body] {
+			size += int(lacing)
+		}
+		if len(page) < size {
+			return nil, 0, errors.New("truncated page")
+		}
+
+		for _, lacing := range page[27:body] {
+			packet = append(packet, page[body:body+int(lacing)]...)
+			body += int(lacing)
+			if lacing < 255 {
+				packets = append(packets, packet)
+				packet = nil
+			}
+		}
+		granule = binary.LittleEndian.Uint64(page[6:14])
+		pos += size
+	}
+	return packets, granule, nil
+}
+
+// opusPacketSamples returns the duration of an Opus packet in 48 kHz samples,
+// from its TOC byte
+func opusPacketSamples(packet []byte) int {
+	if len(packet) == 0 {
+		return 0
+	}
+	config := packet[0] >> 3
+	var frame int
+	switch {
+	case config < 12: // SILK: 10, 20, 40, 60 ms
+		frame = [4]int{480, 960, 1920, 2880}[config%4]
+	case config < 16: // Hybrid: 10, 20 ms
+		frame = [2]int{480, 960}[config%2]
+	default: // CELT: 2.5, 5, 10, 20 ms
+		frame = [4]int{120, 240, 480, 960}[config%4]
+	}
+
+	switch packet[0] & 0x03 {
+	case 0:
+		return frame
+	case 1, 2:
+		return 2 * frame
+	default:
+		if len(packet) < 2 {
+			return 0
+		}
+		return int(packet[1]&0x3f) * frame
+	}
+}
+
+// oggPager writes packets into the pages of one Ogg logical stream
+type oggPager struct {
+	out        bytes.Buffer
+	serial     uint32
+	sequence   uint32
+	lacing     []byte
+	body       []byte
+	granule    uint64
+	complete   bool // a packet ends on the current page
+	continued  bool // the current page starts inside a packet
+	inProgress bool // the last packet added spans onto the next page
+}
+
+// add appends a packet ending at the given granule position, starting a new
+// page whenever the current one has 255 segments
+func (p *oggPager) add(packet []byte, granule uint64) {
+	for {
+		if len(p.lacing) == 255 {
+			p.inProgress = true
+			p.flush(false)
+		}
+		if len(packet) >= 255 {
+			p.lacing = append(p.lacing, 255)
+			p.body = append(p.body, packet[:255]...)
+			packet = packet[255:]
+			continue
+		}
+		p.lacing = append(p.lacing, byte(len(packet)))
+		p.body = append(p.body, packet...)
+		p.granule = granule
+		p.complete = true
+		p.inProgress = false
+		return
+	}
+}
+
+// flush writes the current page, if it has any segments
+func (p *oggPager) flush(last bool) {
+	if len(p.lacing) == 0 {
+		return
+	}
+
+	var flags byte
+	if p.continued {
+		flags |= 0x01
+	}
+	if p.sequence == 0 {
+		flags |= 0x02
+	}
+	if last {
+		flags |= 0x04
+	}
+	granule := uint64(math.MaxUint64) // no packet ends on this page
+	if p.complete {
+		granule = p.granule
+	}
+
+	page := make([]byte, 27, 27+len(p.lacing)+len(p.body))
+	copy(page, "OggS")
+	page[5] = flags
+	binary.LittleEndian.PutUint64(page[6:14], granule)
+	binary.LittleEndian.PutUint32(page[14:18], p.serial)
+	binary.LittleEndian.PutUint32(page[18:22], p.sequence)
+	page[26] = byte(len(p.lacing))
+	page = append(page, p.lacing...)
+	page = append(page, p.body...)
+	binary.LittleEndian.PutUint32(page[22:26], oggCRC(page))
+	p.out.Write(page)
+
+	p.sequence++
+	p.continued = p.inProgress
+	p.lacing, p.body = nil, nil
+	p.complete = false
+}
+
+// oggCRC computes the Ogg page checksum: CRC-32 with polynomial 0x04c11db7,
+// no reflection and a zero initial value
+func oggCRC(page []byte) uint32 {
+	var crc uint32
+	for _, b := range page {
+		crc ^= uint32(b) << 24
+		for i := 0; i < 8; i++ {
+			if crc&0x80000000 != 0 {
+				crc = crc<<1 ^ 0x04c11db7
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
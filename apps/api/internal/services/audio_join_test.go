@@ -0,0 +1,350 @@
+package services
+
+import (
+	"bytes"
+	"encoding/binary"
+	"slices"
+	"testing"
+)
+
+// wavFile builds a 16-bit mono WAV file with n bytes of samples, writing the
+// given RIFF and data sizes
+func wavFile(n int, riffSize, dataSize uint32) []byte {
+	var b bytes.Buffer
+	b.WriteString("RIFF")
+	binary.Write(&b, binary.LittleEndian, riffSize)
+	b.WriteString("WAVEfmt ")
+	binary.Write(&b, binary.LittleEndian, uint32(16))
+	b.Write([]byte{1, 0, 1, 0, 0xc0, 0x5d, 0, 0, 0x80, 0xbb, 0, 0, 2, 0, 16, 0})
+	b.WriteString("LIST")
+	binary.Write(&b, binary.LittleEndian, uint32(3))
+	b.Write([]byte{'a', 'b', 'c', 0})
+	b.WriteString("data")
+	binary.Write(&b, binary.LittleEndian, dataSize)
+	for i := 0; i < n; i++ {
+		b.WriteByte(byte(i))
+	}
+	return b.Bytes()
+}
+
+func TestJoinWAV(t *testing.T) {
+	tests := []struct {
+		name  string
+		parts [][]byte
+		data  int
+	}{
+		{
+			name:  "streaming sizes",
+			parts: [][]byte{wavFile(10, 0xffffffff, 0xffffffff), wavFile(6, 0xffffffff, 0xffffffff)},
+			data:  16,
+		},
+		{
+			name:  "exact and zero sizes",
+			parts: [][]byte{wavFile(8, 52, 8), wavFile(4, 0, 0)},
+			data:  12,
+		},
+		{
+			name:  "odd data is padded",
+			parts: [][]byte{wavFile(3, 0xffffffff, 3), wavFile(4, 0xffffffff, 4)},
+			data:  7,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := joinSpeechAudio("wav", tt.parts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := binary.LittleEndian.Uint32(out[4:8]); int(got) != len(out)-8 {
+				t.Errorf("RIFF size = %d, want %d", got, len(out)-8)
+			}
+			if got := binary.LittleEndian.Uint32(out[40:44]); int(got) != tt.data {
+				t.Errorf("data size = %d, want %d", got, tt.data)
+			}
+			_, data, err := parseWAV(out)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(data) != tt.data {
+				t.Errorf("parsed %d data bytes, want %d", len(data), tt.data)
+			}
+		})
+	}
+
+	mono := wavFile(4, 0xffffffff, 4)
+	stereo := append([]byte(nil), mono...)
+	stereo[22] = 2
+	if _, err := joinSpeechAudio("wav", [][]byte{mono, stereo}); err == nil {
+		t.Error("joining different sample formats succeeded")
+	}
+}
+
+// flacFile builds a mono 8-bit FLAC file with fixed-size verbatim frames. The
+// samples include 0xff 0xf8, so frame sync codes also occur in frame data.
+func flacFile(frames, blockSize int) []byte {
+	var b bytes.Buffer
+	b.WriteString("fLaC")
+	b.Write([]byte{0x80, 0, 0, 34})
+	streamInfo := make([]byte, 34)
+	binary.BigEndian.PutUint16(streamInfo[0:2], uint16(blockSize))
+	binary.BigEndian.PutUint16(streamInfo[2:4], uint16(blockSize))
+	binary.BigEndian.PutUint64(streamInfo[10:18], uint64(24000)<<44|uint64(7)<<36|uint64(frames*blockSize))
+	b.Write(streamInfo)
+
+	for n := 0; n < frames; n++ {
+		frame := []byte{0xff, 0xf8, 0x60, 0x00}
+		frame = append(frame, encodeFLACNumber(uint64(n))...)
+		frame = append(frame, byte(blockSize-1))
+		var crc8 byte
+		for _, c := range frame {
+			crc8 = crc8FLAC(crc8, c)
+		}
+		frame = append(frame, crc8, 0x02) // verbatim subframe
+		for i := 0; i < blockSize; i++ {
+			frame = append(frame, 0xf8+byte(i))
+		}
+		var crc16 uint16
+		for _, c := range frame {
+			crc16 = crc16FLAC(crc16, c)
+		}
+		b.Write(binary.BigEndian.AppendUint16(frame, crc16))
+	}
+	return b.Bytes()
+}
+
+func TestJoinFLAC(t *testing.T) {
+	tests := []struct {
+		name    string
+		parts   [][]byte
+		samples []uint64
+	}{
+		{
+			name:    "two parts",
+			parts:   [][]byte{flacFile(3, 200), flacFile(2, 100)},
+			samples: []uint64{0, 200, 400, 600, 700},
+		},
+		{
+			name:    "sample numbers past one byte",
+			parts:   [][]byte{flacFile(1, 256), flacFile(1, 256), flacFile(1, 16)},
+			samples: []uint64{0, 256, 512},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := joinSpeechAudio("flac", tt.parts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if out[4] != 0x80 {
+				t.Errorf("metadata header = %#x, want a last STREAMINFO block", out[4])
+			}
+
+			packed := binary.BigEndian.Uint64(out[18:26])
+			last := tt.samples[len(tt.samples)-1]
+			_, blockSize, _ := parseFLACFrameHeader(tt.parts[len(tt.parts)-1][42:])
+			if got, want := packed&(1<<36-1), last+uint64(blockSize); got != want {
+				t.Errorf("STREAMINFO total samples = %d, want %d", got, want)
+			}
+
+			_, audio, err := parseFLAC(out)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []uint64
+			for len(audio) > 0 {
+				frame, _, err := nextFLACFrame(audio)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if frame[1] != 0xf9 {
+					t.Errorf("frame uses blocking strategy %#x, want variable", frame[1])
+				}
+				var crc uint16
+				for _, c := range frame {
+					crc = crc16FLAC(crc, c)
+				}
+				if crc != 0 {
+					t.Errorf("frame CRC-16 does not check out")
+				}
+				sample, _ := decodeFLACNumber(frame[4:])
+				got = append(got, sample)
+				audio = audio[len(frame):]
+			}
+			if !slices.Equal(got, tt.samples) {
+				t.Errorf("frame sample numbers = %v, want %v", got, tt.samples)
+			}
+		})
+	}
+}
+
+func TestFLACNumber(t *testing.T) {
+	for _, v := range []uint64{0, 0x7f, 0x80, 0x7ff, 0x800, 0xffff, 0x10000, 0x1fffff, 0x200000, 1<<36 - 1} {
+		encoded := encodeFLACNumber(v)
+		got, n := decodeFLACNumber(encoded)
+		if got != v || n != len(encoded) {
+			t.Errorf("decodeFLACNumber(encodeFLACNumber(%d)) = %d, %d bytes; want %d bytes", v, got, n, len(encoded))
+		}
+	}
+}
+
+func TestJoinMP3(t *testing.T) {
+	// MPEG-2 Layer III, 64 kbit/s, 24 kHz: 192-byte frames
+	frame := func(tag string) []byte {
+		b := make([]byte, 192)
+		copy(b, []byte{0xff, 0xf3, 0x84, 0xc4})
+		copy(b[21:], tag)
+		return b
+	}
+	part := []byte("ID3\x03\x00\x00\x00\x00\x00\x02id")
+	part = append(part, frame("Info")...)
+	part = append(part, frame("")...)
+	part = append(part, frame("")...)
+	part = append(part, "TAG"+string(make([]byte, 125))...)
+
+	tests := []struct {
+		name   string
+		parts  [][]byte
+		frames int
+	}{
+		{name: "tags and info frames dropped", parts: [][]byte{part, part}, frames: 4},
+		{name: "three parts", parts: [][]byte{part, part, part}, frames: 6},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := joinSpeechAudio("mp3", tt.parts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(out) != tt.frames*192 {
+				t.Errorf("joined %d bytes, want %d frames of 192", len(out), tt.frames)
+			}
+			frames, _ := mp3Frames(out)
+			for _, f := range frames {
+				if isMP3InfoFrame(f) {
+					t.Error("joined stream contains an info frame")
+				}
+			}
+		})
+	}
+}
+
+// opusFile builds an Ogg Opus file with one packet per page; each audio
+// packet is a single 20 ms CELT frame and the last page trims trim samples
+func opusFile(serial uint32, packets, trim int) []byte {
+	head := append([]byte("OpusHead"), 1, 1, 0x38, 0x01, 0x80, 0xbb, 0, 0, 0, 0, 0)
+	pager := &oggPager{serial: serial}
+	pager.add(head, 0)
+	pager.flush(false)
+	pager.add([]byte("OpusTags\x00\x00\x00\x00\x00\x00\x00\x00"), 0)
+	pager.flush(false)
+	for i := 1; i <= packets; i++ {
+		granule := uint64(i * 960)
+		if i == packets {
+			granule -= uint64(trim)
+		}
+		pager.add([]byte{31 << 3, byte(i)}, granule)
+		pager.flush(i == packets)
+	}
+	return pager.out.Bytes()
+}
+
+func TestJoinOpus(t *testing.T) {
+	tests := []struct {
+		name    string
+		parts   [][]byte
+		packets int
+		granule uint64
+	}{
+		{
+			name:    "middle trimming dropped, last kept",
+			parts:   [][]byte{opusFile(7, 3, 100), opusFile(9, 2, 200)},
+			packets: 5,
+			granule: 5*960 - 200,
+		},
+		{
+			name:    "three parts",
+			parts:   [][]byte{opusFile(1, 1, 0), opusFile(2, 1, 0), opusFile(3, 1, 50)},
+			packets: 3,
+			granule: 3*960 - 50,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := joinSpeechAudio("opus", tt.parts)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var pages [][]byte
+			for pos := 0; pos < len(out); {
+				size := 27 + int(out[pos+26])
+				for _, lacing := range out[pos+27 : pos+size] {
+					size += int(lacing)
+				}
+				pages = append(pages, out[pos:pos+size])
+				pos += size
+			}
+
+			var previous uint64
+			for i, page := range pages {
+				if serial := binary.LittleEndian.Uint32(page[14:18]); serial != binary.LittleEndian.Uint32(tt.parts[0][14:18]) {
+					t.Errorf("page %d has serial %d", i, serial)
+				}
+				if sequence := binary.LittleEndian.Uint32(page[18:22]); int(sequence) != i {
+					t.Errorf("page %d has sequence number %d", i, sequence)
+				}
+				if bos := page[5]&0x02 != 0; bos != (i == 0) {
+					t.Errorf("page %d BOS flag = %v", i, bos)
+				}
+				if eos := page[5]&0x04 != 0; eos != (i == len(pages)-1) {
+					t.Errorf("page %d EOS flag = %v", i, eos)
+				}
+				withoutCRC := append([]byte(nil), page...)
+				binary.LittleEndian.PutUint32(withoutCRC[22:26], 0)
+				if oggCRC(withoutCRC) != binary.LittleEndian.Uint32(page[22:26]) {
+					t.Errorf("page %d checksum does not check out", i)
+				}
+				granule := binary.LittleEndian.Uint64(page[6:14])
+				if granule < previous {
+					t.Errorf("page %d granule %d goes back from %d", i, granule, previous)
+				}
+				previous = granule
+			}
+
+			packets, granule, err := oggPackets(out)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(packets) != tt.packets+2 {
+				t.Errorf("joined stream has %d packets, want %d audio packets and 2 headers", len(packets), tt.packets)
+			}
+			if granule != tt.granule {
+				t.Errorf("final granule = %d, want %d", granule, tt.granule)
+			}
+		})
+	}
+}
+
+func TestOggPagerSpansPages(t *testing.T) {
+	packet := bytes.Repeat([]byte{0x5a}, 255*300)
+	pager := &oggPager{serial: 1}
+	pager.add(packet, 42)
+	pager.flush(true)
+
+	packets, granule, err := oggPackets(pager.out.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(packets) != 1 || !bytes.Equal(packets[0], packet) {
+		t.Fatalf("got %d packets, want the one spanning packet", len(packets))
+	}
+	if granule != 42 {
+		t.Errorf("granule = %d, want 42", granule)
+	}
+	if second := pager.out.Bytes()[27+255+255*255:]; second[5]&0x01 == 0 {
+		t.Error("second page is not marked as continued")
+	}
+}
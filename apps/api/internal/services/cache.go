@@ -4,13 +4,17 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"log"
+	"log/slog"
 	"sync"
 	"time"
 
 	"altread-go/api/internal/config"
+	"altread-go/api/internal/logging"
+	"altread-go/api/internal/metrics"
+	"altread-go/api/internal/tracing"
 
 	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // CacheService handles Redis caching for alt text generation results
@@ -37,7 +41,7 @@ func GetCacheService(cfg *config.Config) *CacheService {
 func (cs *CacheService) init() {
 	opt, err := redis.ParseURL(cs.cfg.RedisURL)
 	if err != nil {
-		log.Printf("Warning: Failed to parse Redis URL: %v", err)
+		slog.Warn("Failed to parse Redis URL", logging.ServiceKey, "cache", "error", err)
 		return
 	}
 
@@ -47,39 +51,65 @@ func (cs *CacheService) init() {
 	defer cancel()
 
 	if err := cs.client.Ping(ctx).Err(); err != nil {
-		log.Printf("Warning: Failed to connect to Redis: %v", err)
+		slog.Warn("Failed to connect to Redis", logging.ServiceKey, "cache", "error", err)
 		cs.client = nil
 		return
 	}
 
-	log.Println("Redis connection established")
+	slog.Info("Redis connection established", logging.ServiceKey, "cache")
+}
+
+// Client returns the underlying Redis client, or nil if Redis is unavailable
+func (cs *CacheService) Client() *redis.Client {
+	return cs.client
 }
 
 // GetCachedResult retrieves a cached alt text result by image hash
-func (cs *CacheService) GetCachedResult(ctx context.Context, imageHash string) (*CachedAltTextResult, error) {
+func (cs *CacheService) GetCachedResult(ctx context.Context, imageHash string) (cached *CachedAltTextResult, err error) {
+	ctx, span := tracing.StartClientSpan(ctx, "cache.get",
+		attribute.String("db.system", "redis"),
+		attribute.String("db.operation.name", "GET"),
+	)
+	defer func() {
+		span.SetAttributes(attribute.Bool("cache.hit", cached != nil))
+		tracing.EndSpan(span, err)
+	}()
+
 	if cs.client == nil {
+		metrics.CacheRequests.WithLabelValues("error").Inc()
 		return nil, fmt.Errorf("redis not connected")
 	}
 
 	key := fmt.Sprintf("alt_text:%s", imageHash)
 	val, err := cs.client.Get(ctx, key).Result()
 	if err == redis.Nil {
+		metrics.CacheRequests.WithLabelValues("miss").Inc()
 		return nil, nil
 	}
 	if err != nil {
+		metrics.CacheRequests.WithLabelValues("error").Inc()
 		return nil, err
 	}
 
 	var result CachedAltTextResult
 	if err := json.Unmarshal([]byte(val), &result); err != nil {
+		metrics.CacheRequests.WithLabelValues("error").Inc()
 		return nil, err
 	}
 
+	metrics.CacheRequests.WithLabelValues("hit").Inc()
 	return &result, nil
 }
 
 // CacheResult stores an alt text generation result in cache with TTL based on success status
-func (cs *CacheService) CacheResult(ctx context.Context, imageHash string, result map[string]interface{}, success bool) error {
+func (cs *CacheService) CacheResult(ctx context.Context, imageHash string, result map[string]interface{}, success bool) (err error) {
+	ctx, span := tracing.StartClientSpan(ctx, "cache.set",
+		attribute.String("db.system", "redis"),
+		attribute.String("db.operation.name", "SET"),
+		attribute.Bool("cache.success", success),
+	)
+	defer func() { tracing.EndSpan(span, err) }()
+
 	if cs.client == nil {
 		return fmt.Errorf("redis not connected")
 	}
@@ -0,0 +1,135 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// Circuit breaker states
+const (
+	CircuitClosed   = "closed"
+	CircuitOpen     = "open"
+	CircuitHalfOpen = "half-open"
+)
+
+// ErrCircuitOpen is returned instead of calling a provider that keeps failing
+var ErrCircuitOpen = errors.New("AI provider is temporarily unavailable, please retry shortly")
+
+// openAIBreaker guards both chat and speech calls since they share one upstream
+var openAIBreaker = NewCircuitBreaker(5, 30*time.Second)
+
+// CircuitBreaker stops calling a dependency after consecutive failures and
+// lets a single trial request through once the cooldown has passed
+type CircuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu       sync.Mutex
+	state    string
+	failures int
+	openedAt time.Time
+	trial    bool
+}
+
+// NewCircuitBreaker creates a breaker that opens after threshold consecutive failures
+func NewCircuitBreaker(threshold int, cooldown time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+		state:     CircuitClosed,
+	}
+}
+
+// OpenAICircuitState reports the state of the OpenAI circuit breaker
+func OpenAICircuitState() string {
+	return openAIBreaker.State()
+}
+
+// Allow returns ErrCircuitOpen if the call should not be attempted
+func (cb *CircuitBreaker) Allow() error {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	switch cb.state {
+	case CircuitOpen:
+		if time.Since(cb.openedAt) < cb.cooldown {
+			return ErrCircuitOpen
+		}
+		cb.state = CircuitHalfOpen
+		cb.trial = true
+		return nil
+	case CircuitHalfOpen:
+		if cb.trial {
+			return ErrCircuitOpen
+		}
+		cb.trial = true
+		return nil
+	default:
+		return nil
+	}
+}
+
+// Record reports the outcome of an allowed call. Client-side errors such as
+// invalid input do not count against the dependency, and cancelled calls say
+// nothing about it either way, so they only free the trial slot.
+func (cb *CircuitBreaker) Record(err error) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	cb.trial = false
+	if errors.Is(err, context.Canceled) {
+		return
+	}
+	if !isProviderFailure(err) {
+		cb.state = CircuitClosed
+		cb.failures = 0
+		return
+	}
+
+	cb.failures++
+	if cb.state == CircuitHalfOpen || cb.failures >= cb.threshold {
+		cb.state = CircuitOpen
+		cb.openedAt = time.Now()
+	}
+}
+
+// Release gives back an allowed call that was never made
+func (cb *CircuitBreaker) Release() {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	cb.trial = false
+}
+
+// State returns the current breaker state
+func (cb *CircuitBreaker) State() string {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if cb.state == CircuitOpen && time.Since(cb.openedAt) >= cb.cooldown {
+		return CircuitHalfOpen
+	}
+	return cb.state
+}
+
+func isProviderFailure(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var apiErr *openai.APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.HTTPStatusCode >= 500 || apiErr.HTTPStatusCode == 429
+	}
+
+	var reqErr *openai.RequestError
+	if errors.As(err, &reqErr) {
+		return reqErr.HTTPStatusCode >= 500 || reqErr.HTTPStatusCode == 429
+	}
+
+	return true
+}
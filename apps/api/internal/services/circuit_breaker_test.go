@@ -0,0 +1,135 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	providerErr := &openai.APIError{HTTPStatusCode: 503}
+	clientErr := &openai.APIError{HTTPStatusCode: 400}
+	cancelled := fmt.Errorf("request failed: %w", context.Canceled)
+
+	// A step either waits out the cooldown, asks Allow, or records an outcome
+	type step struct {
+		wait      bool
+		allow     bool
+		allowErr  error
+		record    error
+		recordNil bool
+		state     string
+	}
+
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{
+			name: "opens after threshold provider failures",
+			steps: []step{
+				{record: providerErr, state: CircuitClosed},
+				{record: providerErr, state: CircuitClosed},
+				{record: providerErr, state: CircuitOpen},
+				{allow: true, allowErr: ErrCircuitOpen, state: CircuitOpen},
+			},
+		},
+		{
+			name: "success resets the failure count",
+			steps: []step{
+				{record: providerErr, state: CircuitClosed},
+				{record: providerErr, state: CircuitClosed},
+				{recordNil: true, state: CircuitClosed},
+				{record: providerErr, state: CircuitClosed},
+				{record: providerErr, state: CircuitClosed},
+			},
+		},
+		{
+			name: "client errors do not count",
+			steps: []step{
+				{record: providerErr, state: CircuitClosed},
+				{record: providerErr, state: CircuitClosed},
+				{record: clientErr, state: CircuitClosed},
+				{record: providerErr, state: CircuitClosed},
+			},
+		},
+		{
+			name: "cancelled calls keep the failure count",
+			steps: []step{
+				{record: providerErr, state: CircuitClosed},
+				{record: providerErr, state: CircuitClosed},
+				{record: cancelled, state: CircuitClosed},
+				{record: providerErr, state: CircuitOpen},
+			},
+		},
+		{
+			name: "successful trial closes",
+			steps: []step{
+				{record: providerErr},
+				{record: providerErr},
+				{record: providerErr, state: CircuitOpen},
+				{wait: true, state: CircuitHalfOpen},
+				{allow: true, state: CircuitHalfOpen},
+				{allow: true, allowErr: ErrCircuitOpen, state: CircuitHalfOpen},
+				{recordNil: true, state: CircuitClosed},
+				{allow: true, state: CircuitClosed},
+			},
+		},
+		{
+			name: "failed trial reopens",
+			steps: []step{
+				{record: providerErr},
+				{record: providerErr},
+				{record: providerErr, state: CircuitOpen},
+				{wait: true},
+				{allow: true, state: CircuitHalfOpen},
+				{record: providerErr, state: CircuitOpen},
+				{allow: true, allowErr: ErrCircuitOpen, state: CircuitOpen},
+			},
+		},
+		{
+			name: "cancelled trial stays half-open and frees the trial",
+			steps: []step{
+				{record: providerErr},
+				{record: providerErr},
+				{record: providerErr, state: CircuitOpen},
+				{wait: true},
+				{allow: true, state: CircuitHalfOpen},
+				{record: cancelled, state: CircuitHalfOpen},
+				{allow: true, state: CircuitHalfOpen},
+				{record: providerErr, state: CircuitOpen},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cb := NewCircuitBreaker(3, time.Minute)
+			for i, s := range tt.steps {
+				switch {
+				case s.wait:
+					cb.mu.Lock()
+					cb.openedAt = cb.openedAt.Add(-time.Minute)
+					cb.mu.Unlock()
+				case s.allow:
+					if err := cb.Allow(); !errors.Is(err, s.allowErr) {
+						t.Fatalf("step %d: Allow() = %v, want %v", i, err, s.allowErr)
+					}
+				case s.recordNil:
+					cb.Record(nil)
+				default:
+					cb.Record(s.record)
+				}
+				if s.state != "" {
+					if got := cb.State(); got != s.state {
+						t.Fatalf("step %d: State() = %q, want %q", i, got, s.state)
+					}
+				}
+			}
+		})
+	}
+}
@@ -4,9 +4,11 @@ import (
 	"context"
 	"time"
 
+	"altread-go/api/internal/auth"
 	"altread-go/api/internal/database"
 	"altread-go/api/internal/models"
 	"altread-go/api/internal/schemas"
+	"altread-go/api/internal/tracing"
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
@@ -36,30 +38,75 @@ func (ds *DatabaseService) TrackImageUpload(ctx context.Context, event *imageUpl
 		ProcessingTimeMS: event.ProcessingTimeMS,
 		Success:          event.Success,
 		ErrorMessage:     event.ErrorMessage,
+		ModelUsed:        optionalString(event.ModelUsed),
+		CacheHit:         event.CacheHit,
+		FallbackUsed:     event.FallbackUsed,
+		PromptOptions:    event.PromptOptions,
+		Language:         optionalString(event.Language),
+		UserAgent:        optionalString(event.UserAgent),
+		AuthMethod:       authMethodFromContext(ctx),
+		TenantID:         auth.TenantIDFromContext(ctx),
+		TraceID:          tracing.TraceIDFromContext(ctx),
 		CreatedAt:        time.Now(),
 	}
 
-	return ds.db.WithContext(ctx).Create(dbEvent).Error
+	if err := ds.db.WithContext(ctx).Create(dbEvent).Error; err != nil {
+		return err
+	}
+	GetAnalyticsCache().Invalidate(ctx, tenantKey(ctx))
+	publishImageProcessed(ctx, dbEvent)
+	return nil
 }
 
-// TrackVoicePlayFromSchema records a voice play event from a schema struct
+// TrackVoicePlayFromSchema records a voice play event from a schema struct and,
+// for a successful play, counts it in voice_usage_stats
 func (ds *DatabaseService) TrackVoicePlayFromSchema(ctx context.Context, event *schemas.VoicePlayEvent) error {
 	var durationMS *int
 	if event.DurationMS > 0 {
 		durationMS = &event.DurationMS
 	}
 
+	var speed *float64
+	if event.Speed > 0 {
+		speed = &event.Speed
+	}
+	var audioBytes *int
+	if event.Success {
+		audioBytes = &event.AudioBytes
+	}
+
 	dbEvent := &models.VoicePlay{
-		ID:           uuid.New(),
-		VoiceName:    event.VoiceName,
-		TextLength:   event.TextLength,
-		DurationMS:   durationMS,
-		Success:      event.Success,
-		ErrorMessage: event.ErrorMessage,
-		CreatedAt:    time.Now(),
+		ID:             uuid.New(),
+		VoiceName:      event.VoiceName,
+		TextLength:     event.TextLength,
+		DurationMS:     durationMS,
+		Success:        event.Success,
+		ErrorMessage:   event.ErrorMessage,
+		ErrorCode:      optionalString(event.ErrorCode),
+		ModelUsed:      optionalString(truncate(event.Model, 50)),
+		Speed:          speed,
+		ResponseFormat: optionalString(truncate(event.ResponseFormat, 10)),
+		AudioBytes:     audioBytes,
+		TenantID:       auth.TenantIDFromContext(ctx),
+		TraceID:        tracing.TraceIDFromContext(ctx),
+		CreatedAt:      time.Now(),
 	}
 
-	return ds.db.WithContext(ctx).Create(dbEvent).Error
+	err := ds.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(dbEvent).Error; err != nil {
+			return err
+		}
+		if !dbEvent.Success {
+			return nil
+		}
+		return countVoicePlay(tx, dbEvent)
+	})
+	if err != nil {
+		return err
+	}
+	GetAnalyticsCache().Invalidate(ctx, tenantKey(ctx))
+	publishVoicePlayed(ctx, dbEvent)
+	return nil
 }
 
 type imageUploadEvent struct {
@@ -71,4 +118,19 @@ type imageUploadEvent struct {
 	ProcessingTimeMS *int
 	Success          bool
 	ErrorMessage     *string
+	ModelUsed        string
+	CacheHit         bool
+	FallbackUsed     bool
+	PromptOptions    models.JSONB
+	Language         string
+	UserAgent        string
+}
+
+// authMethodFromContext returns how the caller in ctx authenticated, or nil if anonymous
+func authMethodFromContext(ctx context.Context) *string {
+	principal := auth.PrincipalFromContext(ctx)
+	if principal == nil {
+		return nil
+	}
+	return optionalString(principal.Method)
 }
@@ -0,0 +1,200 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"altread-go/api/internal/auth"
+	"altread-go/api/internal/constants"
+	"altread-go/api/internal/database"
+	"altread-go/api/internal/models"
+	"altread-go/api/internal/schemas"
+	"altread-go/api/internal/tracing"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Client event limits
+const (
+	maxSessionIDLength   = 100
+	maxPlaybackMS        = 60 * 60 * 1000
+	maxEventUserIDLength = 100
+)
+
+// ErrInvalidEvent is returned for a client event that fails validation
+var ErrInvalidEvent = errors.New("invalid event")
+
+// EventMeta describes where an analytics event came from. ClientUserID is
+// reported by the client and is never treated as the authenticated user.
+type EventMeta struct {
+	ClientUserID string
+	SessionID    string
+	IPAddress    string
+	UserAgent    string
+}
+
+// EventService records analytics events
+type EventService struct {
+	db *gorm.DB
+}
+
+// NewEventService creates a new event service instance
+func NewEventService() *EventService {
+	return &EventService{
+		db: database.DB,
+	}
+}
+
+// Record stores an analytics event. The user, tenant and trace are taken from
+// ctx; the user is left empty for anonymous requests.
+func (es *EventService) Record(ctx context.Context, eventType string, data map[string]interface{}, meta EventMeta) error {
+	if es.db == nil {
+		return fmt.Errorf("database not initialized")
+	}
+
+	var userID string
+	if principal := auth.PrincipalFromContext(ctx); principal != nil {
+		userID = principal.Subject
+	}
+
+	event := &models.AnalyticsEvent{
+		ID:           uuid.New(),
+		EventType:    eventType,
+		EventData:    models.JSONB(data),
+		UserID:       optionalString(truncate(userID, maxEventUserIDLength)),
+		ClientUserID: optionalString(truncate(meta.ClientUserID, maxEventUserIDLength)),
+		SessionID:    optionalString(truncate(meta.SessionID, maxSessionIDLength)),
+		UserAgent:    optionalString(meta.UserAgent),
+		TenantID:     auth.TenantIDFromContext(ctx),
+		TraceID:      tracing.TraceIDFromContext(ctx),
+		CreatedAt:    time.Now(),
+	}
+	if net.ParseIP(meta.IPAddress) != nil {
+		event.IPAddress = &meta.IPAddress
+	}
+
+	return es.db.WithContext(ctx).Create(event).Error
+}
+
+// ValidateClientEvent checks a client-reported event against the schema of its
+// type and returns its data as stored. Errors wrap ErrInvalidEvent.
+func ValidateClientEvent(req *schemas.ClientEventRequest) (map[string]interface{}, error) {
+	if len(req.SessionID) > maxSessionIDLength {
+		return nil, invalidEvent("session_id must be at most %d characters", maxSessionIDLength)
+	}
+
+	switch req.Type {
+	case constants.EventAltTextCopied:
+		var event schemas.AltTextCopiedEvent
+		if err := decodeEventData(req.Data, &event); err != nil {
+			return nil, err
+		}
+		if event.AltTextLength <= 0 {
+			return nil, invalidEvent("alt_text_length must be positive")
+		}
+		if event.ImageHash != "" && !isSHA256Hex(event.ImageHash) {
+			return nil, invalidEvent("image_hash must be a hex-encoded SHA-256 hash")
+		}
+		return eventData(event)
+
+	case constants.EventVoicePlaybackCompleted:
+		var event schemas.VoicePlaybackCompletedEvent
+		if err := decodeEventData(req.Data, &event); err != nil {
+			return nil, err
+		}
+		if err := validatePlayback(event.VoiceName, event.TextLength); err != nil {
+			return nil, err
+		}
+		if event.DurationMS < 0 || event.DurationMS > maxPlaybackMS {
+			return nil, invalidEvent("duration_ms must be between 0 and %d", maxPlaybackMS)
+		}
+		return eventData(event)
+
+	case constants.EventVoicePlaybackAborted:
+		var event schemas.VoicePlaybackAbortedEvent
+		if err := decodeEventData(req.Data, &event); err != nil {
+			return nil, err
+		}
+		if err := validatePlayback(event.VoiceName, event.TextLength); err != nil {
+			return nil, err
+		}
+		if event.DurationMS < 0 || event.DurationMS > maxPlaybackMS {
+			return nil, invalidEvent("duration_ms must be between 0 and %d", maxPlaybackMS)
+		}
+		if event.PositionMS < 0 || (event.DurationMS > 0 && event.PositionMS > event.DurationMS) {
+			return nil, invalidEvent("position_ms must be between 0 and duration_ms")
+		}
+		switch event.Reason {
+		case "", "user", "error", "navigation":
+		default:
+			return nil, invalidEvent("reason must be one of: user, error, navigation")
+		}
+		return eventData(event)
+
+	default:
+		return nil, invalidEvent("unknown event type %q; must be one of: %s, %s, %s", req.Type,
+			constants.EventAltTextCopied, constants.EventVoicePlaybackCompleted, constants.EventVoicePlaybackAborted)
+	}
+}
+
+func invalidEvent(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s", ErrInvalidEvent, fmt.Sprintf(format, args...))
+}
+
+// decodeEventData decodes raw into dst, rejecting unknown fields
+func decodeEventData(raw json.RawMessage, dst interface{}) error {
+	if len(raw) == 0 {
+		return invalidEvent("data is required")
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(dst); err != nil {
+		return invalidEvent("data does not match the event schema: %v", err)
+	}
+	return nil
+}
+
+// eventData converts a validated event struct to its stored JSON object
+func eventData(event interface{}) (map[string]interface{}, error) {
+	raw, err := json.Marshal(event)
+	if err != nil {
+		return nil, err
+	}
+	var data map[string]interface{}
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func validatePlayback(voiceName string, textLength int) error {
+	valid := false
+	for _, voice := range constants.OpenAIVoiceList {
+		if voiceName == voice {
+			valid = true
+			break
+		}
+	}
+	if !valid {
+		return invalidEvent("voice_name must be one of the OpenAI voices")
+	}
+	if textLength < 0 || textLength > constants.MaxTextLength {
+		return invalidEvent("text_length must be between 0 and %d", constants.MaxTextLength)
+	}
+	return nil
+}
+
+func isSHA256Hex(s string) bool {
+	if len(s) != 64 {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
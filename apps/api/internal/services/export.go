@@ -0,0 +1,372 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"altread-go/api/internal/database"
+
+	"gorm.io/gorm"
+)
+
+// Export datasets
+const (
+	ExportDatasetImageUploads = "image_uploads"
+	ExportDatasetVoicePlays   = "voice_plays"
+)
+
+// Export formats
+const (
+	ExportFormatCSV    = "csv"
+	ExportFormatNDJSON = "ndjson"
+)
+
+// exportFetchSize is how many rows are fetched from the cursor and flushed to
+// the client at a time
+const exportFetchSize = 1000
+
+// ErrInvalidExport is returned for an export request that fails validation
+var ErrInvalidExport = errors.New("invalid export")
+
+// exportKind says how a column value is written to NDJSON
+type exportKind int
+
+const (
+	exportString exportKind = iota
+	exportNumber
+	exportBool
+	exportJSON
+	exportTime
+)
+
+// exportColumn is a column that can be exported from a dataset
+type exportColumn struct {
+	name string
+	kind exportKind
+}
+
+// exportColumns lists the exportable columns of each dataset in their default order
+var exportColumns = map[string][]exportColumn{
+	ExportDatasetImageUploads: {
+		{"id", exportString},
+		{"created_at", exportTime},
+		{"tenant_id", exportString},
+		{"trace_id", exportString},
+		{"file_name", exportString},
+		{"file_type", exportString},
+		{"file_size", exportNumber},
+		{"image_hash", exportString},
+		{"success", exportBool},
+		{"alt_text", exportString},
+		{"error_message", exportString},
+		{"processing_time_ms", exportNumber},
+		{"model_used", exportString},
+		{"cache_hit", exportBool},
+		{"fallback_used", exportBool},
+		{"prompt_options", exportJSON},
+		{"language", exportString},
+		{"user_agent", exportString},
+		{"auth_method", exportString},
+	},
+	ExportDatasetVoicePlays: {
+		{"id", exportString},
+		{"created_at", exportTime},
+		{"tenant_id", exportString},
+		{"trace_id", exportString},
+		{"voice_name", exportString},
+		{"text_length", exportNumber},
+		{"duration_ms", exportNumber},
+		{"success", exportBool},
+		{"error_message", exportString},
+		{"error_code", exportString},
+		{"model_used", exportString},
+		{"speed", exportNumber},
+		{"response_format", exportString},
+		{"audio_bytes", exportNumber},
+	},
+}
+
+// ExportRequest selects the rows and columns of an analytics export. Rows
+// created in [From, To) are exported; a zero From means since the first row.
+// Success, Model and TenantID filter rows when set, and an empty Columns
+// exports every column.
+type ExportRequest struct {
+	Dataset  string
+	Format   string
+	From     time.Time
+	To       time.Time
+	Success  *bool
+	Model    string
+	TenantID string
+	Columns  []string
+}
+
+// Validate checks the dataset, format, range and columns of the request.
+// Errors wrap ErrInvalidExport.
+func (r *ExportRequest) Validate() error {
+	if _, ok := exportColumns[r.Dataset]; !ok {
+		return invalidExport("dataset must be one of: %s, %s", ExportDatasetImageUploads, ExportDatasetVoicePlays)
+	}
+	if r.Format != ExportFormatCSV && r.Format != ExportFormatNDJSON {
+		return invalidExport("format must be one of: %s, %s", ExportFormatCSV, ExportFormatNDJSON)
+	}
+	if r.To.IsZero() {
+		return invalidExport("to is required")
+	}
+	if !r.From.IsZero() && !r.From.Before(r.To) {
+		return invalidExport("from must be before to")
+	}
+	_, err := r.columns()
+	return err
+}
+
+// ContentType returns the MIME type of the export format
+func (r *ExportRequest) ContentType() string {
+	if r.Format == ExportFormatNDJSON {
+		return "application/x-ndjson"
+	}
+	return "text/csv; charset=utf-8"
+}
+
+// columns resolves the requested column names, in the requested order
+func (r *ExportRequest) columns() ([]exportColumn, error) {
+	available := exportColumns[r.Dataset]
+	if len(r.Columns) == 0 {
+		return available, nil
+	}
+
+	selected := make([]exportColumn, 0, len(r.Columns))
+	seen := make(map[string]bool, len(r.Columns))
+	for _, name := range r.Columns {
+		found := false
+		for _, column := range available {
+			if column.name == name {
+				found = true
+				if !seen[name] {
+					selected = append(selected, column)
+					seen[name] = true
+				}
+				break
+			}
+		}
+		if !found {
+			names := make([]string, len(available))
+			for i, column := range available {
+				names[i] = column.name
+			}
+			return nil, invalidExport("unknown column %q; %s columns are: %s", name, r.Dataset, strings.Join(names, ", "))
+		}
+	}
+	return selected, nil
+}
+
+func invalidExport(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s", ErrInvalidExport, fmt.Sprintf(format, args...))
+}
+
+// ExportService streams raw analytics rows
+type ExportService struct {
+	db *gorm.DB
+}
+
+// NewExportService creates a new export service instance
+func NewExportService() *ExportService {
+	return &ExportService{
+		db: database.DB,
+	}
+}
+
+// Export writes the rows selected by req to w, oldest first, and calls flush
+// after each chunk. Rows are read through a server-side cursor in a read-only
+// repeatable read transaction, so memory use does not grow with the export
+// and the output is a consistent snapshot.
+func (es *ExportService) Export(ctx context.Context, req *ExportRequest, w io.Writer, flush func()) error {
+	if es.db == nil {
+		return fmt.Errorf("database not initialized")
+	}
+	if err := req.Validate(); err != nil {
+		return err
+	}
+	columns, _ := req.columns()
+
+	exprs := make([]string, len(columns))
+	for i, column := range columns {
+		if column.kind == exportTime {
+			exprs[i] = fmt.Sprintf(`to_char(%s AT TIME ZONE 'UTC', 'YYYY-MM-DD"T"HH24:MI:SS.US"Z"')`, column.name)
+		} else {
+			exprs[i] = column.name + "::text"
+		}
+	}
+
+	conds := []string{"created_at < ?"}
+	args := []interface{}{req.To}
+	if !req.From.IsZero() {
+		conds = append(conds, "created_at >= ?")
+		args = append(args, req.From)
+	}
+	if req.Success != nil {
+		conds = append(conds, "success = ?")
+		args = append(args, *req.Success)
+	}
+	if req.Model != "" {
+		conds = append(conds, "model_used = ?")
+		args = append(args, req.Model)
+	}
+	if req.TenantID != "" {
+		conds = append(conds, "tenant_id = ?")
+		args = append(args, req.TenantID)
+	}
+
+	declare := fmt.Sprintf("DECLARE analytics_export NO SCROLL CURSOR FOR SELECT %s FROM %s WHERE %s ORDER BY created_at, id",
+		strings.Join(exprs, ", "), req.Dataset, strings.Join(conds, " AND "))
+
+	encoder := newExportEncoder(req.Format, columns, w)
+	if err := encoder.header(); err != nil {
+		return err
+	}
+
+	return es.db.WithContext(ctx).Session(&gorm.Session{PrepareStmt: false}).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec(declare, args...).Error; err != nil {
+			return err
+		}
+
+		values := make([]sql.NullString, len(columns))
+		dest := make([]interface{}, len(columns))
+		for i := range values {
+			dest[i] = &values[i]
+		}
+
+		for {
+			rows, err := tx.Raw(fmt.Sprintf("FETCH %d FROM analytics_export", exportFetchSize)).Rows()
+			if err != nil {
+				return err
+			}
+			fetched := 0
+			for rows.Next() {
+				if err := rows.Scan(dest...); err != nil {
+					rows.Close()
+					return err
+				}
+				if err := encoder.row(values); err != nil {
+					rows.Close()
+					return err
+				}
+				fetched++
+			}
+			err = rows.Err()
+			rows.Close()
+			if err != nil {
+				return err
+			}
+
+			if err := encoder.flush(); err != nil {
+				return err
+			}
+			flush()
+
+			if fetched < exportFetchSize {
+				return nil
+			}
+		}
+	}, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+}
+
+// exportEncoder writes export rows in one format
+type exportEncoder struct {
+	columns []exportColumn
+	w       io.Writer
+	csv     *csv.Writer
+	buf     bytes.Buffer
+}
+
+func newExportEncoder(format string, columns []exportColumn, w io.Writer) *exportEncoder {
+	e := &exportEncoder{columns: columns, w: w}
+	if format == ExportFormatCSV {
+		e.csv = csv.NewWriter(w)
+	}
+	return e
+}
+
+// header writes the CSV header row; NDJSON has none
+func (e *exportEncoder) header() error {
+	if e.csv == nil {
+		return nil
+	}
+	names := make([]string, len(e.columns))
+	for i, column := range e.columns {
+		names[i] = column.name
+	}
+	return e.csv.Write(names)
+}
+
+// row encodes one row. NULLs are empty CSV fields and JSON nulls, and CSV text
+// cells are escaped against formula injection.
+func (e *exportEncoder) row(values []sql.NullString) error {
+	if e.csv != nil {
+		record := make([]string, len(values))
+		for i, value := range values {
+			record[i] = value.String
+			if e.columns[i].kind == exportString || e.columns[i].kind == exportJSON {
+				record[i] = escapeCSVFormula(record[i])
+			}
+		}
+		return e.csv.Write(record)
+	}
+
+	e.buf.WriteByte('{')
+	for i, value := range values {
+		if i > 0 {
+			e.buf.WriteByte(',')
+		}
+		name, _ := json.Marshal(e.columns[i].name)
+		e.buf.Write(name)
+		e.buf.WriteByte(':')
+		switch {
+		case !value.Valid:
+			e.buf.WriteString("null")
+		case e.columns[i].kind == exportNumber || e.columns[i].kind == exportBool || e.columns[i].kind == exportJSON:
+			e.buf.WriteString(value.String)
+		default:
+			quoted, err := json.Marshal(value.String)
+			if err != nil {
+				return err
+			}
+			e.buf.Write(quoted)
+		}
+	}
+	e.buf.WriteString("}\n")
+	return nil
+}
+
+// escapeCSVFormula prefixes a cell that a spreadsheet would evaluate as a
+// formula with a single quote, so client-supplied text such as file names and
+// user agents is shown as text
+func escapeCSVFormula(cell string) string {
+	if cell == "" {
+		return cell
+	}
+	switch cell[0] {
+	case '=', '+', '-', '@', '\t', '\r':
+		return "'" + cell
+	}
+	return cell
+}
+
+// flush writes out the rows encoded since the last flush
+func (e *exportEncoder) flush() error {
+	if e.csv != nil {
+		e.csv.Flush()
+		return e.csv.Error()
+	}
+	_, err := e.w.Write(e.buf.Bytes())
+	e.buf.Reset()
+	return err
+}
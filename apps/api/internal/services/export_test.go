@@ -0,0 +1,105 @@
+package services
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestEscapeCSVFormula(t *testing.T) {
+	tests := []struct {
+		cell string
+		want string
+	}{
+		{cell: "", want: ""},
+		{cell: "photo.jpg", want: "photo.jpg"},
+		{cell: "=HYPERLINK(\"http://evil\")", want: "'=HYPERLINK(\"http://evil\")"},
+		{cell: "+1+1", want: "'+1+1"},
+		{cell: "-2+3", want: "'-2+3"},
+		{cell: "@SUM(A1)", want: "'@SUM(A1)"},
+		{cell: "\t=1", want: "'\t=1"},
+		{cell: "\r=1", want: "'\r=1"},
+		{cell: "a=b", want: "a=b"},
+		{cell: " =1", want: " =1"},
+	}
+	for _, tt := range tests {
+		if got := escapeCSVFormula(tt.cell); got != tt.want {
+			t.Errorf("escapeCSVFormula(%q) = %q, want %q", tt.cell, got, tt.want)
+		}
+	}
+}
+
+func TestExportRequestValidate(t *testing.T) {
+	to := time.Date(2026, 3, 8, 0, 0, 0, 0, time.UTC)
+	valid := ExportRequest{Dataset: ExportDatasetImageUploads, Format: ExportFormatCSV, To: to}
+
+	tests := []struct {
+		name    string
+		change  func(r *ExportRequest)
+		wantErr bool
+	}{
+		{name: "valid", change: func(r *ExportRequest) {}},
+		{name: "ndjson", change: func(r *ExportRequest) { r.Format = ExportFormatNDJSON }},
+		{name: "voice plays", change: func(r *ExportRequest) { r.Dataset = ExportDatasetVoicePlays }},
+		{name: "unknown dataset", change: func(r *ExportRequest) { r.Dataset = "api_keys" }, wantErr: true},
+		{name: "unknown format", change: func(r *ExportRequest) { r.Format = "xlsx" }, wantErr: true},
+		{name: "missing to", change: func(r *ExportRequest) { r.To = time.Time{} }, wantErr: true},
+		{name: "from after to", change: func(r *ExportRequest) { r.From = to.Add(time.Hour) }, wantErr: true},
+		{name: "from equal to to", change: func(r *ExportRequest) { r.From = to }, wantErr: true},
+		{name: "known columns", change: func(r *ExportRequest) { r.Columns = []string{"id", "file_name"} }},
+		{name: "unknown column", change: func(r *ExportRequest) { r.Columns = []string{"id", "key_hash"} }, wantErr: true},
+		{name: "column of the other dataset", change: func(r *ExportRequest) { r.Columns = []string{"voice_name"} }, wantErr: true},
+		{name: "column as SQL", change: func(r *ExportRequest) { r.Columns = []string{"id; DROP TABLE image_uploads"} }, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := valid
+			tt.change(&req)
+			err := req.Validate()
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidExport) {
+					t.Errorf("Validate() = %v, want ErrInvalidExport", err)
+				}
+			} else if err != nil {
+				t.Errorf("Validate() = %v, want nil", err)
+			}
+		})
+	}
+}
+
+func TestExportRequestColumns(t *testing.T) {
+	tests := []struct {
+		name    string
+		columns []string
+		want    []string
+	}{
+		{name: "requested order", columns: []string{"file_name", "id"}, want: []string{"file_name", "id"}},
+		{name: "duplicates dropped", columns: []string{"id", "success", "id"}, want: []string{"id", "success"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := ExportRequest{Dataset: ExportDatasetImageUploads, Columns: tt.columns}
+			columns, err := req.columns()
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := make([]string, len(columns))
+			for i, column := range columns {
+				got[i] = column.name
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("columns() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	req := ExportRequest{Dataset: ExportDatasetVoicePlays}
+	columns, err := req.columns()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(columns) != len(exportColumns[ExportDatasetVoicePlays]) {
+		t.Errorf("columns() returned %d columns, want every voice_plays column", len(columns))
+	}
+}
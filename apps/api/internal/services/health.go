@@ -0,0 +1,294 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"altread-go/api/internal/config"
+	"altread-go/api/internal/database"
+
+	"github.com/golang-migrate/migrate/v4/database/postgres"
+	"github.com/golang-migrate/migrate/v4/source"
+	_ "github.com/golang-migrate/migrate/v4/source/file"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+)
+
+// Health statuses, from best to worst
+const (
+	HealthOK       = "ok"
+	HealthDegraded = "degraded"
+	HealthFail     = "fail"
+)
+
+const healthCheckTimeout = 2 * time.Second
+
+// ComponentHealth is the result of checking one dependency
+type ComponentHealth struct {
+	Status    string                 `json:"status"`
+	LatencyMS int64                  `json:"latency_ms"`
+	Critical  bool                   `json:"critical"`
+	Error     string                 `json:"error,omitempty"`
+	Details   map[string]interface{} `json:"details,omitempty"`
+}
+
+// HealthReport aggregates component checks into an overall status
+type HealthReport struct {
+	Status     string                      `json:"status"`
+	Version    string                      `json:"version"`
+	Timestamp  time.Time                   `json:"timestamp"`
+	Components map[string]*ComponentHealth `json:"components"`
+}
+
+// HealthService checks the API's dependencies for health and readiness probes
+type HealthService struct {
+	db         *gorm.DB
+	redis      *redis.Client
+	cfg        *config.Config
+	logService *LogService
+
+	latestOnce      sync.Once
+	latestMigration uint
+	latestErr       error
+}
+
+type healthCheck struct {
+	name     string
+	critical bool
+	run      func(ctx context.Context) (map[string]interface{}, string, error)
+}
+
+// NewHealthService creates a health service; redis may be nil if Redis is unavailable
+func NewHealthService(cfg *config.Config, redisClient *redis.Client, logService *LogService) *HealthService {
+	return &HealthService{
+		db:         database.DB,
+		redis:      redisClient,
+		cfg:        cfg,
+		logService: logService,
+	}
+}
+
+// Readiness checks only the dependencies the API cannot serve requests without
+func (hs *HealthService) Readiness(ctx context.Context) *HealthReport {
+	return hs.run(ctx, hs.checks(true))
+}
+
+// Health checks every dependency; non-critical failures degrade but do not fail the report
+func (hs *HealthService) Health(ctx context.Context) *HealthReport {
+	return hs.run(ctx, hs.checks(false))
+}
+
+func (hs *HealthService) checks(criticalOnly bool) []healthCheck {
+	checks := []healthCheck{
+		{name: "postgres", critical: true, run: hs.checkPostgres},
+		{name: "migrations", critical: true, run: hs.checkMigrations},
+	}
+	if criticalOnly {
+		return checks
+	}
+	return append(checks,
+		healthCheck{name: "redis", run: hs.checkRedis},
+		healthCheck{name: "provider", run: hs.checkProvider},
+		healthCheck{name: "log_queue", run: hs.checkLogQueue},
+	)
+}
+
+func (hs *HealthService) run(ctx context.Context, checks []healthCheck) *HealthReport {
+	report := &HealthReport{
+		Status:     HealthOK,
+		Version:    hs.cfg.Version,
+		Timestamp:  time.Now().UTC(),
+		Components: make(map[string]*ComponentHealth, len(checks)),
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, check := range checks {
+		wg.Add(1)
+		go func(check healthCheck) {
+			defer wg.Done()
+
+			checkCtx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+			defer cancel()
+
+			start := time.Now()
+			details, status, err := check.run(checkCtx)
+			result := &ComponentHealth{
+				Status:    status,
+				LatencyMS: time.Since(start).Milliseconds(),
+				Critical:  check.critical,
+				Details:   details,
+			}
+			if err != nil {
+				result.Error = err.Error()
+			}
+
+			mu.Lock()
+			report.Components[check.name] = result
+			mu.Unlock()
+		}(check)
+	}
+	wg.Wait()
+
+	for _, component := range report.Components {
+		switch {
+		case component.Status == HealthFail && component.Critical:
+			report.Status = HealthFail
+		case component.Status != HealthOK && report.Status == HealthOK:
+			report.Status = HealthDegraded
+		}
+	}
+
+	return report
+}
+
+func (hs *HealthService) checkPostgres(ctx context.Context) (map[string]interface{}, string, error) {
+	if hs.db == nil {
+		return nil, HealthFail, errors.New("database not initialized")
+	}
+
+	sqlDB, err := hs.db.DB()
+	if err != nil {
+		return nil, HealthFail, err
+	}
+	if err := sqlDB.PingContext(ctx); err != nil {
+		return nil, HealthFail, err
+	}
+
+	stats := sqlDB.Stats()
+	return map[string]interface{}{
+		"open_connections": stats.OpenConnections,
+		"in_use":           stats.InUse,
+		"idle":             stats.Idle,
+	}, HealthOK, nil
+}
+
+// checkMigrations compares the applied schema version with the newest migration on disk.
+// It reads golang-migrate's version table directly because the migrate driver takes an
+// advisory lock that would block the probe while a migration is running.
+func (hs *HealthService) checkMigrations(ctx context.Context) (map[string]interface{}, string, error) {
+	if hs.db == nil {
+		return nil, HealthFail, errors.New("database not initialized")
+	}
+
+	var row struct {
+		Version int64
+		Dirty   bool
+	}
+	err := hs.db.WithContext(ctx).
+		Raw(fmt.Sprintf("SELECT version, dirty FROM %s LIMIT 1", postgres.DefaultMigrationsTable)).
+		Scan(&row).Error
+	if err != nil {
+		return nil, HealthFail, fmt.Errorf("failed to read migration version: %w", err)
+	}
+
+	details := map[string]interface{}{
+		"version": row.Version,
+		"dirty":   row.Dirty,
+	}
+
+	if row.Dirty {
+		return details, HealthFail, fmt.Errorf("migration %d failed partway through", row.Version)
+	}
+
+	latest, err := hs.latestMigrationVersion()
+	if err != nil {
+		// The applied version is fine; we just cannot tell whether it is current
+		return details, HealthDegraded, err
+	}
+	details["latest"] = latest
+
+	if row.Version < int64(latest) {
+		return details, HealthFail, fmt.Errorf("database is at version %d, expected %d", row.Version, latest)
+	}
+
+	return details, HealthOK, nil
+}
+
+// latestMigrationVersion reads the newest migration version from the migrations directory once
+func (hs *HealthService) latestMigrationVersion() (uint, error) {
+	hs.latestOnce.Do(func() {
+		path, err := filepath.Abs(hs.cfg.MigrationsPath)
+		if err != nil {
+			hs.latestErr = err
+			return
+		}
+		if _, err := os.Stat(path); err != nil {
+			hs.latestErr = fmt.Errorf("migrations directory not found: %s", path)
+			return
+		}
+
+		src, err := source.Open("file://" + path)
+		if err != nil {
+			hs.latestErr = err
+			return
+		}
+		defer src.Close()
+
+		version, err := src.First()
+		if err != nil {
+			hs.latestErr = err
+			return
+		}
+		for {
+			next, err := src.Next(version)
+			if err != nil {
+				break
+			}
+			version = next
+		}
+		hs.latestMigration = version
+	})
+	return hs.latestMigration, hs.latestErr
+}
+
+func (hs *HealthService) checkRedis(ctx context.Context) (map[string]interface{}, string, error) {
+	if hs.redis == nil {
+		return nil, HealthDegraded, errors.New("redis not connected; caching and shared rate limits are disabled")
+	}
+	if err := hs.redis.Ping(ctx).Err(); err != nil {
+		return nil, HealthDegraded, err
+	}
+
+	stats := hs.redis.PoolStats()
+	return map[string]interface{}{
+		"total_connections": stats.TotalConns,
+		"idle_connections":  stats.IdleConns,
+	}, HealthOK, nil
+}
+
+func (hs *HealthService) checkProvider(ctx context.Context) (map[string]interface{}, string, error) {
+	details := map[string]interface{}{
+		"credentials_configured": hs.cfg.OpenAIAPIKey != "",
+		"circuit_breaker":        OpenAICircuitState(),
+	}
+
+	if hs.cfg.OpenAIAPIKey == "" {
+		return details, HealthDegraded, errors.New("OpenAI API key is not configured")
+	}
+	if state := OpenAICircuitState(); state != CircuitClosed {
+		return details, HealthDegraded, fmt.Errorf("circuit breaker is %s", state)
+	}
+
+	return details, HealthOK, nil
+}
+
+func (hs *HealthService) checkLogQueue(ctx context.Context) (map[string]interface{}, string, error) {
+	depth, capacity := hs.logService.QueueDepth()
+	details := map[string]interface{}{
+		"depth":    depth,
+		"capacity": capacity,
+		"dropped":  hs.logService.Dropped(),
+	}
+
+	if capacity > 0 && depth*100/capacity >= 80 {
+		return details, HealthDegraded, errors.New("log queue is nearly full")
+	}
+
+	return details, HealthOK, nil
+}
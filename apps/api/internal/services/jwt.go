@@ -0,0 +1,332 @@
+package services
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"altread-go/api/internal/auth"
+	"altread-go/api/internal/config"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Minimum time between JWKS reload attempts
+const jwksMinRefreshInterval = time.Minute
+
+// ErrInvalidToken is returned when a bearer token fails verification
+var ErrInvalidToken = errors.New("invalid bearer token")
+
+// JWTVerifier validates bearer tokens against a JSON Web Key Set
+type JWTVerifier struct {
+	cfg        *config.Config
+	httpClient *http.Client
+	keys       map[string]interface{}
+	fetchedAt  time.Time
+	mu         sync.RWMutex
+
+	// refreshMu lets one caller at a time reload the key set; attemptedAt is
+	// when the last reload started, successful or not
+	refreshMu   sync.Mutex
+	attemptedAt time.Time
+}
+
+type jsonWebKeySet struct {
+	Keys []jsonWebKey `json:"keys"`
+}
+
+type jsonWebKey struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// NewJWTVerifier creates a verifier and loads the configured key set
+func NewJWTVerifier(ctx context.Context, cfg *config.Config) (*JWTVerifier, error) {
+	v := &JWTVerifier{
+		cfg:        cfg,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+	}
+
+	if err := v.loadKeys(ctx); err != nil {
+		return nil, err
+	}
+
+	return v, nil
+}
+
+// IsJWT reports whether the credential has the three-part compact JWS form
+func IsJWT(credential string) bool {
+	return strings.Count(credential, ".") == 2
+}
+
+// Verify validates the token signature and registered claims and maps it to a principal
+func (v *JWTVerifier) Verify(ctx context.Context, tokenString string) (*auth.Principal, error) {
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512", "EdDSA"}),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(30 * time.Second),
+	}
+	if v.cfg.JWTIssuer != "" {
+		opts = append(opts, jwt.WithIssuer(v.cfg.JWTIssuer))
+	}
+	if v.cfg.JWTAudience != "" {
+		opts = append(opts, jwt.WithAudience(v.cfg.JWTAudience))
+	}
+
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return v.keyFor(ctx, kid)
+	}, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	subject, _ := claims.GetSubject()
+	if subject == "" {
+		return nil, fmt.Errorf("%w: missing sub claim", ErrInvalidToken)
+	}
+
+	tenantID, _ := claims[v.cfg.JWTTenantClaim].(string)
+
+	scopes := claimScopes(claims)
+	if scopes == nil {
+		scopes = v.cfg.JWTDefaultScopes
+	}
+
+	return &auth.Principal{
+		Subject:  subject,
+		TenantID: tenantID,
+		Scopes:   scopes,
+		Method:   auth.MethodJWT,
+	}, nil
+}
+
+func (v *JWTVerifier) keyFor(ctx context.Context, kid string) (interface{}, error) {
+	v.mu.RLock()
+	key, ok := v.lookupKey(kid)
+	fetchedAt := v.fetchedAt
+	v.mu.RUnlock()
+
+	if ok && time.Since(fetchedAt) <= time.Duration(v.cfg.JWTJWKSRefresh)*time.Second {
+		return key, nil
+	}
+
+	// Unknown key IDs usually mean the provider rotated keys
+	if err := v.refreshKeys(ctx, fetchedAt); err != nil && !ok {
+		return nil, err
+	}
+	v.mu.RLock()
+	key, ok = v.lookupKey(kid)
+	v.mu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("no signing key found for kid %q", kid)
+	}
+	return key, nil
+}
+
+// refreshKeys reloads the key set unless another caller already reloaded it
+// since seen or a reload was attempted within jwksMinRefreshInterval.
+// Concurrent callers wait for the reload in progress instead of starting their own.
+func (v *JWTVerifier) refreshKeys(ctx context.Context, seen time.Time) error {
+	v.refreshMu.Lock()
+	defer v.refreshMu.Unlock()
+
+	v.mu.RLock()
+	reloaded := v.fetchedAt.After(seen)
+	v.mu.RUnlock()
+	if reloaded || time.Since(v.attemptedAt) < jwksMinRefreshInterval {
+		return nil
+	}
+
+	v.attemptedAt = time.Now()
+	// The reload is shared, so it must not fail because the first caller went away
+	return v.loadKeys(context.WithoutCancel(ctx))
+}
+
+// lookupKey finds the key by ID; tokens without a kid are accepted only for single-key sets
+func (v *JWTVerifier) lookupKey(kid string) (interface{}, bool) {
+	if kid == "" && len(v.keys) == 1 {
+		for _, key := range v.keys {
+			return key, true
+		}
+	}
+	key, ok := v.keys[kid]
+	return key, ok
+}
+
+func (v *JWTVerifier) loadKeys(ctx context.Context) error {
+	var (
+		data []byte
+		err  error
+	)
+	if v.cfg.JWTJWKSFile != "" {
+		data, err = os.ReadFile(v.cfg.JWTJWKSFile)
+	} else {
+		data, err = v.fetchKeys(ctx)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to load JWKS: %w", err)
+	}
+
+	var set jsonWebKeySet
+	if err := json.Unmarshal(data, &set); err != nil {
+		return fmt.Errorf("failed to parse JWKS: %w", err)
+	}
+
+	keys := make(map[string]interface{}, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.publicKey()
+		if err != nil {
+			return fmt.Errorf("failed to parse JWKS key %q: %w", jwk.Kid, err)
+		}
+		keys[jwk.Kid] = key
+	}
+	if len(keys) == 0 {
+		return fmt.Errorf("JWKS contains no signing keys")
+	}
+
+	v.mu.Lock()
+	v.keys = keys
+	v.fetchedAt = time.Now()
+	v.mu.Unlock()
+
+	return nil
+}
+
+func (v *JWTVerifier) fetchKeys(ctx context.Context) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, v.cfg.JWTJWKSURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := v.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d from %s", resp.StatusCode, v.cfg.JWTJWKSURL)
+	}
+
+	return io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+}
+
+func (k *jsonWebKey) publicKey() (interface{}, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBase64URLInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBase64URLInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decodeBase64URLInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBase64URLInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, fmt.Errorf("point is not on curve %s", k.Crv)
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("invalid Ed25519 key size")
+		}
+		return ed25519.PublicKey(x), nil
+
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
+
+func decodeBase64URLInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(b), nil
+}
+
+// claimScopes reads OAuth "scope" (space-separated) or "scp" (array) claims,
+// keeping only scopes known to this API. It returns nil if neither claim is present.
+func claimScopes(claims jwt.MapClaims) []string {
+	var raw []string
+	if v, ok := claims["scope"].(string); ok {
+		raw = strings.Fields(v)
+	} else {
+		switch v := claims["scp"].(type) {
+		case string:
+			raw = strings.Fields(v)
+		case []interface{}:
+			for _, item := range v {
+				if s, ok := item.(string); ok {
+					raw = append(raw, s)
+				}
+			}
+		}
+	}
+	if raw == nil {
+		return nil
+	}
+
+	scopes := make([]string, 0, len(raw))
+	for _, scope := range raw {
+		if isValidScope(scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+	return scopes
+}
@@ -0,0 +1,164 @@
+package services
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"altread-go/api/internal/auth"
+	"altread-go/api/internal/config"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestJWTVerifierVerify(t *testing.T) {
+	signingKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config.Config{
+		JWTJWKSRefresh:   3600,
+		JWTIssuer:        "https://issuer.example",
+		JWTAudience:      "altread",
+		JWTTenantClaim:   "tenant_id",
+		JWTDefaultScopes: []string{"analytics:read"},
+	}
+	// The key set is preloaded and a reload was just attempted, so an unknown
+	// kid fails without fetching anything
+	v := &JWTVerifier{
+		cfg:         cfg,
+		keys:        map[string]interface{}{"k1": &signingKey.PublicKey},
+		fetchedAt:   time.Now(),
+		attemptedAt: time.Now(),
+	}
+
+	now := time.Now()
+	validClaims := func() jwt.MapClaims {
+		return jwt.MapClaims{
+			"sub":       "user-1",
+			"iss":       cfg.JWTIssuer,
+			"aud":       cfg.JWTAudience,
+			"exp":       now.Add(time.Hour).Unix(),
+			"tenant_id": "acme",
+			"scope":     "alt-text:write voice:write",
+		}
+	}
+	sign := func(method jwt.SigningMethod, kid string, key interface{}, claims jwt.MapClaims) string {
+		token := jwt.NewWithClaims(method, claims)
+		if kid != "" {
+			token.Header["kid"] = kid
+		}
+		signed, err := token.SignedString(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return signed
+	}
+	with := func(change func(jwt.MapClaims)) jwt.MapClaims {
+		claims := validClaims()
+		change(claims)
+		return claims
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		want  *auth.Principal
+	}{
+		{
+			name:  "valid",
+			token: sign(jwt.SigningMethodES256, "k1", signingKey, validClaims()),
+			want: &auth.Principal{Subject: "user-1", TenantID: "acme",
+				Scopes: []string{"alt-text:write", "voice:write"}, Method: auth.MethodJWT},
+		},
+		{
+			name:  "no kid with a single-key set",
+			token: sign(jwt.SigningMethodES256, "", signingKey, validClaims()),
+			want: &auth.Principal{Subject: "user-1", TenantID: "acme",
+				Scopes: []string{"alt-text:write", "voice:write"}, Method: auth.MethodJWT},
+		},
+		{
+			name:  "default scopes without scope claims",
+			token: sign(jwt.SigningMethodES256, "k1", signingKey, with(func(c jwt.MapClaims) { delete(c, "scope") })),
+			want: &auth.Principal{Subject: "user-1", TenantID: "acme",
+				Scopes: []string{"analytics:read"}, Method: auth.MethodJWT},
+		},
+		{name: "signed by another key", token: sign(jwt.SigningMethodES256, "k1", otherKey, validClaims())},
+		{name: "unknown kid", token: sign(jwt.SigningMethodES256, "k2", signingKey, validClaims())},
+		{name: "HMAC signed", token: sign(jwt.SigningMethodHS256, "k1", []byte("secret"), validClaims())},
+		{name: "expired", token: sign(jwt.SigningMethodES256, "k1", signingKey, with(func(c jwt.MapClaims) { c["exp"] = now.Add(-time.Hour).Unix() }))},
+		{name: "no expiry", token: sign(jwt.SigningMethodES256, "k1", signingKey, with(func(c jwt.MapClaims) { delete(c, "exp") }))},
+		{name: "wrong issuer", token: sign(jwt.SigningMethodES256, "k1", signingKey, with(func(c jwt.MapClaims) { c["iss"] = "https://evil.example" }))},
+		{name: "wrong audience", token: sign(jwt.SigningMethodES256, "k1", signingKey, with(func(c jwt.MapClaims) { c["aud"] = "other" }))},
+		{name: "no subject", token: sign(jwt.SigningMethodES256, "k1", signingKey, with(func(c jwt.MapClaims) { delete(c, "sub") }))},
+		{name: "not a token", token: "a.b.c"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := v.Verify(context.Background(), tt.token)
+			if tt.want == nil {
+				if !errors.Is(err, ErrInvalidToken) {
+					t.Fatalf("Verify() error = %v, want ErrInvalidToken", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Verify() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Verify() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClaimScopes(t *testing.T) {
+	tests := []struct {
+		name   string
+		claims jwt.MapClaims
+		want   []string
+	}{
+		{name: "no scope claims", claims: jwt.MapClaims{}, want: nil},
+		{name: "scope string", claims: jwt.MapClaims{"scope": "alt-text:write  voice:write"}, want: []string{"alt-text:write", "voice:write"}},
+		{name: "scp string", claims: jwt.MapClaims{"scp": "analytics:read"}, want: []string{"analytics:read"}},
+		{name: "scp array", claims: jwt.MapClaims{"scp": []interface{}{"analytics:read", 42, "voice:write"}}, want: []string{"analytics:read", "voice:write"}},
+		{name: "scope wins over scp", claims: jwt.MapClaims{"scope": "voice:write", "scp": []interface{}{"admin"}}, want: []string{"voice:write"}},
+		{name: "unknown scopes dropped", claims: jwt.MapClaims{"scope": "openid profile admin"}, want: []string{"admin"}},
+		{name: "only unknown scopes", claims: jwt.MapClaims{"scope": "openid email"}, want: []string{}},
+		{name: "empty scope", claims: jwt.MapClaims{"scope": ""}, want: []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := claimScopes(tt.claims); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("claimScopes() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsJWT(t *testing.T) {
+	tests := []struct {
+		credential string
+		want       bool
+	}{
+		{credential: "header.payload.signature", want: true},
+		{credential: "ak_abc.def", want: false},
+		{credential: "a.b.c.d", want: false},
+		{credential: "", want: false},
+	}
+	for _, tt := range tests {
+		if got := IsJWT(tt.credential); got != tt.want {
+			t.Errorf("IsJWT(%q) = %v, want %v", tt.credential, got, tt.want)
+		}
+	}
+}
@@ -2,32 +2,66 @@ package services
 
 import (
 	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net"
+	"os"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"altread-go/api/internal/auth"
+	"altread-go/api/internal/config"
 	"altread-go/api/internal/database"
+	"altread-go/api/internal/logging"
+	"altread-go/api/internal/metrics"
 	"altread-go/api/internal/models"
+	"altread-go/api/internal/tracing"
 
+	"github.com/jackc/pgx/v5/pgconn"
 	"gorm.io/gorm"
 )
 
+// Log queue overflow policies
+const (
+	LogOverflowDrop   = "drop"
+	LogOverflowBlock  = "block"
+	LogOverflowStderr = "stderr"
+)
+
+const (
+	logBatchSize        = 100
+	logWriteAttempts    = 3
+	logRetryBaseDelay   = 100 * time.Millisecond
+	logWriteTimeout     = 5 * time.Second
+	defaultLogQueue     = 1000
+	defaultBlockTimeout = 100 * time.Millisecond
+)
+
 // LogService handles asynchronous logging to database with batching
 type LogService struct {
-	db      *gorm.DB
-	queue   chan *LogEntry
-	wg      sync.WaitGroup
-	stopCh  chan struct{}
-	stopped bool
-	mu      sync.RWMutex
+	db             *gorm.DB
+	queue          chan *LogEntry
+	overflowPolicy string
+	blockTimeout   time.Duration
+	dropped        atomic.Int64
+	subsMu         sync.RWMutex
+	subs           map[chan LogRecord]struct{}
+	wg             sync.WaitGroup
+	stopped        bool
+	mu             sync.RWMutex
 }
 
 // LogEntry represents a single log entry
 type LogEntry struct {
 	Timestamp time.Time
-	Level     string
+	Level     logging.Level
 	Service   string
 	Message   string
 	TraceID   *string
+	TenantID  *string
 	Context   map[string]interface{}
 }
 
@@ -36,17 +70,60 @@ var logOnce sync.Once
 
 // GetLogService returns a singleton log service instance
 func GetLogService() *LogService {
+	return InitLogService(nil)
+}
+
+// InitLogService returns the singleton log service, creating it with the queue size and
+// overflow policy from cfg. Settings only apply if it is called before GetLogService.
+func InitLogService(cfg *config.Config) *LogService {
 	logOnce.Do(func() {
+		queueSize := defaultLogQueue
+		policy := LogOverflowDrop
+		blockTimeout := defaultBlockTimeout
+		if cfg != nil {
+			if cfg.LogQueueSize > 0 {
+				queueSize = cfg.LogQueueSize
+			}
+			switch cfg.LogOverflowPolicy {
+			case LogOverflowBlock, LogOverflowStderr:
+				policy = cfg.LogOverflowPolicy
+			}
+			if cfg.LogBlockTimeout > 0 {
+				blockTimeout = time.Duration(cfg.LogBlockTimeout) * time.Millisecond
+			}
+		}
+
 		globalLogService = &LogService{
-			db:     database.DB,
-			queue:  make(chan *LogEntry, 1000),
-			stopCh: make(chan struct{}),
+			db:             database.DB,
+			queue:          make(chan *LogEntry, queueSize),
+			overflowPolicy: policy,
+			blockTimeout:   blockTimeout,
 		}
 		globalLogService.start()
+
+		metrics.Default.NewGaugeFunc(
+			"altread_log_queue_depth",
+			"Log entries waiting to be written to the database.",
+			func() float64 { return float64(len(globalLogService.queue)) },
+		)
 	})
 	return globalLogService
 }
 
+// InstallLogger makes the default slog logger write to stdout and store
+// records in application_logs through the log service, as configured in cfg.
+// Call it once the database is initialized. The returned function writes out
+// the queued records and switches the default logger to stdout only; call it
+// on shutdown, after the last request has finished.
+func InstallLogger(cfg *config.Config) func() {
+	ls := InitLogService(cfg)
+	slog.SetDefault(logging.New(cfg, os.Stdout, ls))
+	return func() {
+		slog.SetDefault(logging.New(cfg, os.Stdout, nil))
+		ls.Stop()
+	}
+}
+
 func (ls *LogService) start() {
 	ls.mu.Lock()
 	if ls.stopped {
@@ -59,6 +136,7 @@ func (ls *LogService) start() {
 	go ls.processQueue()
 }
 
+// Stop stops accepting entries and waits for the queued ones to be written
 func (ls *LogService) Stop() {
 	ls.mu.Lock()
 	if ls.stopped {
@@ -66,39 +144,155 @@ func (ls *LogService) Stop() {
 		return
 	}
 	ls.stopped = true
-	ls.mu.Unlock()
-
-	close(ls.stopCh)
-
 	close(ls.queue)
-	for entry := range ls.queue {
-		ls.storeLog(context.Background(), entry)
-	}
+	ls.mu.Unlock()
 
 	ls.wg.Wait()
 }
 
-func (ls *LogService) Log(level, service, message string, traceID *string, context map[string]interface{}) {
-	ls.mu.RLock()
-	if ls.stopped {
-		ls.mu.RUnlock()
-		return
-	}
-	ls.mu.RUnlock()
-
-	entry := &LogEntry{
+// Log queues a message; prefer slog, whose default handler forwards records here
+func (ls *LogService) Log(level logging.Level, service, message string, traceID *string, context map[string]interface{}) {
+	ls.enqueue(&LogEntry{
 		Timestamp: time.Now(),
 		Level:     level,
 		Service:   service,
 		Message:   message,
 		TraceID:   traceID,
 		Context:   context,
+	})
+}
+
+// LogContext logs a message with the trace and tenant of the request carried by ctx.
+// It implements logging.Sink.
+func (ls *LogService) LogContext(ctx context.Context, level logging.Level, service, message string, fields map[string]interface{}) {
+	if t := tracing.FromContext(ctx); t != nil && t.RequestID != t.TraceID {
+		withRequestID := make(map[string]interface{}, len(fields)+1)
+		for k, v := range fields {
+			withRequestID[k] = v
+		}
+		withRequestID["request_id"] = t.RequestID
+		fields = withRequestID
+	}
+
+	ls.enqueue(&LogEntry{
+		Timestamp: time.Now(),
+		Level:     level,
+		Service:   service,
+		Message:   message,
+		TraceID:   tracing.TraceIDFromContext(ctx),
+		TenantID:  auth.TenantIDFromContext(ctx),
+		Context:   fields,
+	})
+}
+
+// enqueue holds the read lock while sending so Stop cannot close the queue mid-send
+func (ls *LogService) enqueue(entry *LogEntry) {
+	if !entry.Level.Valid() {
+		entry.Level = logging.LevelInfo
+	}
+
+	ls.mu.RLock()
+	defer ls.mu.RUnlock()
+	if ls.stopped {
+		return
 	}
 
 	select {
 	case ls.queue <- entry:
+		return
 	default:
 	}
+
+	switch ls.overflowPolicy {
+	case LogOverflowBlock:
+		timer := time.NewTimer(ls.blockTimeout)
+		defer timer.Stop()
+		select {
+		case ls.queue <- entry:
+			return
+		case <-timer.C:
+		}
+	case LogOverflowStderr:
+		spillToStderr(entry)
+		metrics.LogEntriesSpilled.WithLabelValues().Inc()
+		return
+	}
+
+	ls.drop("queue_full", 1)
+}
+
+func (ls *LogService) drop(reason string, n int) {
+	ls.dropped.Add(int64(n))
+	metrics.LogEntriesDropped.WithLabelValues(reason).Add(float64(n))
+}
+
+// spillToStderr writes an entry that could not be queued as a JSON line
+func spillToStderr(entry *LogEntry) {
+	line, err := json.Marshal(map[string]interface{}{
+		"timestamp": entry.Timestamp.UTC().Format(time.RFC3339Nano),
+		"level":     entry.Level,
+		"service":   entry.Service,
+		"message":   entry.Message,
+		"trace_id":  entry.TraceID,
+		"tenant_id": entry.TenantID,
+		"context":   entry.Context,
+	})
+	if err != nil {
+		return
+	}
+	_, _ = os.Stderr.Write(append(line, '\n'))
+}
+
+// Subscribe returns a channel receiving entries as they are written to the
+// database, and a function to unsubscribe. Entries are dropped for subscribers
+// that fall more than buffer entries behind rather than slowing the writer.
+func (ls *LogService) Subscribe(buffer int) (<-chan LogRecord, func()) {
+	ch := make(chan LogRecord, buffer)
+
+	ls.subsMu.Lock()
+	if ls.subs == nil {
+		ls.subs = make(map[chan LogRecord]struct{})
+	}
+	ls.subs[ch] = struct{}{}
+	ls.subsMu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			ls.subsMu.Lock()
+			delete(ls.subs, ch)
+			ls.subsMu.Unlock()
+		})
+	}
+}
+
+func (ls *LogService) publish(rows []models.ApplicationLog) {
+	ls.subsMu.RLock()
+	defer ls.subsMu.RUnlock()
+	if len(ls.subs) == 0 {
+		return
+	}
+
+	for _, row := range rows {
+		record := newLogRecord(row)
+		for ch := range ls.subs {
+			select {
+			case ch <- record:
+			default:
+			}
+		}
+	}
+}
+
+// QueueDepth returns the number of queued entries and the queue capacity
+func (ls *LogService) QueueDepth() (depth, capacity int) {
+	return len(ls.queue), cap(ls.queue)
+}
+
+// Dropped returns the number of entries lost since startup, either because the
+// queue was full or because the database rejected them
+func (ls *LogService) Dropped() int64 {
+	return ls.dropped.Load()
 }
 
 func (ls *LogService) processQueue() {
@@ -107,69 +301,123 @@ func (ls *LogService) processQueue() {
 	ticker := time.NewTicker(500 * time.Millisecond)
 	defer ticker.Stop()
 
-	batch := make([]*LogEntry, 0, 100)
+	batch := make([]*LogEntry, 0, logBatchSize)
 
 	for {
 		select {
-		case <-ls.stopCh:
-			for entry := range ls.queue {
-				batch = append(batch, entry)
-				if len(batch) >= 100 {
-					ls.processBatch(context.Background(), batch)
-					batch = batch[:0]
+		case entry, ok := <-ls.queue:
+			if !ok {
+				if len(batch) > 0 {
+					ls.processBatch(batch)
 				}
+				return
 			}
-			if len(batch) > 0 {
-				ls.processBatch(context.Background(), batch)
-			}
-			return
-
-		case entry := <-ls.queue:
 			batch = append(batch, entry)
-			if len(batch) >= 100 {
-				ls.processBatch(context.Background(), batch)
+			if len(batch) >= logBatchSize {
+				ls.processBatch(batch)
 				batch = batch[:0]
 			}
 
 		case <-ticker.C:
 			if len(batch) > 0 {
-				ls.processBatch(context.Background(), batch)
+				ls.processBatch(batch)
 				batch = batch[:0]
 			}
 		}
 	}
 }
 
-func (ls *LogService) processBatch(ctx context.Context, entries []*LogEntry) {
-	for _, entry := range entries {
-		ls.storeLog(ctx, entry)
+// processBatch writes entries with one multi-row insert, retrying transient
+// failures. If the database rejects the batch outright, entries are retried
+// one at a time so a single bad row does not lose the rest.
+func (ls *LogService) processBatch(entries []*LogEntry) {
+	if ls.db == nil {
+		return
 	}
-}
 
-func (ls *LogService) storeLog(ctx context.Context, entry *LogEntry) {
-	if ls.db == nil {
+	rows := make([]models.ApplicationLog, len(entries))
+	now := time.Now()
+	for i, entry := range entries {
+		var contextJSON models.JSONB
+		if entry.Context != nil {
+			contextJSON = models.JSONB(entry.Context)
+		}
+		rows[i] = models.ApplicationLog{
+			Timestamp: entry.Timestamp,
+			Level:     string(entry.Level),
+			Service:   entry.Service,
+			Message:   entry.Message,
+			TraceID:   entry.TraceID,
+			TenantID:  entry.TenantID,
+			Context:   contextJSON,
+			CreatedAt: now,
+		}
+	}
+
+	err := ls.storeLogs(rows)
+	if err == nil {
+		ls.publish(rows)
 		return
 	}
 
-	var contextJSON models.JSONB
-	if entry.Context != nil {
-		contextJSON = models.JSONB(entry.Context)
+	if len(rows) > 1 && !isTransientDBError(err) {
+		failed := 0
+		for i := range rows {
+			if ls.storeLogs(rows[i:i+1]) != nil {
+				failed++
+				continue
+			}
+			ls.publish(rows[i : i+1])
+		}
+		if failed > 0 {
+			ls.drop("write_failed", failed)
+			slog.WarnContext(logging.WithoutSink(context.Background()), "Failed to write application logs",
+				logging.ServiceKey, "logs", "count", failed, "error", err)
+		}
+		return
 	}
 
-	logEntry := &models.ApplicationLog{
-		ID:        0,
-		Timestamp: entry.Timestamp,
-		Level:     entry.Level,
-		Service:   entry.Service,
-		Message:   entry.Message,
-		TraceID:   entry.TraceID,
-		Context:   contextJSON,
-		CreatedAt: time.Now(),
+	ls.drop("write_failed", len(rows))
+	slog.WarnContext(logging.WithoutSink(context.Background()), "Failed to write application logs",
+		logging.ServiceKey, "logs", "count", len(rows), "error", err)
+}
+
+// storeLogs inserts rows, retrying with exponential backoff while the error is transient
+func (ls *LogService) storeLogs(rows []models.ApplicationLog) error {
+	var err error
+	for attempt := 0; attempt < logWriteAttempts; attempt++ {
+		if attempt > 0 {
+			metrics.LogWriteRetries.WithLabelValues().Inc()
+			time.Sleep(logRetryBaseDelay << (attempt - 1))
+		}
+
+		ctx, cancel := context.WithTimeout(logging.WithoutSink(context.Background()), logWriteTimeout)
+		err = ls.db.WithContext(ctx).CreateInBatches(rows, logBatchSize).Error
+		cancel()
+
+		if err == nil || !isTransientDBError(err) {
+			return err
+		}
 	}
+	return err
+}
 
-	bgCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
+// isTransientDBError reports whether a write may succeed if retried: connection
+// failures, timeouts, deadlocks and resource exhaustion, but not rejected data
+func isTransientDBError(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, driver.ErrBadConn) {
+		return true
+	}
 
-	if err := ls.db.WithContext(bgCtx).Create(logEntry).Error; err != nil {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch pgErr.Code[:2] {
+		case "08", "40", "53", "57":
+			return true
+		}
+		return false
 	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr)
 }
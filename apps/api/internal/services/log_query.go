@@ -0,0 +1,191 @@
+package services
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"altread-go/api/internal/database"
+	"altread-go/api/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// Log query page sizes
+const (
+	DefaultLogPageSize = 100
+	MaxLogPageSize     = 500
+)
+
+// ErrInvalidCursor is returned for a pagination cursor that was not issued by QueryLogs
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// LogRecord is an application log entry as returned by the admin log API
+type LogRecord struct {
+	ID        uint                   `json:"id"`
+	Timestamp time.Time              `json:"timestamp"`
+	Level     string                 `json:"level"`
+	Service   string                 `json:"service"`
+	Message   string                 `json:"message"`
+	TraceID   *string                `json:"traceId,omitempty"`
+	TenantID  *string                `json:"tenantId,omitempty"`
+	Context   map[string]interface{} `json:"context,omitempty"`
+}
+
+func newLogRecord(log models.ApplicationLog) LogRecord {
+	return LogRecord{
+		ID:        log.ID,
+		Timestamp: log.Timestamp,
+		Level:     log.Level,
+		Service:   log.Service,
+		Message:   log.Message,
+		TraceID:   log.TraceID,
+		TenantID:  log.TenantID,
+		Context:   log.Context,
+	}
+}
+
+// LogFilter selects application logs; empty fields match everything
+type LogFilter struct {
+	Levels   []string
+	Service  string
+	TraceID  string
+	TenantID string
+	From     time.Time
+	To       time.Time
+	Search   string
+}
+
+// Matches reports whether a record passes the level, service, trace and tenant
+// filters. Time range and full-text search are only applied by QueryLogs.
+func (f *LogFilter) Matches(record LogRecord) bool {
+	if len(f.Levels) > 0 {
+		found := false
+		for _, level := range f.Levels {
+			if record.Level == level {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if f.Service != "" && record.Service != f.Service {
+		return false
+	}
+	if f.TraceID != "" && (record.TraceID == nil || *record.TraceID != f.TraceID) {
+		return false
+	}
+	if f.TenantID != "" && (record.TenantID == nil || *record.TenantID != f.TenantID) {
+		return false
+	}
+	return true
+}
+
+// LogPage is one page of query results, newest first
+type LogPage struct {
+	Logs       []LogRecord `json:"logs"`
+	NextCursor string      `json:"nextCursor,omitempty"`
+}
+
+// LogQueryService reads application logs for the admin API
+type LogQueryService struct {
+	db *gorm.DB
+}
+
+// NewLogQueryService creates a new log query service instance
+func NewLogQueryService() *LogQueryService {
+	return &LogQueryService{
+		db: database.DB,
+	}
+}
+
+// QueryLogs returns up to limit logs matching filter, newest first, starting
+// after cursor. Pagination is keyset-based so pages stay stable while new logs
+// are written.
+func (qs *LogQueryService) QueryLogs(ctx context.Context, filter *LogFilter, cursor string, limit int) (*LogPage, error) {
+	if limit <= 0 || limit > MaxLogPageSize {
+		limit = DefaultLogPageSize
+	}
+
+	query := qs.db.WithContext(ctx).Model(&models.ApplicationLog{})
+	if len(filter.Levels) > 0 {
+		query = query.Where("level IN ?", filter.Levels)
+	}
+	if filter.Service != "" {
+		query = query.Where("service = ?", filter.Service)
+	}
+	if filter.TraceID != "" {
+		query = query.Where("trace_id = ?", filter.TraceID)
+	}
+	if filter.TenantID != "" {
+		query = query.Where("tenant_id = ?", filter.TenantID)
+	}
+	if !filter.From.IsZero() {
+		query = query.Where("timestamp >= ?", filter.From)
+	}
+	if !filter.To.IsZero() {
+		query = query.Where("timestamp < ?", filter.To)
+	}
+	if filter.Search != "" {
+		query = query.Where("to_tsvector('english', message) @@ websearch_to_tsquery('english', ?)", filter.Search)
+	}
+
+	if cursor != "" {
+		timestamp, id, err := decodeLogCursor(cursor)
+		if err != nil {
+			return nil, err
+		}
+		query = query.Where("(timestamp, id) < (?, ?)", timestamp, id)
+	}
+
+	var rows []models.ApplicationLog
+	err := query.Order("timestamp DESC, id DESC").Limit(limit + 1).Find(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	page := &LogPage{Logs: make([]LogRecord, 0, len(rows))}
+	if len(rows) > limit {
+		rows = rows[:limit]
+		last := rows[len(rows)-1]
+		page.NextCursor = encodeLogCursor(last.Timestamp, last.ID)
+	}
+	for _, row := range rows {
+		page.Logs = append(page.Logs, newLogRecord(row))
+	}
+
+	return page, nil
+}
+
+func encodeLogCursor(timestamp time.Time, id uint) string {
+	raw := fmt.Sprintf("%d:%d", timestamp.UnixMicro(), id)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeLogCursor(cursor string) (time.Time, uint, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return time.Time{}, 0, ErrInvalidCursor
+	}
+
+	micros, id, ok := strings.Cut(string(raw), ":")
+	if !ok {
+		return time.Time{}, 0, ErrInvalidCursor
+	}
+	timestamp, err := strconv.ParseInt(micros, 10, 64)
+	if err != nil {
+		return time.Time{}, 0, ErrInvalidCursor
+	}
+	logID, err := strconv.ParseUint(id, 10, 64)
+	if err != nil {
+		return time.Time{}, 0, ErrInvalidCursor
+	}
+
+	return time.UnixMicro(timestamp), uint(logID), nil
+}
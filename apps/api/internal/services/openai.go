@@ -4,42 +4,54 @@ import (
 	"context"
 	"crypto/sha256"
 	"encoding/base64"
+	"errors"
 	"fmt"
+	"log/slog"
+	"net/http"
 	"strings"
 	"time"
 
 	"altread-go/api/internal/config"
 	"altread-go/api/internal/constants"
+	"altread-go/api/internal/logging"
+	"altread-go/api/internal/metrics"
 	"altread-go/api/internal/schemas"
+	"altread-go/api/internal/tracing"
 
 	"github.com/sashabaranov/go-openai"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // OpenAIService handles OpenAI API interactions for alt text generation
 type OpenAIService struct {
-	client     *openai.Client
-	cfg        *config.Config
-	cache      *CacheService
-	db         *DatabaseService
-	logService *LogService
+	client *openai.Client
+	cfg    *config.Config
+	cache  *CacheService
+	db     *DatabaseService
 }
 
 // NewOpenAIService creates a new OpenAI service instance
 func NewOpenAIService(cfg *config.Config, cache *CacheService, db *DatabaseService) *OpenAIService {
 	var client *openai.Client
 	if cfg.OpenAIAPIKey != "" {
-		client = openai.NewClient(cfg.OpenAIAPIKey)
+		client = newOpenAIClient(cfg.OpenAIAPIKey)
 	}
 
 	return &OpenAIService{
-		client:     client,
-		cfg:        cfg,
-		cache:      cache,
-		db:         db,
-		logService: GetLogService(),
+		client: client,
+		cfg:    cfg,
+		cache:  cache,
+		db:     db,
 	}
 }
 
+// newOpenAIClient creates an OpenAI client that forwards request IDs and trace context
+func newOpenAIClient(apiKey string) *openai.Client {
+	clientConfig := openai.DefaultConfig(apiKey)
+	clientConfig.HTTPClient = &http.Client{Transport: &tracing.Transport{}}
+	return openai.NewClientWithConfig(clientConfig)
+}
+
 // ValidateImageInput validates base64-encoded image data format and size
 func (s *OpenAIService) ValidateImageInput(imageData string) error {
 	if imageData == "" {
@@ -107,36 +119,39 @@ func (s *OpenAIService) BuildPrompt(options map[string]interface{}) string {
 	return prompt
 }
 
-// GenerateAltText generates alt text for an image using OpenAI API with caching and fallback model support
+// GenerateAltText generates alt text for an image using OpenAI API with caching and fallback model support.
+// Every outcome, including validation failures and cache hits, is tracked in image_uploads.
 func (s *OpenAIService) GenerateAltText(ctx context.Context, req *schemas.GenerateAltTextRequest) (*schemas.GenerateAltTextResponse, error) {
 	startTime := time.Now()
+	event := newImageUploadEvent(req, s.generateImageHash(req.Image))
 
-	if resp, err := s.validateAndCheckClient(req.Image, startTime, ctx); resp != nil || err != nil {
+	if resp, err := s.validateAndCheckClient(ctx, req.Image, event, startTime); resp != nil || err != nil {
 		return resp, err
 	}
 
-	imageHash := s.generateImageHash(req.Image)
-	if cached := s.getCachedResult(ctx, imageHash, startTime); cached != nil {
+	if cached := s.getCachedResult(ctx, event, startTime); cached != nil {
 		return cached, nil
 	}
 
-	altText, model, err := s.generateWithFallback(ctx, req)
+	altText, model, fallbackUsed, err := s.generateWithFallback(ctx, req)
+	event.ModelUsed = model
+	event.FallbackUsed = fallbackUsed
 	if err != nil {
-		return s.handleGenerationError(ctx, imageHash, err.Error(), startTime), nil
+		// An open circuit says nothing about this image, so the failure is not cached
+		return s.handleGenerationError(ctx, event, err.Error(), !errors.Is(err, ErrCircuitOpen), startTime), nil
 	}
 
 	if altText == "" {
-		return s.handleGenerationError(ctx, imageHash, "Failed to generate alt text", startTime), nil
+		return s.handleGenerationError(ctx, event, "Failed to generate alt text", true, startTime), nil
 	}
 
-	return s.handleSuccess(ctx, imageHash, altText, model, startTime), nil
+	return s.handleSuccess(ctx, event, altText, startTime), nil
 }
 
-func (s *OpenAIService) validateAndCheckClient(imageData string, startTime time.Time, ctx context.Context) (*schemas.GenerateAltTextResponse, error) {
+func (s *OpenAIService) validateAndCheckClient(ctx context.Context, imageData string, event *imageUploadEvent, startTime time.Time) (*schemas.GenerateAltTextResponse, error) {
 	if err := s.ValidateImageInput(imageData); err != nil {
 		processingTime := int(time.Since(startTime).Milliseconds())
-		imageHash := s.generateImageHash(imageData)
-		go s.trackFailedGeneration(context.Background(), imageHash, processingTime, err.Error())
+		s.trackFailure(ctx, event, processingTime, err.Error())
 		return &schemas.GenerateAltTextResponse{
 			Success:        false,
 			AltText:        "",
@@ -147,8 +162,7 @@ func (s *OpenAIService) validateAndCheckClient(imageData string, startTime time.
 
 	if s.client == nil {
 		processingTime := int(time.Since(startTime).Milliseconds())
-		imageHash := s.generateImageHash(imageData)
-		go s.trackFailedGeneration(context.Background(), imageHash, processingTime, "OpenAI API key is not configured")
+		s.trackFailure(ctx, event, processingTime, "OpenAI API key is not configured")
 		return &schemas.GenerateAltTextResponse{
 			Success:        false,
 			AltText:        "",
@@ -160,8 +174,8 @@ func (s *OpenAIService) validateAndCheckClient(imageData string, startTime time.
 	return nil, nil
 }
 
-func (s *OpenAIService) getCachedResult(ctx context.Context, imageHash string, startTime time.Time) *schemas.GenerateAltTextResponse {
-	cached, err := s.cache.GetCachedResult(ctx, imageHash)
+func (s *OpenAIService) getCachedResult(ctx context.Context, event *imageUploadEvent, startTime time.Time) *schemas.GenerateAltTextResponse {
+	cached, err := s.cache.GetCachedResult(ctx, event.ImageHash)
 	if err != nil || cached == nil {
 		return nil
 	}
@@ -171,6 +185,15 @@ func (s *OpenAIService) getCachedResult(ctx context.Context, imageHash string, s
 	if cached.Error != "" {
 		errorPtr = stringPtr(cached.Error)
 	}
+
+	event.AltText = cached.AltText
+	event.ProcessingTimeMS = &processingTime
+	event.Success = cached.Success
+	event.ErrorMessage = errorPtr
+	event.ModelUsed = cached.ModelUsed
+	event.CacheHit = true
+	go s.trackGeneration(context.WithoutCancel(ctx), event)
+
 	return &schemas.GenerateAltTextResponse{
 		Success:        cached.Success,
 		AltText:        cached.AltText,
@@ -179,29 +202,38 @@ func (s *OpenAIService) getCachedResult(ctx context.Context, imageHash string, s
 	}
 }
 
-func (s *OpenAIService) generateWithFallback(ctx context.Context, req *schemas.GenerateAltTextRequest) (string, string, error) {
+// generateWithFallback returns the alt text, the model that produced it or
+// failed last, and whether the fallback model was used
+func (s *OpenAIService) generateWithFallback(ctx context.Context, req *schemas.GenerateAltTextRequest) (string, string, bool, error) {
 	prompt := s.BuildPrompt(req.Options)
 	model := s.cfg.OpenAIModel
+	fallbackUsed := false
 
 	response, err := s.callOpenAIAPI(ctx, model, prompt, req.Image)
-	if err != nil && model != s.cfg.OpenAIModelFallback {
+	if err != nil && !errors.Is(err, ErrCircuitOpen) && model != s.cfg.OpenAIModelFallback {
+		slog.WarnContext(ctx, "Model failed, retrying with fallback", logging.ServiceKey, "openai",
+			"model", model, "fallback_model", s.cfg.OpenAIModelFallback, "error", err)
 		model = s.cfg.OpenAIModelFallback
+		fallbackUsed = true
 		response, err = s.callOpenAIAPI(ctx, model, prompt, req.Image)
 	}
 
-	return strings.TrimSpace(response), model, err
+	return strings.TrimSpace(response), model, fallbackUsed, err
 }
 
-func (s *OpenAIService) handleGenerationError(ctx context.Context, imageHash, errorMsg string, startTime time.Time) *schemas.GenerateAltTextResponse {
+func (s *OpenAIService) handleGenerationError(ctx context.Context, event *imageUploadEvent, errorMsg string, cacheable bool, startTime time.Time) *schemas.GenerateAltTextResponse {
 	processingTime := int(time.Since(startTime).Milliseconds())
 	resultData := map[string]interface{}{
 		"alt_text":        "",
 		"processing_time": processingTime,
 		"error":           errorMsg,
 		"cached_at":       time.Now().Unix(),
+		"model_used":      event.ModelUsed,
 	}
-	go s.cache.CacheResult(ctx, imageHash, resultData, false)
-	go s.trackFailedGeneration(context.Background(), imageHash, processingTime, errorMsg)
+	if cacheable {
+		go s.cache.CacheResult(ctx, event.ImageHash, resultData, false)
+	}
+	s.trackFailure(ctx, event, processingTime, errorMsg)
 
 	return &schemas.GenerateAltTextResponse{
 		Success:        false,
@@ -211,16 +243,20 @@ func (s *OpenAIService) handleGenerationError(ctx context.Context, imageHash, er
 	}
 }
 
-func (s *OpenAIService) handleSuccess(ctx context.Context, imageHash, altText, model string, startTime time.Time) *schemas.GenerateAltTextResponse {
+func (s *OpenAIService) handleSuccess(ctx context.Context, event *imageUploadEvent, altText string, startTime time.Time) *schemas.GenerateAltTextResponse {
 	processingTime := int(time.Since(startTime).Milliseconds())
 	resultData := map[string]interface{}{
 		"alt_text":        altText,
 		"processing_time": processingTime,
 		"cached_at":       time.Now().Unix(),
-		"model_used":      model,
+		"model_used":      event.ModelUsed,
 	}
-	go s.cache.CacheResult(ctx, imageHash, resultData, true)
-	go s.trackSuccessfulGeneration(context.Background(), imageHash, processingTime, altText, model)
+	go s.cache.CacheResult(ctx, event.ImageHash, resultData, true)
+
+	event.AltText = altText
+	event.ProcessingTimeMS = &processingTime
+	event.Success = true
+	go s.trackGeneration(context.WithoutCancel(ctx), event)
 
 	confidence := 0.95
 	return &schemas.GenerateAltTextResponse{
@@ -231,7 +267,14 @@ func (s *OpenAIService) handleSuccess(ctx context.Context, imageHash, altText, m
 	}
 }
 
-func (s *OpenAIService) callOpenAIAPI(ctx context.Context, model, prompt, imageData string) (string, error) {
+func (s *OpenAIService) callOpenAIAPI(ctx context.Context, model, prompt, imageData string) (content string, err error) {
+	ctx, span := tracing.StartClientSpan(ctx, "openai.chat",
+		attribute.String("gen_ai.system", "openai"),
+		attribute.String("gen_ai.operation.name", "chat"),
+		attribute.String("gen_ai.request.model", model),
+	)
+	defer func() { tracing.EndSpan(span, err) }()
+
 	req := openai.ChatCompletionRequest{
 		Model:       model,
 		MaxTokens:   s.cfg.OpenAIMaxTokens,
@@ -256,11 +299,28 @@ func (s *OpenAIService) callOpenAIAPI(ctx context.Context, model, prompt, imageD
 		},
 	}
 
+	if err := openAIBreaker.Allow(); err != nil {
+		return "", err
+	}
+
+	start := time.Now()
 	resp, err := s.client.CreateChatCompletion(ctx, req)
+	openAIBreaker.Record(err)
+	outcome := "success"
+	if err != nil {
+		outcome = "error"
+	}
+	metrics.ProviderRequestDuration.WithLabelValues("openai", "chat", model, outcome).Observe(time.Since(start).Seconds())
+	metrics.ProviderLatency.Observe(time.Since(start), err != nil, "chat", model)
 	if err != nil {
 		return "", err
 	}
 
+	span.SetAttributes(
+		attribute.Int("gen_ai.usage.input_tokens", resp.Usage.PromptTokens),
+		attribute.Int("gen_ai.usage.output_tokens", resp.Usage.CompletionTokens),
+	)
+
 	if len(resp.Choices) == 0 {
 		return "", fmt.Errorf("no choices in response")
 	}
@@ -273,41 +333,84 @@ func (s *OpenAIService) generateImageHash(imageData string) string {
 	return fmt.Sprintf("%x", hash)
 }
 
-func (s *OpenAIService) trackSuccessfulGeneration(ctx context.Context, imageHash string, processingTime int, altText string, model string) {
-	now := time.Now()
-	fileName := fmt.Sprintf("image_%d.jpg", now.Unix())
+// trackFailure records a failed generation in the background
+func (s *OpenAIService) trackFailure(ctx context.Context, event *imageUploadEvent, processingTime int, errorMessage string) {
+	event.ProcessingTimeMS = &processingTime
+	event.Success = false
+	event.ErrorMessage = stringPtr(errorMessage)
+	go s.trackGeneration(context.WithoutCancel(ctx), event)
+}
+
+func (s *OpenAIService) trackGeneration(ctx context.Context, event *imageUploadEvent) {
+	if err := s.db.TrackImageUpload(ctx, event); err != nil {
+		slog.ErrorContext(ctx, "Failed to track alt text generation", logging.ServiceKey, "openai",
+			"success", event.Success, "cache_hit", event.CacheHit, "error", err)
+	}
+}
+
+// promptOptionKeys are the request options recorded with each generation
+var promptOptionKeys = []string{"include_objects", "include_colors", "include_text", "max_length", "language"}
+
+// newImageUploadEvent describes the image and client of a request; the outcome
+// is filled in by whichever path handles it
+func newImageUploadEvent(req *schemas.GenerateAltTextRequest, imageHash string) *imageUploadEvent {
+	fileType, fileSize := imageMetadata(req.Image)
+	ext := strings.TrimPrefix(fileType, "image/")
+	if ext == "" || ext == "jpeg" {
+		ext = "jpg"
+	}
 
 	event := &imageUploadEvent{
-		FileName:         fileName,
-		FileSize:         0,
-		FileType:         "image/jpeg",
-		ImageHash:        imageHash,
-		AltText:          altText,
-		ProcessingTimeMS: &processingTime,
-		Success:          true,
+		FileName:  fmt.Sprintf("image_%d.%s", time.Now().Unix(), ext),
+		FileSize:  fileSize,
+		FileType:  fileType,
+		ImageHash: imageHash,
+		UserAgent: truncate(req.UserAgent, 500),
+	}
+
+	for _, key := range promptOptionKeys {
+		if value, ok := req.Options[key]; ok {
+			if event.PromptOptions == nil {
+				event.PromptOptions = make(map[string]interface{})
+			}
+			event.PromptOptions[key] = value
+		}
 	}
 
-	if err := s.db.TrackImageUpload(ctx, event); err != nil {
-		s.logService.Log("error", "openai", fmt.Sprintf("Failed to track successful generation: %v", err), nil, nil)
+	if language, ok := req.Options["language"].(string); ok && language != "" {
+		event.Language = truncate(language, 35)
+	} else {
+		event.Language = primaryLanguage(req.AcceptLanguage)
 	}
+
+	return event
 }
 
-func (s *OpenAIService) trackFailedGeneration(ctx context.Context, imageHash string, processingTime int, errorMessage string) {
-	now := time.Now()
-	fileName := fmt.Sprintf("image_%d.jpg", now.Unix())
+// imageMetadata returns the MIME type and decoded size of a data URL image, as
+// far as they can be read without decoding it
+func imageMetadata(imageData string) (string, int) {
+	header, payload, ok := strings.Cut(imageData, ",")
+	if !ok || !strings.HasPrefix(header, "data:") {
+		return "", 0
+	}
 
-	event := &imageUploadEvent{
-		FileName:         fileName,
-		FileSize:         0,
-		FileType:         "image/jpeg",
-		ImageHash:        imageHash,
-		ProcessingTimeMS: &processingTime,
-		Success:          false,
-		ErrorMessage:     stringPtr(errorMessage),
+	fileType, _, _ := strings.Cut(strings.TrimPrefix(header, "data:"), ";")
+	size := len(payload) * 3 / 4
+	size -= len(payload) - len(strings.TrimRight(payload, "="))
+	if size < 0 {
+		size = 0
 	}
+	return truncate(strings.ToLower(fileType), 50), size
+}
 
-	if err := s.db.TrackImageUpload(ctx, event); err != nil {
-		s.logService.Log("error", "openai", fmt.Sprintf("Failed to track failed generation: %v", err), nil, nil)
+// primaryLanguage returns the first language tag of an Accept-Language header
+func primaryLanguage(acceptLanguage string) string {
+	tag, _, _ := strings.Cut(acceptLanguage, ",")
+	tag, _, _ = strings.Cut(tag, ";")
+	tag = strings.TrimSpace(tag)
+	if tag == "*" {
+		return ""
 	}
+	return truncate(tag, 35)
 }
 
@@ -4,12 +4,21 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
 
 	"altread-go/api/internal/config"
 	"altread-go/api/internal/constants"
+	"altread-go/api/internal/logging"
+	"altread-go/api/internal/metrics"
 	"altread-go/api/internal/schemas"
+	"altread-go/api/internal/tracing"
 
 	"github.com/sashabaranov/go-openai"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // OpenAITTSService handles OpenAI TTS API interactions
@@ -32,7 +41,7 @@ func NewOpenAITTSService(cfg *config.Config) *OpenAITTSService {
 
 	var client *openai.Client
 	if cfg.OpenAIAPIKey != "" {
-		client = openai.NewClient(cfg.OpenAIAPIKey)
+		client = newOpenAIClient(cfg.OpenAIAPIKey)
 	}
 
 	return &OpenAITTSService{
@@ -86,7 +95,7 @@ func (s *OpenAITTSService) GenerateSpeech(ctx context.Context, req *schemas.TTSR
 		}, nil
 	}
 
-	if len(req.Text) > constants.MaxTextLength {
+	if utf8.RuneCountInString(req.Text) > constants.MaxTextLength {
 		errorMsg := fmt.Sprintf("Text too long. Maximum %d characters allowed.", constants.MaxTextLength)
 		return &schemas.TTSResponse{
 			Success: false,
@@ -95,31 +104,146 @@ func (s *OpenAITTSService) GenerateSpeech(ctx context.Context, req *schemas.TTSR
 		}, nil
 	}
 
-	model := constants.DefaultTTSModel
-	if req.Model != nil {
-		model = *req.Model
+	model, speed, format := s.SpeechOptions(req)
+
+	// The breaker is asked once per request, so every chunk of a long text goes
+	// ahead when the request is allowed, including as a half-open trial
+	if err := openAIBreaker.Allow(); err != nil {
+		errorMsg := err.Error()
+		return &schemas.TTSResponse{
+			Success: false,
+			Error:   &errorMsg,
+			Code:    stringPtr(constants.ErrCodeProviderUnavailable),
+		}, nil
 	}
 
-	speed := constants.DefaultTTSSpeed
-	if req.Speed != nil {
-		speed = *req.Speed
+	if utf8.RuneCountInString(req.Text) <= constants.MaxSpeechChunkLength {
+		return s.synthesize(ctx, req.Text, req.Voice, model, speed, format), nil
 	}
+	return s.synthesizeChunks(ctx, splitSpeechText(req.Text, constants.MaxSpeechChunkLength), req.Voice, model, speed, format), nil
+}
 
-	format := constants.DefaultTTSFormat
-	if req.ResponseFormat != nil {
-		format = *req.ResponseFormat
+// synthesizeChunks synthesizes text split into chunks, at most
+// cfg.TTSChunkConcurrency at a time, and joins their audio. The first chunk to
+// fail cancels the rest, and its response is returned.
+func (s *OpenAITTSService) synthesizeChunks(ctx context.Context, chunks []string, voice, model string, speed float64, format string) *schemas.TTSResponse {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	start := time.Now()
+	parts := make([][]byte, len(chunks))
+	sem := make(chan struct{}, max(s.cfg.TTSChunkConcurrency, 1))
+
+	var mu sync.Mutex
+	var failure *schemas.TTSResponse
+	var called atomic.Bool
+	var wg sync.WaitGroup
+	for i, chunk := range chunks {
+		wg.Add(1)
+		go func(i int, chunk string) {
+			defer wg.Done()
+
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-ctx.Done():
+				return
+			}
+			if ctx.Err() != nil {
+				return
+			}
+
+			called.Store(true)
+			response := s.synthesize(ctx, chunk, voice, model, speed, format)
+			if response.Success {
+				parts[i] = response.AudioBuffer
+				return
+			}
+
+			mu.Lock()
+			if failure == nil {
+				failure = response
+				cancel()
+			}
+			mu.Unlock()
+		}(i, chunk)
+	}
+	wg.Wait()
+	if !called.Load() {
+		openAIBreaker.Release()
+	}
+
+	synthesisTime := int(time.Since(start).Milliseconds())
+	if failure == nil && ctx.Err() != nil {
+		errorMsg := "Failed to generate speech"
+		failure = &schemas.TTSResponse{
+			Success: false,
+			Error:   &errorMsg,
+			Code:    stringPtr(constants.ErrCodeTTSGenerationError),
+		}
+	}
+	if failure != nil {
+		failure.SynthesisTimeMS = synthesisTime
+		return failure
+	}
+
+	audioData, err := joinSpeechAudio(format, parts)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to join speech audio", logging.ServiceKey, "openai_tts",
+			"format", format, "chunks", len(chunks), "error", err)
+
+		errorMsg := "Failed to join audio chunks"
+		return &schemas.TTSResponse{
+			Success:         false,
+			Error:           &errorMsg,
+			Code:            stringPtr(constants.ErrCodeTTSGenerationError),
+			SynthesisTimeMS: synthesisTime,
+		}
 	}
 
+	slog.InfoContext(ctx, "Synthesized speech in chunks", logging.ServiceKey, "openai_tts",
+		"model", model, "voice", voice, "format", format, "chunks", len(chunks), "duration_ms", synthesisTime)
+
+	return &schemas.TTSResponse{
+		Success:         true,
+		AudioBuffer:     audioData,
+		SynthesisTimeMS: int(time.Since(start).Milliseconds()),
+	}
+}
+
+// synthesize makes one speech request to OpenAI. The caller must have been
+// allowed by openAIBreaker.
+func (s *OpenAITTSService) synthesize(ctx context.Context, text, voice, model string, speed float64, format string) *schemas.TTSResponse {
 	ttsReq := openai.CreateSpeechRequest{
 		Model:          openai.SpeechModel(model),
-		Input:          req.Text,
-		Voice:          openai.SpeechVoice(req.Voice),
+		Input:          text,
+		Voice:          openai.SpeechVoice(voice),
 		Speed:          speed,
 		ResponseFormat: openai.SpeechResponseFormat(format),
 	}
 
-	resp, err := s.client.CreateSpeech(ctx, ttsReq)
+	spanCtx, span := tracing.StartClientSpan(ctx, "openai.speech",
+		attribute.String("gen_ai.system", "openai"),
+		attribute.String("gen_ai.operation.name", "speech"),
+		attribute.String("gen_ai.request.model", model),
+		attribute.Int("tts.characters", len([]rune(text))),
+	)
+	start := time.Now()
+	resp, err := s.client.CreateSpeech(spanCtx, ttsReq)
+	synthesisTime := int(time.Since(start).Milliseconds())
+	openAIBreaker.Record(err)
+	tracing.EndSpan(span, err)
+	outcome := "success"
+	if err != nil {
+		outcome = "error"
+	}
+	metrics.ProviderRequestDuration.WithLabelValues("openai", "speech", model, outcome).Observe(time.Since(start).Seconds())
+	metrics.ProviderLatency.Observe(time.Since(start), err != nil, "speech", model)
+	metrics.TTSCharacters.WithLabelValues(model, outcome).Add(float64(utf8.RuneCountInString(text)))
 	if err != nil {
+		slog.ErrorContext(ctx, "Speech generation failed", logging.ServiceKey, "openai_tts",
+			"model", model, "voice", voice, "error", err)
+
 		errorMsg := "Failed to generate speech"
 		code := constants.ErrCodeTTSGenerationError
 
@@ -138,26 +262,51 @@ func (s *OpenAITTSService) GenerateSpeech(ctx context.Context, req *schemas.TTSR
 		}
 
 		return &schemas.TTSResponse{
-			Success: false,
-			Error:   &errorMsg,
-			Code:    &code,
-		}, nil
+			Success:         false,
+			Error:           &errorMsg,
+			Code:            &code,
+			SynthesisTimeMS: synthesisTime,
+		}
 	}
 
 	audioData, err := io.ReadAll(resp)
+	resp.Close()
 	if err != nil {
 		errorMsg := "Failed to read audio response"
 		return &schemas.TTSResponse{
-			Success: false,
-			Error:   &errorMsg,
-			Code:    stringPtr(constants.ErrCodeReadError),
-		}, nil
+			Success:         false,
+			Error:           &errorMsg,
+			Code:            stringPtr(constants.ErrCodeReadError),
+			SynthesisTimeMS: int(time.Since(start).Milliseconds()),
+		}
 	}
 
 	return &schemas.TTSResponse{
-		Success:     true,
-		AudioBuffer: audioData,
-	}, nil
+		Success:         true,
+		AudioBuffer:     audioData,
+		SynthesisTimeMS: int(time.Since(start).Milliseconds()),
+	}
+}
+
+// SpeechOptions returns the model, speed and response format of a request,
+// with defaults for the ones it leaves out
+func (s *OpenAITTSService) SpeechOptions(req *schemas.TTSRequest) (string, float64, string) {
+	model := constants.DefaultTTSModel
+	if req.Model != nil {
+		model = *req.Model
+	}
+
+	speed := constants.DefaultTTSSpeed
+	if req.Speed != nil {
+		speed = *req.Speed
+	}
+
+	format := constants.DefaultTTSFormat
+	if req.ResponseFormat != nil {
+		format = *req.ResponseFormat
+	}
+
+	return model, speed, format
 }
 
 // GetVoiceIDs returns a list of available voice IDs
@@ -0,0 +1,200 @@
+package services
+
+import (
+	"context"
+	"log/slog"
+	"runtime"
+	"sync"
+	"time"
+
+	"altread-go/api/internal/config"
+	"altread-go/api/internal/database"
+	"altread-go/api/internal/logging"
+	"altread-go/api/internal/metrics"
+	"altread-go/api/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+)
+
+// Metric units stored in system_performance
+const (
+	unitMilliseconds = "ms"
+	unitPercent      = "percent"
+	unitPerMinute    = "per_minute"
+	unitCount        = "count"
+)
+
+// PerformanceSampler periodically snapshots API, provider and runtime metrics
+// into the system_performance table and purges samples past retention
+type PerformanceSampler struct {
+	db        *gorm.DB
+	redis     *redis.Client
+	interval  time.Duration
+	retention time.Duration
+	stopCh    chan struct{}
+	wg        sync.WaitGroup
+	stopOnce  sync.Once
+
+	// First endpoint and provider latency slots not yet recorded
+	mu           sync.Mutex
+	endpointSlot int64
+	providerSlot int64
+}
+
+// NewPerformanceSampler creates a sampler; redis may be nil if Redis is unavailable
+func NewPerformanceSampler(cfg *config.Config, redisClient *redis.Client) *PerformanceSampler {
+	interval := time.Duration(cfg.PerfSampleInterval) * time.Second
+	if interval < time.Minute {
+		interval = time.Minute
+	}
+
+	return &PerformanceSampler{
+		db:           database.DB,
+		redis:        redisClient,
+		interval:     interval,
+		retention:    time.Duration(cfg.PerfRetentionDays) * 24 * time.Hour,
+		stopCh:       make(chan struct{}),
+		endpointSlot: metrics.EndpointLatency.CurrentSlot(),
+		providerSlot: metrics.ProviderLatency.CurrentSlot(),
+	}
+}
+
+// Start begins sampling in the background
+func (ps *PerformanceSampler) Start() {
+	ps.wg.Add(1)
+	go ps.run()
+}
+
+// Stop stops sampling and waits for an in-progress sample to finish
+func (ps *PerformanceSampler) Stop() {
+	ps.stopOnce.Do(func() {
+		close(ps.stopCh)
+	})
+	ps.wg.Wait()
+}
+
+func (ps *PerformanceSampler) run() {
+	defer ps.wg.Done()
+
+	ticker := time.NewTicker(ps.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ps.stopCh:
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			if err := ps.Sample(ctx); err != nil {
+				slog.Error("Failed to record performance sample", logging.ServiceKey, "performance", "error", err)
+			}
+			if err := ps.purge(ctx); err != nil {
+				slog.Error("Failed to purge performance samples", logging.ServiceKey, "performance", "error", err)
+			}
+			cancel()
+		}
+	}
+}
+
+// Sample records one snapshot of all performance metrics. Request and
+// provider figures cover the minutes completed since the previous sample, so
+// all traffic is recorded once whatever the interval.
+func (ps *PerformanceSampler) Sample(ctx context.Context) error {
+	if ps.db == nil {
+		return nil
+	}
+
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	now := time.Now()
+	var rows []models.SystemPerformance
+	addLabeled := func(labels models.SystemPerformance, name string, value float64, unit string) {
+		labels.ID = uuid.New()
+		labels.MetricName = name
+		labels.MetricValue = value
+		labels.MetricUnit = stringPtr(unit)
+		labels.RecordedAt = now
+		rows = append(rows, labels)
+	}
+	add := func(name string, value float64, unit string) {
+		addLabeled(models.SystemPerformance{}, name, value, unit)
+	}
+
+	endpoints, endpointSlot := metrics.EndpointLatency.SnapshotSince(ps.endpointSlot)
+	for _, s := range endpoints {
+		labels := models.SystemPerformance{
+			Method: optionalString(truncate(s.Labels["method"], 10)),
+			Route:  optionalString(truncate(s.Labels["route"], 255)),
+		}
+		addLabeled(labels, "http_request_rate", s.RequestsPerMinute, unitPerMinute)
+		addLabeled(labels, "http_error_rate", s.ErrorRate, unitPercent)
+		addLabeled(labels, "http_latency_p50", s.P50Ms, unitMilliseconds)
+		addLabeled(labels, "http_latency_p90", s.P90Ms, unitMilliseconds)
+		addLabeled(labels, "http_latency_p99", s.P99Ms, unitMilliseconds)
+	}
+
+	providers, providerSlot := metrics.ProviderLatency.SnapshotSince(ps.providerSlot)
+	for _, s := range providers {
+		labels := models.SystemPerformance{
+			Operation: optionalString(truncate(s.Labels["operation"], 50)),
+			Model:     optionalString(truncate(s.Labels["model"], 100)),
+		}
+		addLabeled(labels, "provider_error_rate", s.ErrorRate, unitPercent)
+		addLabeled(labels, "provider_latency_p50", s.P50Ms, unitMilliseconds)
+		addLabeled(labels, "provider_latency_p90", s.P90Ms, unitMilliseconds)
+		addLabeled(labels, "provider_latency_p99", s.P99Ms, unitMilliseconds)
+	}
+
+	if sqlDB, err := ps.db.DB(); err == nil {
+		stats := sqlDB.Stats()
+		add("db_open_connections", float64(stats.OpenConnections), unitCount)
+		add("db_in_use_connections", float64(stats.InUse), unitCount)
+		add("db_idle_connections", float64(stats.Idle), unitCount)
+		add("db_wait_count", float64(stats.WaitCount), unitCount)
+		add("db_wait_duration", float64(stats.WaitDuration.Milliseconds()), unitMilliseconds)
+	}
+
+	if ps.redis != nil {
+		stats := ps.redis.PoolStats()
+		add("redis_total_connections", float64(stats.TotalConns), unitCount)
+		add("redis_idle_connections", float64(stats.IdleConns), unitCount)
+		add("redis_stale_connections", float64(stats.StaleConns), unitCount)
+		add("redis_pool_hits", float64(stats.Hits), unitCount)
+		add("redis_pool_misses", float64(stats.Misses), unitCount)
+		add("redis_pool_timeouts", float64(stats.Timeouts), unitCount)
+	}
+
+	add("goroutines", float64(runtime.NumGoroutine()), unitCount)
+
+	if err := ps.db.WithContext(ctx).CreateInBatches(rows, 100).Error; err != nil {
+		return err
+	}
+
+	// Advance only once recorded, so a failed insert is retried with the next sample
+	ps.endpointSlot, ps.providerSlot = endpointSlot, providerSlot
+	return nil
+}
+
+// purge deletes samples older than the retention period in small batches
+func (ps *PerformanceSampler) purge(ctx context.Context) error {
+	if ps.db == nil || ps.retention <= 0 {
+		return nil
+	}
+
+	cutoff := time.Now().Add(-ps.retention)
+	for {
+		result := ps.db.WithContext(ctx).Exec(
+			`DELETE FROM system_performance WHERE id IN (
+				SELECT id FROM system_performance WHERE recorded_at < ? LIMIT 1000
+			)`, cutoff)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected < 1000 {
+			return nil
+		}
+	}
+}
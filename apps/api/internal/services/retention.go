@@ -0,0 +1,307 @@
+package services
+
+import (
+	"compress/gzip"
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"altread-go/api/internal/config"
+	"altread-go/api/internal/database"
+	"altread-go/api/internal/logging"
+
+	"gorm.io/gorm"
+)
+
+// RetentionPolicy says how long rows of one table are kept
+type RetentionPolicy struct {
+	Table      string
+	TimeColumn string
+	Retention  time.Duration
+}
+
+// PurgeResult reports the rows purged, or that would be purged in a dry run, from one table
+type PurgeResult struct {
+	Table   string
+	Cutoff  time.Time
+	Rows    int64
+	Archive string
+}
+
+// RetentionWorker periodically deletes rows older than each table's retention
+// period, in small batches so the tables stay writable, optionally archiving
+// them to gzipped JSONL files first
+type RetentionWorker struct {
+	db         *gorm.DB
+	policies   []RetentionPolicy
+	interval   time.Duration
+	batchSize  int
+	archiveDir string
+	stopCh     chan struct{}
+	wg         sync.WaitGroup
+	stopOnce   sync.Once
+}
+
+// NewRetentionWorker creates a retention worker from the retention settings in cfg
+func NewRetentionWorker(cfg *config.Config) *RetentionWorker {
+	interval := time.Duration(cfg.RetentionInterval) * time.Second
+	if interval < time.Minute {
+		interval = time.Minute
+	}
+	batchSize := cfg.RetentionBatchSize
+	if batchSize <= 0 {
+		batchSize = 1000
+	}
+
+	days := func(n int) time.Duration { return time.Duration(n) * 24 * time.Hour }
+	return &RetentionWorker{
+		db: database.DB,
+		policies: []RetentionPolicy{
+			{Table: "application_logs", TimeColumn: "timestamp", Retention: days(cfg.RetentionLogsDays)},
+			{Table: "image_uploads", TimeColumn: "created_at", Retention: days(cfg.RetentionImageUploadsDays)},
+			{Table: "voice_plays", TimeColumn: "created_at", Retention: days(cfg.RetentionVoicePlaysDays)},
+			{Table: "analytics_events", TimeColumn: "created_at", Retention: days(cfg.RetentionEventsDays)},
+		},
+		interval:   interval,
+		batchSize:  batchSize,
+		archiveDir: cfg.RetentionArchiveDir,
+		stopCh:     make(chan struct{}),
+	}
+}
+
+// Policies returns the retention policy of each managed table
+func (rw *RetentionWorker) Policies() []RetentionPolicy {
+	return rw.policies
+}
+
+// Start purges expired rows now and keeps purging in the background
+func (rw *RetentionWorker) Start() {
+	rw.wg.Add(1)
+	go rw.run()
+}
+
+// Stop stops purging and waits for an in-progress batch to finish
+func (rw *RetentionWorker) Stop() {
+	rw.stopOnce.Do(func() {
+		close(rw.stopCh)
+	})
+	rw.wg.Wait()
+}
+
+func (rw *RetentionWorker) run() {
+	defer rw.wg.Done()
+
+	ticker := time.NewTicker(rw.interval)
+	defer ticker.Stop()
+
+	for {
+		ctx, cancel := context.WithCancel(context.Background())
+		go func() {
+			select {
+			case <-rw.stopCh:
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+
+		results, err := rw.Purge(ctx, false)
+		for _, result := range results {
+			if result.Rows > 0 {
+				slog.Info("Purged expired rows", logging.ServiceKey, "retention",
+					"table", result.Table, "rows", result.Rows, "cutoff", result.Cutoff, "archive", result.Archive)
+			}
+		}
+		if err != nil && ctx.Err() == nil {
+			slog.Error("Failed to purge expired rows", logging.ServiceKey, "retention", "error", err)
+		}
+		cancel()
+
+		select {
+		case <-rw.stopCh:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Purge deletes rows past retention from the given tables, or all managed tables
+// if none are given. A dry run only counts the rows that would be deleted.
+func (rw *RetentionWorker) Purge(ctx context.Context, dryRun bool, tables ...string) ([]PurgeResult, error) {
+	if rw.db == nil {
+		return nil, fmt.Errorf("database not initialized")
+	}
+
+	selected := make(map[string]bool, len(tables))
+	for _, table := range tables {
+		if !rw.hasPolicy(table) {
+			return nil, fmt.Errorf("table %s has no retention policy", table)
+		}
+		selected[table] = true
+	}
+
+	now := time.Now()
+	var results []PurgeResult
+	for _, policy := range rw.policies {
+		if len(tables) > 0 && !selected[policy.Table] {
+			continue
+		}
+		if policy.Retention <= 0 {
+			continue
+		}
+
+		result := PurgeResult{Table: policy.Table, Cutoff: now.Add(-policy.Retention)}
+		var err error
+		if dryRun {
+			err = rw.countExpired(ctx, policy, &result)
+		} else {
+			err = rw.purgeTable(ctx, policy, &result)
+		}
+		results = append(results, result)
+		if err != nil {
+			return results, fmt.Errorf("failed to purge %s: %w", policy.Table, err)
+		}
+	}
+
+	return results, nil
+}
+
+func (rw *RetentionWorker) hasPolicy(table string) bool {
+	for _, policy := range rw.policies {
+		if policy.Table == table {
+			return true
+		}
+	}
+	return false
+}
+
+func (rw *RetentionWorker) countExpired(ctx context.Context, policy RetentionPolicy, result *PurgeResult) error {
+	return rw.db.WithContext(ctx).Table(policy.Table).
+		Where(policy.TimeColumn+" < ?", result.Cutoff).
+		Count(&result.Rows).Error
+}
+
+// purgeTable deletes expired rows in batches. Each batch locks only the rows it
+// deletes and skips rows locked by other writers or replicas. When archiving,
+// a batch is committed only after its rows are synced to the archive file.
+func (rw *RetentionWorker) purgeTable(ctx context.Context, policy RetentionPolicy, result *PurgeResult) error {
+	batch := fmt.Sprintf(`DELETE FROM %[1]s AS t WHERE id IN (
+		SELECT id FROM %[1]s WHERE %[2]s < ? ORDER BY %[2]s LIMIT ? FOR UPDATE SKIP LOCKED
+	)`, policy.Table, policy.TimeColumn)
+
+	var archive *archiveFile
+	defer func() {
+		if archive != nil {
+			archive.Close()
+		}
+	}()
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if rw.archiveDir == "" {
+			res := rw.db.WithContext(ctx).Exec(batch, result.Cutoff, rw.batchSize)
+			if res.Error != nil {
+				return res.Error
+			}
+			result.Rows += res.RowsAffected
+			if res.RowsAffected < int64(rw.batchSize) {
+				return nil
+			}
+			continue
+		}
+
+		var deleted int
+		err := rw.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			rows, err := tx.Raw(batch+" RETURNING row_to_json(t)::text", result.Cutoff, rw.batchSize).Rows()
+			if err != nil {
+				return err
+			}
+			defer rows.Close()
+
+			var lines []string
+			for rows.Next() {
+				var line string
+				if err := rows.Scan(&line); err != nil {
+					return err
+				}
+				lines = append(lines, line)
+			}
+			if err := rows.Err(); err != nil {
+				return err
+			}
+			if len(lines) == 0 {
+				return nil
+			}
+
+			if archive == nil {
+				archive, err = openArchive(rw.archiveDir, policy.Table, time.Now())
+				if err != nil {
+					return err
+				}
+				result.Archive = archive.path
+			}
+			if err := archive.WriteBatch(lines); err != nil {
+				return err
+			}
+			deleted = len(lines)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		result.Rows += int64(deleted)
+		if deleted < rw.batchSize {
+			return nil
+		}
+	}
+}
+
+// archiveFile appends batches of JSON lines to a gzip file. Each batch is a
+// complete gzip member, so the file stays readable if a purge is interrupted.
+type archiveFile struct {
+	f    *os.File
+	path string
+}
+
+func openArchive(dir, table string, now time.Time) (*archiveFile, error) {
+	dir = filepath.Join(dir, table)
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("failed to create archive directory: %w", err)
+	}
+
+	path := filepath.Join(dir, fmt.Sprintf("%s-%s.jsonl.gz", table, now.UTC().Format("20060102T150405Z")))
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o640)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open archive file: %w", err)
+	}
+	return &archiveFile{f: f, path: path}, nil
+}
+
+// WriteBatch writes lines as one gzip member and syncs the file to disk
+func (a *archiveFile) WriteBatch(lines []string) error {
+	zw := gzip.NewWriter(a.f)
+	for _, line := range lines {
+		if _, err := zw.Write([]byte(line + "\n")); err != nil {
+			return fmt.Errorf("failed to write archive: %w", err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("failed to write archive: %w", err)
+	}
+	if err := a.f.Sync(); err != nil {
+		return fmt.Errorf("failed to sync archive: %w", err)
+	}
+	return nil
+}
+
+// Close closes the archive file
+func (a *archiveFile) Close() error {
+	return a.f.Close()
+}
@@ -0,0 +1,239 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"altread-go/api/internal/config"
+	"altread-go/api/internal/database"
+	"altread-go/api/internal/logging"
+	"altread-go/api/internal/metrics"
+
+	"gorm.io/gorm"
+)
+
+// rollupChunkDays is how many days are recomputed per transaction
+const rollupChunkDays = 31
+
+// rollupTables are the daily rollups replaced by each run
+var rollupTables = []string{"daily_stats", "daily_voice_stats", "daily_model_stats", "daily_error_stats", "daily_latency_stats"}
+
+// RollupWorker periodically rolls image_uploads and voice_plays up into
+// daily_stats and the per-voice, per-model, error and latency daily tables.
+// Only complete UTC days are rolled up; the last rolled-up day is recomputed
+// on every run to pick up late writes.
+type RollupWorker struct {
+	db       *gorm.DB
+	interval time.Duration
+	stopCh   chan struct{}
+	wg       sync.WaitGroup
+	stopOnce sync.Once
+}
+
+// NewRollupWorker creates a rollup worker from the rollup settings in cfg
+func NewRollupWorker(cfg *config.Config) *RollupWorker {
+	interval := time.Duration(cfg.RollupInterval) * time.Second
+	if interval < time.Minute {
+		interval = time.Minute
+	}
+	return &RollupWorker{
+		db:       database.DB,
+		interval: interval,
+		stopCh:   make(chan struct{}),
+	}
+}
+
+// Start rolls up any pending days and keeps rolling up in the background
+func (rw *RollupWorker) Start() {
+	rw.wg.Add(1)
+	go rw.run()
+}
+
+// Stop stops rolling up and waits for an in-progress run to finish
+func (rw *RollupWorker) Stop() {
+	rw.stopOnce.Do(func() {
+		close(rw.stopCh)
+	})
+	rw.wg.Wait()
+}
+
+func (rw *RollupWorker) run() {
+	defer rw.wg.Done()
+
+	ticker := time.NewTicker(rw.interval)
+	defer ticker.Stop()
+
+	for {
+		ctx, cancel := context.WithCancel(context.Background())
+		go func() {
+			select {
+			case <-rw.stopCh:
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+
+		if err := rw.Rollup(ctx); err != nil && ctx.Err() == nil {
+			slog.Error("Failed to roll up daily stats", logging.ServiceKey, "rollup", "error", err)
+		}
+		cancel()
+
+		select {
+		case <-rw.stopCh:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Rollup recomputes every complete day from the last rolled-up day, or from the
+// first recorded event if nothing has been rolled up yet, through yesterday
+func (rw *RollupWorker) Rollup(ctx context.Context) error {
+	if rw.db == nil {
+		return fmt.Errorf("database not initialized")
+	}
+
+	from, err := rw.pendingFrom(ctx)
+	if err != nil {
+		return err
+	}
+	if from.IsZero() {
+		return nil
+	}
+	return rw.RollupDays(ctx, from, startOfDay(time.Now()))
+}
+
+// RollupDays recomputes the rollups of the UTC days in [from, to). Days are
+// replaced rather than incremented, so reruns are safe.
+func (rw *RollupWorker) RollupDays(ctx context.Context, from, to time.Time) error {
+	from, to = startOfDay(from), startOfDay(to)
+	for day := from; day.Before(to); day = day.AddDate(0, 0, rollupChunkDays) {
+		end := day.AddDate(0, 0, rollupChunkDays)
+		if end.After(to) {
+			end = to
+		}
+		if err := rw.rollupChunk(ctx, day, end); err != nil {
+			return fmt.Errorf("failed to roll up %s to %s: %w", day.Format(time.DateOnly), end.Format(time.DateOnly), err)
+		}
+	}
+	return nil
+}
+
+func (rw *RollupWorker) pendingFrom(ctx context.Context) (time.Time, error) {
+	var last sql.NullTime
+	if err := rw.db.WithContext(ctx).Raw("SELECT MAX(date) FROM daily_stats").Scan(&last).Error; err != nil {
+		return time.Time{}, err
+	}
+	if last.Valid {
+		return startOfDay(last.Time), nil
+	}
+
+	var first sql.NullTime
+	err := rw.db.WithContext(ctx).Raw(`SELECT LEAST(
+		(SELECT MIN(created_at) FROM image_uploads),
+		(SELECT MIN(created_at) FROM voice_plays)
+	)`).Scan(&first).Error
+	if err != nil {
+		return time.Time{}, err
+	}
+	if !first.Valid {
+		return time.Time{}, nil
+	}
+	return startOfDay(first.Time), nil
+}
+
+// rollupChunk replaces the rollups of [from, to) in one transaction. A
+// transaction-scoped advisory lock keeps concurrent replicas from interleaving.
+func (rw *RollupWorker) rollupChunk(ctx context.Context, from, to time.Time) error {
+	return rw.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext('daily_stats_rollup'))").Error; err != nil {
+			return err
+		}
+		for _, table := range rollupTables {
+			if err := tx.Exec("DELETE FROM "+table+" WHERE date >= ? AND date < ?", from, to).Error; err != nil {
+				return err
+			}
+		}
+
+		err := tx.Exec(`INSERT INTO daily_stats (date, tenant_key, total_images, total_alt_texts,
+			successful_images, failed_images, total_processing_time_ms, processing_time_samples,
+			avg_processing_time_ms, success_rate, total_voice_plays, successful_voice_plays, cache_hits, updated_at)
+		SELECT date, tenant_key,
+			COALESCE(i.total_images, 0), COALESCE(i.successful_images, 0),
+			COALESCE(i.successful_images, 0), COALESCE(i.failed_images, 0),
+			COALESCE(i.total_processing_time_ms, 0), COALESCE(i.processing_time_samples, 0),
+			CASE WHEN i.processing_time_samples > 0 THEN i.total_processing_time_ms::numeric / i.processing_time_samples ELSE 0 END,
+			CASE WHEN i.total_images > 0 THEN i.successful_images * 100.0 / i.total_images ELSE 0 END,
+			COALESCE(v.total_voice_plays, 0), COALESCE(v.successful_voice_plays, 0), COALESCE(i.cache_hits, 0), NOW()
+		FROM (
+			SELECT (created_at AT TIME ZONE 'UTC')::date AS date, COALESCE(tenant_id, '') AS tenant_key,
+				COUNT(*) FILTER (WHERE NOT cache_hit) AS total_images,
+				COUNT(*) FILTER (WHERE NOT cache_hit AND success) AS successful_images,
+				COUNT(*) FILTER (WHERE NOT cache_hit AND NOT success) AS failed_images,
+				COALESCE(SUM(processing_time_ms) FILTER (WHERE NOT cache_hit), 0) AS total_processing_time_ms,
+				COUNT(processing_time_ms) FILTER (WHERE NOT cache_hit) AS processing_time_samples,
+				COUNT(*) FILTER (WHERE cache_hit) AS cache_hits
+			FROM image_uploads WHERE created_at >= @from AND created_at < @to
+			GROUP BY 1, 2
+		) i
+		FULL OUTER JOIN (
+			SELECT (created_at AT TIME ZONE 'UTC')::date AS date, COALESCE(tenant_id, '') AS tenant_key,
+				COUNT(*) AS total_voice_plays,
+				COUNT(*) FILTER (WHERE success) AS successful_voice_plays
+			FROM voice_plays WHERE created_at >= @from AND created_at < @to
+			GROUP BY 1, 2
+		) v USING (date, tenant_key)`, sql.Named("from", from), sql.Named("to", to)).Error
+		if err != nil {
+			return err
+		}
+
+		err = tx.Exec(`INSERT INTO daily_voice_stats (date, tenant_key, voice_name, play_count)
+		SELECT (created_at AT TIME ZONE 'UTC')::date, COALESCE(tenant_id, ''), voice_name, COUNT(*)
+		FROM voice_plays
+		WHERE created_at >= ? AND created_at < ? AND voice_name IS NOT NULL AND success
+		GROUP BY 1, 2, 3`, from, to).Error
+		if err != nil {
+			return err
+		}
+
+		err = tx.Exec(`INSERT INTO daily_model_stats (date, tenant_key, model_used, total, failed,
+			total_processing_time_ms, processing_time_samples, cache_hits, fallbacks)
+		SELECT (created_at AT TIME ZONE 'UTC')::date, COALESCE(tenant_id, ''), model_used,
+			COUNT(*) FILTER (WHERE NOT cache_hit),
+			COUNT(*) FILTER (WHERE NOT cache_hit AND NOT success),
+			COALESCE(SUM(processing_time_ms) FILTER (WHERE NOT cache_hit), 0),
+			COUNT(processing_time_ms) FILTER (WHERE NOT cache_hit),
+			COUNT(*) FILTER (WHERE cache_hit),
+			COUNT(*) FILTER (WHERE fallback_used)
+		FROM image_uploads
+		WHERE created_at >= ? AND created_at < ? AND model_used IS NOT NULL
+		GROUP BY 1, 2, 3`, from, to).Error
+		if err != nil {
+			return err
+		}
+
+		err = tx.Exec(`INSERT INTO daily_error_stats (date, tenant_key, message, error_count)
+		SELECT (created_at AT TIME ZONE 'UTC')::date, COALESCE(tenant_id, ''), COALESCE(LEFT(error_message, 200), ''), COUNT(*)
+		FROM image_uploads
+		WHERE created_at >= ? AND created_at < ? AND NOT success AND NOT cache_hit
+		GROUP BY 1, 2, 3`, from, to).Error
+		if err != nil {
+			return err
+		}
+
+		return tx.Exec(fmt.Sprintf(`INSERT INTO daily_latency_stats (date, tenant_key, latency_bucket, request_count, total_processing_time_ms)
+		SELECT (created_at AT TIME ZONE 'UTC')::date, COALESCE(tenant_id, ''), %s, COUNT(*), SUM(processing_time_ms)
+		FROM image_uploads
+		WHERE created_at >= ? AND created_at < ? AND NOT cache_hit AND processing_time_ms IS NOT NULL
+		GROUP BY 1, 2, 3`, metrics.LatencyBucketSQL("processing_time_ms")), from, to).Error
+	})
+}
+
+// startOfDay returns midnight UTC of t's UTC day
+func startOfDay(t time.Time) time.Time {
+	return t.UTC().Truncate(24 * time.Hour)
+}
@@ -0,0 +1,202 @@
+package services
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// speechAbbreviations end with a period that does not end a sentence
+var speechAbbreviations = map[string]bool{
+	"mr": true, "mrs": true, "ms": true, "dr": true, "prof": true, "sr": true, "jr": true,
+	"st": true, "mt": true, "vs": true, "etc": true, "approx": true, "fig": true, "figs": true,
+	"no": true, "nos": true, "vol": true, "p": true, "pp": true, "ch": true, "sec": true,
+	"e.g": true, "i.e": true, "cf": true, "al": true, "inc": true, "ltd": true, "co": true,
+	"corp": true, "dept": true, "est": true, "jan": true, "feb": true, "mar": true, "apr": true,
+	"jun": true, "jul": true, "aug": true, "sep": true, "sept": true, "oct": true, "nov": true,
+	"dec": true, "a.m": true, "p.m": true, "u.s": true, "u.k": true,
+}
+
+// splitSpeechText splits text into chunks of at most maxRunes characters for
+// synthesis. Chunks end at sentence boundaries where possible, then at clause
+// boundaries, then between words; a single word longer than maxRunes is cut.
+func splitSpeechText(text string, maxRunes int) []string {
+	text = strings.TrimSpace(text)
+	if utf8.RuneCountInString(text) <= maxRunes {
+		return []string{text}
+	}
+
+	var units []string
+	for _, sentence := range splitAfter(text, sentenceEnd) {
+		units = append(units, fitSpeechUnit(sentence, maxRunes)...)
+	}
+
+	var chunks []string
+	var current strings.Builder
+	currentRunes := 0
+	for _, unit := range units {
+		if currentRunes > 0 && currentRunes+speechRunes(unit) > maxRunes {
+			chunks = appendChunk(chunks, current.String())
+			current.Reset()
+			currentRunes = 0
+		}
+		current.WriteString(unit)
+		currentRunes += utf8.RuneCountInString(unit)
+	}
+	return appendChunk(chunks, current.String())
+}
+
+// fitSpeechUnit splits a sentence that is too long at clause boundaries,
+// then between words, then anywhere
+func fitSpeechUnit(s string, maxRunes int) []string {
+	if speechRunes(s) <= maxRunes {
+		return []string{s}
+	}
+
+	var pieces []string
+	for _, clause := range splitAfter(s, clauseEnd) {
+		if speechRunes(clause) <= maxRunes {
+			pieces = append(pieces, clause)
+			continue
+		}
+		for _, word := range splitAfter(clause, wordEnd) {
+			for speechRunes(word) > maxRunes {
+				cut := runeOffset(word, maxRunes)
+				pieces = append(pieces, word[:cut])
+				word = word[cut:]
+			}
+			pieces = append(pieces, word)
+		}
+	}
+	return pieces
+}
+
+// splitAfter splits s after each boundary reported by isEnd, keeping the
+// whitespace that follows a boundary with the piece before it
+func splitAfter(s string, isEnd func(s string, i int) (end int, ok bool)) []string {
+	var pieces []string
+	start := 0
+	for i := 0; i < len(s); {
+		end, ok := isEnd(s, i)
+		if !ok {
+			_, size := utf8.DecodeRuneInString(s[i:])
+			i += size
+			continue
+		}
+		for end < len(s) {
+			r, size := utf8.DecodeRuneInString(s[end:])
+			if !unicode.IsSpace(r) {
+				break
+			}
+			end += size
+		}
+		pieces = append(pieces, s[start:end])
+		start, i = end, end
+	}
+	if start < len(s) {
+		pieces = append(pieces, s[start:])
+	}
+	return pieces
+}
+
+// sentenceEnd reports whether a sentence ends with the terminator at s[i],
+// and where its trailing punctuation ends
+func sentenceEnd(s string, i int) (int, bool) {
+	r, size := utf8.DecodeRuneInString(s[i:])
+	var fullwidth bool
+	switch r {
+	case '.', '!', '?', '…', '‼', '⁇', '؟', '।', '॥':
+	case '。', '！', '？', '｡':
+		fullwidth = true
+	default:
+		return 0, false
+	}
+
+	// Take in repeated terminators and closing quotes and brackets
+	end := i + size
+	for end < len(s) {
+		next, n := utf8.DecodeRuneInString(s[end:])
+		if !strings.ContainsRune(".!?…。！？\"'”’»)]}」』）", next) {
+			break
+		}
+		end += n
+	}
+
+	if end == len(s) {
+		return end, true
+	}
+	next, _ := utf8.DecodeRuneInString(s[end:])
+	if !unicode.IsSpace(next) {
+		// Decimals, version numbers and URLs have no space after the period
+		return end, fullwidth
+	}
+	if r == '.' && end == i+size && !periodEndsSentence(s, i, end) {
+		return 0, false
+	}
+	return end, true
+}
+
+// periodEndsSentence tells abbreviations and initials from the end of a sentence
+func periodEndsSentence(s string, i, end int) bool {
+	wordStart := i
+	for wordStart > 0 {
+		r, size := utf8.DecodeLastRuneInString(s[:wordStart])
+		if !unicode.IsLetter(r) && r != '.' {
+			break
+		}
+		wordStart -= size
+	}
+	word := strings.ToLower(s[wordStart:i])
+	if word == "" {
+		return true
+	}
+	if speechAbbreviations[word] || utf8.RuneCountInString(word) == 1 && unicode.IsUpper([]rune(s[wordStart:i])[0]) {
+		return false
+	}
+
+	// A lowercase word after the period continues the sentence
+	rest := strings.TrimLeftFunc(s[end:], unicode.IsSpace)
+	if next, _ := utf8.DecodeRuneInString(rest); unicode.IsLower(next) {
+		return false
+	}
+	return true
+}
+
+// clauseEnd reports a clause boundary after a comma, semicolon, colon or dash
+func clauseEnd(s string, i int) (int, bool) {
+	r, size := utf8.DecodeRuneInString(s[i:])
+	switch r {
+	case '，', '；', '：', '、':
+		return i + size, true
+	case ',', ';', ':', '—', '–':
+		if i+size < len(s) {
+			next, _ := utf8.DecodeRuneInString(s[i+size:])
+			if unicode.IsSpace(next) {
+				return i + size, true
+			}
+		}
+	}
+	return 0, false
+}
+
+// wordEnd reports a boundary at whitespace
+func wordEnd(s string, i int) (int, bool) {
+	r, size := utf8.DecodeRuneInString(s[i:])
+	if unicode.IsSpace(r) {
+		return i + size, true
+	}
+	return 0, false
+}
+
+// speechRunes counts the runes of s without its trailing whitespace, which is
+// trimmed from chunks
+func speechRunes(s string) int {
+	return utf8.RuneCountInString(strings.TrimRightFunc(s, unicode.IsSpace))
+}
+
+func appendChunk(chunks []string, chunk string) []string {
+	if chunk = strings.TrimSpace(chunk); chunk != "" {
+		chunks = append(chunks, chunk)
+	}
+	return chunks
+}
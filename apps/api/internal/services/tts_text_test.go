@@ -0,0 +1,88 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestSplitSpeechText(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		maxRunes int
+		want     []string
+	}{
+		{
+			name:     "fits in one chunk",
+			text:     "  Short text. ",
+			maxRunes: 100,
+			want:     []string{"Short text."},
+		},
+		{
+			name:     "sentences",
+			text:     "First sentence here. Second one! Third?",
+			maxRunes: 25,
+			want:     []string{"First sentence here.", "Second one! Third?"},
+		},
+		{
+			name:     "abbreviations and initials",
+			text:     "Dr. Smith met J. K. Rowling. They talked.",
+			maxRunes: 30,
+			want:     []string{"Dr. Smith met J. K. Rowling.", "They talked."},
+		},
+		{
+			name:     "decimals and lowercase continuation",
+			text:     "It costs 3.50 dollars approx. each day. Next.",
+			maxRunes: 40,
+			want:     []string{"It costs 3.50 dollars approx. each day.", "Next."},
+		},
+		{
+			name:     "closing quotes stay with the sentence",
+			text:     `He said "Stop." Then he left.`,
+			maxRunes: 20,
+			want:     []string{`He said "Stop."`, "Then he left."},
+		},
+		{
+			name:     "fullwidth terminators",
+			text:     "你好。世界！再见",
+			maxRunes: 3,
+			want:     []string{"你好。", "世界！", "再见"},
+		},
+		{
+			name:     "clauses",
+			text:     "one thing, another thing; last",
+			maxRunes: 16,
+			want:     []string{"one thing,", "another thing;", "last"},
+		},
+		{
+			name:     "words",
+			text:     "aaaa bbbb cccc dddd",
+			maxRunes: 9,
+			want:     []string{"aaaa bbbb", "cccc dddd"},
+		},
+		{
+			name:     "long word is cut on rune boundaries",
+			text:     "ééééé",
+			maxRunes: 2,
+			want:     []string{"éé", "éé", "é"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := splitSpeechText(tt.text, tt.maxRunes)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("splitSpeechText() = %q, want %q", got, tt.want)
+			}
+			for _, chunk := range got {
+				if n := utf8.RuneCountInString(chunk); n > tt.maxRunes {
+					t.Errorf("chunk %q has %d runes, max %d", chunk, n, tt.maxRunes)
+				}
+				if !utf8.ValidString(chunk) {
+					t.Errorf("chunk %q is not valid UTF-8", chunk)
+				}
+			}
+		})
+	}
+}
@@ -3,3 +3,30 @@ package services
 func stringPtr(s string) *string {
 	return &s
 }
+
+func optionalString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+// truncate cuts s to at most n characters, as VARCHAR(n) columns count them,
+// without splitting a UTF-8 sequence
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:runeOffset(s, n)]
+}
+
+// runeOffset returns the byte offset of the nth rune of s
+func runeOffset(s string, n int) int {
+	for i := range s {
+		if n == 0 {
+			return i
+		}
+		n--
+	}
+	return len(s)
+}
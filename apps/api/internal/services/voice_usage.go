@@ -0,0 +1,82 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"altread-go/api/internal/auth"
+	"altread-go/api/internal/database"
+	"altread-go/api/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// VoiceUsage is how often and how recently a tenant has played a voice
+type VoiceUsage struct {
+	VoiceName  string    `json:"voiceName"`
+	UsageCount int64     `json:"usageCount"`
+	LastUsed   time.Time `json:"lastUsed"`
+}
+
+// VoiceUsageService reads per-tenant voice popularity from voice_usage_stats
+type VoiceUsageService struct {
+	db *gorm.DB
+}
+
+// NewVoiceUsageService creates a new voice usage service instance
+func NewVoiceUsageService() *VoiceUsageService {
+	return &VoiceUsageService{
+		db: database.DB,
+	}
+}
+
+// PopularVoices returns up to limit voices played by the tenant in ctx, most
+// played first. Without a tenant, it returns the plays made without one.
+func (vs *VoiceUsageService) PopularVoices(ctx context.Context, limit int) ([]VoiceUsage, error) {
+	if vs.db == nil {
+		return nil, fmt.Errorf("database not initialized")
+	}
+
+	var rows []models.VoiceUsageStats
+	err := vs.db.WithContext(ctx).
+		Where("tenant_key = ? AND usage_count > 0", tenantKey(ctx)).
+		Order("usage_count DESC, last_used DESC, voice_name").
+		Limit(limit).
+		Find(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	usage := make([]VoiceUsage, len(rows))
+	for i, row := range rows {
+		usage[i] = VoiceUsage{
+			VoiceName:  row.VoiceName,
+			UsageCount: row.UsageCount,
+			LastUsed:   row.LastUsed,
+		}
+	}
+	return usage, nil
+}
+
+// countVoicePlay adds a successful play to the voice's usage for its tenant
+func countVoicePlay(tx *gorm.DB, play *models.VoicePlay) error {
+	key := ""
+	if play.TenantID != nil {
+		key = *play.TenantID
+	}
+	return tx.Exec(`INSERT INTO voice_usage_stats (tenant_key, voice_name, usage_count, last_used)
+	VALUES (?, ?, 1, ?)
+	ON CONFLICT (tenant_key, voice_name) DO UPDATE SET
+		usage_count = voice_usage_stats.usage_count + 1,
+		last_used = GREATEST(voice_usage_stats.last_used, EXCLUDED.last_used),
+		updated_at = NOW()`, key, play.VoiceName, play.CreatedAt).Error
+}
+
+// tenantKey returns the voice_usage_stats key of the tenant in ctx
+func tenantKey(ctx context.Context) string {
+	if tenantID := auth.TenantIDFromContext(ctx); tenantID != nil {
+		return *tenantID
+	}
+	return ""
+}
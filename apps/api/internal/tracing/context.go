@@ -0,0 +1,166 @@
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// Request correlation headers
+const (
+	HeaderRequestID   = "X-Request-ID"
+	HeaderTraceparent = "traceparent"
+)
+
+const maxRequestIDLength = 100
+
+// Trace identifies a request across services, logs and stored rows
+type Trace struct {
+	TraceID   string
+	SpanID    string
+	ParentID  string
+	RequestID string
+	Route     string
+	Sampled   bool
+}
+
+type traceKey struct{}
+
+// WithTrace returns a copy of ctx carrying the trace
+func WithTrace(ctx context.Context, t *Trace) context.Context {
+	return context.WithValue(ctx, traceKey{}, t)
+}
+
+// FromContext returns the trace stored in ctx, or nil
+func FromContext(ctx context.Context) *Trace {
+	t, _ := ctx.Value(traceKey{}).(*Trace)
+	return t
+}
+
+// TraceIDFromContext returns the trace ID stored in ctx, or nil if there is none
+func TraceIDFromContext(ctx context.Context) *string {
+	t := FromContext(ctx)
+	if t == nil || t.TraceID == "" {
+		return nil
+	}
+	traceID := t.TraceID
+	return &traceID
+}
+
+// RequestIDFromContext returns the request ID stored in ctx, or "" if there is none
+func RequestIDFromContext(ctx context.Context) string {
+	if t := FromContext(ctx); t != nil {
+		return t.RequestID
+	}
+	return ""
+}
+
+// Traceparent formats the trace as a W3C traceparent header value
+func (t *Trace) Traceparent() string {
+	flags := "00"
+	if t.Sampled {
+		flags = "01"
+	}
+	return fmt.Sprintf("00-%s-%s-%s", t.TraceID, t.SpanID, flags)
+}
+
+// ParseTraceparent extracts the trace ID, parent span ID and sampled flag from a
+// W3C traceparent header value
+func ParseTraceparent(value string) (traceID, parentID string, sampled bool, ok bool) {
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" {
+		return "", "", false, false
+	}
+	// Version 00 has exactly four fields; later versions may append more
+	if parts[0] == "00" && len(parts) != 4 {
+		return "", "", false, false
+	}
+
+	traceID, parentID, flags := parts[1], parts[2], parts[3]
+	if !isHex(traceID, 32) || !isHex(parentID, 16) || !isHex(flags, 2) {
+		return "", "", false, false
+	}
+	if traceID == strings.Repeat("0", 32) || parentID == strings.Repeat("0", 16) {
+		return "", "", false, false
+	}
+
+	flagBits, _ := hex.DecodeString(flags)
+	return traceID, parentID, flagBits[0]&0x01 == 1, true
+}
+
+// SanitizeRequestID returns a client-supplied request ID if it is safe to log and echo
+func SanitizeRequestID(value string) string {
+	value = strings.TrimSpace(value)
+	if value == "" || len(value) > maxRequestIDLength {
+		return ""
+	}
+	for _, r := range value {
+		if r < 0x21 || r > 0x7e {
+			return ""
+		}
+	}
+	return value
+}
+
+// NewTraceID returns a random 16-byte trace ID in hex
+func NewTraceID() string {
+	return randomHex(16)
+}
+
+// NewSpanID returns a random 8-byte span ID in hex
+func NewSpanID() string {
+	return randomHex(8)
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func isHex(s string, length int) bool {
+	if len(s) != length {
+		return false
+	}
+	for _, r := range s {
+		if !(r >= '0' && r <= '9' || r >= 'a' && r <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// Transport forwards the request ID and trace context of the outgoing request's
+// context to the upstream server
+type Transport struct {
+	Base http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	trace := FromContext(req.Context())
+	if trace == nil {
+		return base.RoundTrip(req)
+	}
+
+	req = req.Clone(req.Context())
+	req.Header.Set(HeaderRequestID, trace.RequestID)
+	if Enabled() {
+		// Parent the upstream call on the current client span rather than the server span
+		otel.GetTextMapPropagator().Inject(req.Context(), propagation.HeaderCarrier(req.Header))
+	} else {
+		req.Header.Set(HeaderTraceparent, trace.Traceparent())
+	}
+	return base.RoundTrip(req)
+}
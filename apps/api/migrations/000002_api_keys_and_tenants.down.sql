@@ -0,0 +1,11 @@
+-- Rollback API key authentication and tenant isolation
+
+DROP INDEX IF EXISTS idx_application_logs_tenant_id;
+DROP INDEX IF EXISTS idx_voice_plays_tenant_created_at;
+DROP INDEX IF EXISTS idx_image_uploads_tenant_created_at;
+
+ALTER TABLE application_logs DROP COLUMN IF EXISTS tenant_id;
+ALTER TABLE voice_plays DROP COLUMN IF EXISTS tenant_id;
+ALTER TABLE image_uploads DROP COLUMN IF EXISTS tenant_id;
+
+DROP TABLE IF EXISTS api_keys;
@@ -0,0 +1,27 @@
+-- API key authentication and tenant isolation
+
+-- API Keys Table (hashed machine credentials)
+CREATE TABLE IF NOT EXISTS api_keys (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    name VARCHAR(100) NOT NULL,
+    tenant_id VARCHAR(100) NOT NULL,
+    key_prefix VARCHAR(16) NOT NULL,
+    key_hash VARCHAR(64) NOT NULL UNIQUE,
+    scopes TEXT NOT NULL DEFAULT '',
+    expires_at TIMESTAMP WITH TIME ZONE,
+    revoked_at TIMESTAMP WITH TIME ZONE,
+    last_used_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+-- Create indexes for api_keys
+CREATE INDEX IF NOT EXISTS idx_api_keys_tenant_id ON api_keys(tenant_id);
+
+-- Record the tenant on event and log tables
+ALTER TABLE image_uploads ADD COLUMN IF NOT EXISTS tenant_id VARCHAR(100);
+ALTER TABLE voice_plays ADD COLUMN IF NOT EXISTS tenant_id VARCHAR(100);
+ALTER TABLE application_logs ADD COLUMN IF NOT EXISTS tenant_id VARCHAR(100);
+
+CREATE INDEX IF NOT EXISTS idx_image_uploads_tenant_created_at ON image_uploads(tenant_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_voice_plays_tenant_created_at ON voice_plays(tenant_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_application_logs_tenant_id ON application_logs(tenant_id);
@@ -0,0 +1,9 @@
+-- Rollback system_performance label columns
+
+DROP INDEX IF EXISTS idx_system_performance_name_model;
+DROP INDEX IF EXISTS idx_system_performance_name_route;
+
+ALTER TABLE system_performance DROP COLUMN IF EXISTS model;
+ALTER TABLE system_performance DROP COLUMN IF EXISTS operation;
+ALTER TABLE system_performance DROP COLUMN IF EXISTS route;
+ALTER TABLE system_performance DROP COLUMN IF EXISTS method;
@@ -0,0 +1,9 @@
+-- Store the labels of per-endpoint and per-provider samples in their own columns
+
+ALTER TABLE system_performance ADD COLUMN IF NOT EXISTS method VARCHAR(10);
+ALTER TABLE system_performance ADD COLUMN IF NOT EXISTS route VARCHAR(255);
+ALTER TABLE system_performance ADD COLUMN IF NOT EXISTS operation VARCHAR(50);
+ALTER TABLE system_performance ADD COLUMN IF NOT EXISTS model VARCHAR(100);
+
+CREATE INDEX IF NOT EXISTS idx_system_performance_name_route ON system_performance(metric_name, route, recorded_at);
+CREATE INDEX IF NOT EXISTS idx_system_performance_name_model ON system_performance(metric_name, model, recorded_at);
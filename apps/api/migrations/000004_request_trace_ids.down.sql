@@ -0,0 +1,7 @@
+-- Rollback request trace IDs on event tables
+
+DROP INDEX IF EXISTS idx_voice_plays_trace_id;
+DROP INDEX IF EXISTS idx_image_uploads_trace_id;
+
+ALTER TABLE voice_plays DROP COLUMN IF EXISTS trace_id;
+ALTER TABLE image_uploads DROP COLUMN IF EXISTS trace_id;
@@ -0,0 +1,7 @@
+-- Record the request trace ID on event tables so a request can be followed end to end
+
+ALTER TABLE image_uploads ADD COLUMN IF NOT EXISTS trace_id VARCHAR(100);
+ALTER TABLE voice_plays ADD COLUMN IF NOT EXISTS trace_id VARCHAR(100);
+
+CREATE INDEX IF NOT EXISTS idx_image_uploads_trace_id ON image_uploads(trace_id);
+CREATE INDEX IF NOT EXISTS idx_voice_plays_trace_id ON voice_plays(trace_id);
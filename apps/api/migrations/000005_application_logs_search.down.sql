@@ -0,0 +1,4 @@
+-- Rollback application_logs search indexes
+
+DROP INDEX IF EXISTS idx_application_logs_timestamp_id;
+DROP INDEX IF EXISTS idx_application_logs_message_fts;
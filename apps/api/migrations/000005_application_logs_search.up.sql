@@ -0,0 +1,7 @@
+-- Support full-text message search and keyset pagination over application_logs
+
+CREATE INDEX IF NOT EXISTS idx_application_logs_message_fts
+    ON application_logs USING GIN (to_tsvector('english', message));
+
+CREATE INDEX IF NOT EXISTS idx_application_logs_timestamp_id
+    ON application_logs(timestamp DESC, id DESC);
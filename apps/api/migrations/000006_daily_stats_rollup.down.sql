@@ -0,0 +1,33 @@
+-- Rollback daily_stats rollup
+
+DROP TABLE IF EXISTS daily_voice_stats;
+
+DROP INDEX IF EXISTS idx_daily_stats_date_tenant;
+DELETE FROM daily_stats;
+
+ALTER TABLE daily_stats DROP COLUMN IF EXISTS successful_voice_plays;
+ALTER TABLE daily_stats DROP COLUMN IF EXISTS processing_time_samples;
+ALTER TABLE daily_stats DROP COLUMN IF EXISTS failed_images;
+ALTER TABLE daily_stats DROP COLUMN IF EXISTS successful_images;
+ALTER TABLE daily_stats DROP COLUMN IF EXISTS tenant_key;
+ALTER TABLE daily_stats ADD CONSTRAINT daily_stats_date_key UNIQUE (date);
+
+CREATE OR REPLACE FUNCTION update_daily_stats()
+RETURNS TRIGGER AS $$
+BEGIN
+    INSERT INTO daily_stats (date, total_images, total_alt_texts, total_voice_plays)
+    VALUES (CURRENT_DATE, 0, 0, 0)
+    ON CONFLICT (date) DO UPDATE SET
+        total_images = daily_stats.total_images + CASE WHEN NEW.event_type = 'image_uploaded' THEN 1 ELSE 0 END,
+        total_alt_texts = daily_stats.total_alt_texts + CASE WHEN NEW.event_type = 'alt_text_generated' THEN 1 ELSE 0 END,
+        total_voice_plays = daily_stats.total_voice_plays + CASE WHEN NEW.event_type = 'voice_play_started' THEN 1 ELSE 0 END,
+        updated_at = NOW();
+
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER trigger_update_daily_stats
+    AFTER INSERT ON analytics_events
+    FOR EACH ROW
+    EXECUTE FUNCTION update_daily_stats();
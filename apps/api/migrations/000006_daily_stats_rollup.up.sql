@@ -0,0 +1,27 @@
+-- Maintain daily_stats from image_uploads and voice_plays with a rollup job
+-- instead of the analytics_events trigger. Rows are per day and tenant; an empty
+-- tenant_key holds traffic without a tenant so the unique key has no NULLs.
+
+DROP TRIGGER IF EXISTS trigger_update_daily_stats ON analytics_events;
+DROP FUNCTION IF EXISTS update_daily_stats();
+
+-- Rows written by the old trigger were never populated
+DELETE FROM daily_stats;
+
+ALTER TABLE daily_stats DROP CONSTRAINT IF EXISTS daily_stats_date_key;
+ALTER TABLE daily_stats ADD COLUMN IF NOT EXISTS tenant_key VARCHAR(100) NOT NULL DEFAULT '';
+ALTER TABLE daily_stats ADD COLUMN IF NOT EXISTS successful_images INTEGER DEFAULT 0;
+ALTER TABLE daily_stats ADD COLUMN IF NOT EXISTS failed_images INTEGER DEFAULT 0;
+ALTER TABLE daily_stats ADD COLUMN IF NOT EXISTS processing_time_samples INTEGER DEFAULT 0;
+ALTER TABLE daily_stats ADD COLUMN IF NOT EXISTS successful_voice_plays INTEGER DEFAULT 0;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_daily_stats_date_tenant ON daily_stats(date, tenant_key);
+
+-- Per-voice daily play counts
+CREATE TABLE IF NOT EXISTS daily_voice_stats (
+    date DATE NOT NULL,
+    tenant_key VARCHAR(100) NOT NULL DEFAULT '',
+    voice_name VARCHAR(100) NOT NULL,
+    play_count INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (date, tenant_key, voice_name)
+);
@@ -0,0 +1,8 @@
+-- Rollback analytics_events tenant, trace and client user columns
+
+DROP INDEX IF EXISTS idx_analytics_events_trace_id;
+DROP INDEX IF EXISTS idx_analytics_events_tenant_created_at;
+
+ALTER TABLE analytics_events DROP COLUMN IF EXISTS client_user_id;
+ALTER TABLE analytics_events DROP COLUMN IF EXISTS trace_id;
+ALTER TABLE analytics_events DROP COLUMN IF EXISTS tenant_id;
@@ -0,0 +1,9 @@
+-- Record the tenant and request trace on analytics_events, and keep the
+-- client-supplied X-User-ID header apart from the authenticated user_id
+
+ALTER TABLE analytics_events ADD COLUMN IF NOT EXISTS tenant_id VARCHAR(100);
+ALTER TABLE analytics_events ADD COLUMN IF NOT EXISTS trace_id VARCHAR(100);
+ALTER TABLE analytics_events ADD COLUMN IF NOT EXISTS client_user_id VARCHAR(100);
+
+CREATE INDEX IF NOT EXISTS idx_analytics_events_tenant_created_at ON analytics_events(tenant_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_analytics_events_trace_id ON analytics_events(trace_id);
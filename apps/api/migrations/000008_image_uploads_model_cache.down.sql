@@ -0,0 +1,12 @@
+-- Rollback image_uploads model and cache columns and the daily quality rollups
+
+DROP TABLE IF EXISTS daily_latency_stats;
+DROP TABLE IF EXISTS daily_error_stats;
+DROP TABLE IF EXISTS daily_model_stats;
+
+ALTER TABLE daily_stats DROP COLUMN IF EXISTS cache_hits;
+
+DROP INDEX IF EXISTS idx_image_uploads_model_used;
+
+ALTER TABLE image_uploads DROP COLUMN IF EXISTS cache_hit;
+ALTER TABLE image_uploads DROP COLUMN IF EXISTS model_used;
@@ -0,0 +1,43 @@
+-- Record the model that produced each result and whether it was served from
+-- cache, and roll up the per-model, error and latency figures of the analytics
+-- quality stats so completed days need not be read from image_uploads. Image
+-- totals in daily_stats leave out cache hits, which are counted separately.
+
+ALTER TABLE image_uploads ADD COLUMN IF NOT EXISTS model_used VARCHAR(100);
+ALTER TABLE image_uploads ADD COLUMN IF NOT EXISTS cache_hit BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE INDEX IF NOT EXISTS idx_image_uploads_model_used ON image_uploads(model_used);
+
+ALTER TABLE daily_stats ADD COLUMN IF NOT EXISTS cache_hits INTEGER DEFAULT 0;
+
+-- Per-model daily generation counts, leaving out cache hits
+CREATE TABLE IF NOT EXISTS daily_model_stats (
+    date DATE NOT NULL,
+    tenant_key VARCHAR(100) NOT NULL DEFAULT '',
+    model_used VARCHAR(100) NOT NULL,
+    total INTEGER NOT NULL DEFAULT 0,
+    failed INTEGER NOT NULL DEFAULT 0,
+    total_processing_time_ms BIGINT NOT NULL DEFAULT 0,
+    processing_time_samples INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (date, tenant_key, model_used)
+);
+
+-- Daily counts of failed generations by raw error message, truncated to 200 characters
+CREATE TABLE IF NOT EXISTS daily_error_stats (
+    date DATE NOT NULL,
+    tenant_key VARCHAR(100) NOT NULL DEFAULT '',
+    message VARCHAR(200) NOT NULL,
+    error_count INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (date, tenant_key, message)
+);
+
+-- Daily processing time histogram, in the log-spaced buckets of the in-process
+-- latency windows, so percentiles can be merged across days
+CREATE TABLE IF NOT EXISTS daily_latency_stats (
+    date DATE NOT NULL,
+    tenant_key VARCHAR(100) NOT NULL DEFAULT '',
+    latency_bucket SMALLINT NOT NULL,
+    request_count INTEGER NOT NULL DEFAULT 0,
+    total_processing_time_ms BIGINT NOT NULL DEFAULT 0,
+    PRIMARY KEY (date, tenant_key, latency_bucket)
+);
@@ -0,0 +1,12 @@
+-- Rollback image_uploads request metadata columns
+
+ALTER TABLE daily_model_stats DROP COLUMN IF EXISTS fallbacks;
+ALTER TABLE daily_model_stats DROP COLUMN IF EXISTS cache_hits;
+
+DROP INDEX IF EXISTS idx_image_uploads_cache_hit_created_at;
+
+ALTER TABLE image_uploads DROP COLUMN IF EXISTS auth_method;
+ALTER TABLE image_uploads DROP COLUMN IF EXISTS user_agent;
+ALTER TABLE image_uploads DROP COLUMN IF EXISTS fallback_used;
+ALTER TABLE image_uploads DROP COLUMN IF EXISTS language;
+ALTER TABLE image_uploads DROP COLUMN IF EXISTS prompt_options;
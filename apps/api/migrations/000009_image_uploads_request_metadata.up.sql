@@ -0,0 +1,13 @@
+-- Record prompt options, language, fallback use and client details on image_uploads
+
+ALTER TABLE image_uploads ADD COLUMN IF NOT EXISTS prompt_options JSONB;
+ALTER TABLE image_uploads ADD COLUMN IF NOT EXISTS language VARCHAR(35);
+ALTER TABLE image_uploads ADD COLUMN IF NOT EXISTS fallback_used BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE image_uploads ADD COLUMN IF NOT EXISTS user_agent TEXT;
+ALTER TABLE image_uploads ADD COLUMN IF NOT EXISTS auth_method VARCHAR(20);
+
+CREATE INDEX IF NOT EXISTS idx_image_uploads_cache_hit_created_at ON image_uploads(cache_hit, created_at);
+
+-- Per-model cache hits and fallbacks; days rolled up earlier count none
+ALTER TABLE daily_model_stats ADD COLUMN IF NOT EXISTS cache_hits INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE daily_model_stats ADD COLUMN IF NOT EXISTS fallbacks INTEGER NOT NULL DEFAULT 0;
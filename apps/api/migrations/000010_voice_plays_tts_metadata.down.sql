@@ -0,0 +1,11 @@
+-- Rollback voice_plays TTS metadata columns
+
+DROP INDEX IF EXISTS idx_voice_plays_model_used;
+
+COMMENT ON COLUMN voice_plays.duration_ms IS NULL;
+
+ALTER TABLE voice_plays DROP COLUMN IF EXISTS error_code;
+ALTER TABLE voice_plays DROP COLUMN IF EXISTS audio_bytes;
+ALTER TABLE voice_plays DROP COLUMN IF EXISTS response_format;
+ALTER TABLE voice_plays DROP COLUMN IF EXISTS speed;
+ALTER TABLE voice_plays DROP COLUMN IF EXISTS model_used;
@@ -0,0 +1,11 @@
+-- Record the TTS request options, audio size and error code of each speech request
+
+ALTER TABLE voice_plays ADD COLUMN IF NOT EXISTS model_used VARCHAR(50);
+ALTER TABLE voice_plays ADD COLUMN IF NOT EXISTS speed REAL;
+ALTER TABLE voice_plays ADD COLUMN IF NOT EXISTS response_format VARCHAR(10);
+ALTER TABLE voice_plays ADD COLUMN IF NOT EXISTS audio_bytes INTEGER;
+ALTER TABLE voice_plays ADD COLUMN IF NOT EXISTS error_code VARCHAR(50);
+
+COMMENT ON COLUMN voice_plays.duration_ms IS 'Speech synthesis latency in milliseconds';
+
+CREATE INDEX IF NOT EXISTS idx_voice_plays_model_used ON voice_plays(model_used);
@@ -0,0 +1,32 @@
+-- Rollback per-tenant voice_usage_stats and restore the analytics_events trigger
+
+DELETE FROM voice_usage_stats;
+
+DROP INDEX IF EXISTS idx_voice_usage_stats_tenant_count;
+DROP INDEX IF EXISTS idx_voice_usage_stats_tenant_voice;
+CREATE INDEX IF NOT EXISTS idx_voice_usage_stats_count ON voice_usage_stats(usage_count DESC);
+
+ALTER TABLE voice_usage_stats DROP COLUMN IF EXISTS tenant_key;
+ALTER TABLE voice_usage_stats ADD CONSTRAINT voice_usage_stats_voice_name_key UNIQUE (voice_name);
+
+CREATE OR REPLACE FUNCTION update_voice_usage_stats()
+RETURNS TRIGGER AS $$
+BEGIN
+    -- Update voice usage when voice plays are recorded
+    IF NEW.event_type = 'voice_play_started' AND NEW.event_data->>'voice_name' IS NOT NULL THEN
+        INSERT INTO voice_usage_stats (voice_name, usage_count, last_used)
+        VALUES (NEW.event_data->>'voice_name', 1, NOW())
+        ON CONFLICT (voice_name) DO UPDATE SET
+            usage_count = voice_usage_stats.usage_count + 1,
+            last_used = NOW(),
+            updated_at = NOW();
+    END IF;
+
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER trigger_update_voice_usage_stats
+    AFTER INSERT ON analytics_events
+    FOR EACH ROW
+    EXECUTE FUNCTION update_voice_usage_stats();
@@ -0,0 +1,21 @@
+-- Maintain voice_usage_stats per tenant from successful voice_plays instead of
+-- the analytics_events trigger. An empty tenant_key holds plays without a tenant.
+
+DROP TRIGGER IF EXISTS trigger_update_voice_usage_stats ON analytics_events;
+DROP FUNCTION IF EXISTS update_voice_usage_stats();
+
+ALTER TABLE voice_usage_stats DROP CONSTRAINT IF EXISTS voice_usage_stats_voice_name_key;
+ALTER TABLE voice_usage_stats ADD COLUMN IF NOT EXISTS tenant_key VARCHAR(100) NOT NULL DEFAULT '';
+
+DROP INDEX IF EXISTS idx_voice_usage_stats_count;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_voice_usage_stats_tenant_voice ON voice_usage_stats(tenant_key, voice_name);
+CREATE INDEX IF NOT EXISTS idx_voice_usage_stats_tenant_count ON voice_usage_stats(tenant_key, usage_count DESC);
+
+-- Rebuild the counts from the plays recorded so far
+DELETE FROM voice_usage_stats;
+
+INSERT INTO voice_usage_stats (tenant_key, voice_name, usage_count, last_used)
+SELECT COALESCE(tenant_id, ''), voice_name, COUNT(*), MAX(created_at)
+FROM voice_plays
+WHERE success AND voice_name IS NOT NULL
+GROUP BY 1, 2;
@@ -1,6 +1,7 @@
 import { useQuery } from '@tanstack/react-query'
 import { AnalyticsData, TimeRange } from '@altread/types'
-import { API_BASE_URL } from '../utils/api'
+import { API_BASE_URL, fetchWithBackoff } from '../utils/api'
+import { authHeaders } from '../utils/auth'
 
 interface AnalyticsResponse {
   success: boolean
@@ -9,11 +10,11 @@ interface AnalyticsResponse {
 }
 
 const fetchAnalytics = async (timeRange: TimeRange): Promise<AnalyticsData> => {
-  const response = await fetch(`${API_BASE_URL}/analytics?timeRange=${timeRange}`, {
+  const response = await fetchWithBackoff(`${API_BASE_URL}/analytics?timeRange=${timeRange}`, {
     method: 'GET',
-    headers: {
+    headers: authHeaders({
       'Content-Type': 'application/json',
-    },
+    }),
   })
 
   const result: AnalyticsResponse = await response.json()
@@ -1,6 +1,7 @@
 import { useState, useCallback } from 'react'
 import { GenerateAltTextRequest, GenerateAltTextResponse } from '../types'
-import { API_BASE_URL } from '../utils/api'
+import { API_BASE_URL, fetchWithBackoff } from '../utils/api'
+import { authHeaders } from '../utils/auth'
 
 export const useApi = () => {
   const [isLoading, setIsLoading] = useState(false)
@@ -11,11 +12,11 @@ export const useApi = () => {
     setError(null)
 
     try {
-      const response = await fetch(`${API_BASE_URL}/alt-text`, {
+      const response = await fetchWithBackoff(`${API_BASE_URL}/alt-text`, {
         method: 'POST',
-        headers: {
+        headers: authHeaders({
           'Content-Type': 'application/json',
-        },
+        }),
         body: JSON.stringify(request),
       })
 
@@ -1,6 +1,7 @@
 import { useState, useCallback, useRef } from 'react'
 import { VoiceSettings, OpenAIVoice } from '../types'
-import { API_BASE_URL_NO_PATH } from '../utils/api'
+import { API_BASE_URL_NO_PATH, fetchWithBackoff } from '../utils/api'
+import { authHeaders } from '../utils/auth'
 
 interface UseOpenAITTSReturn {
   isPlaying: boolean
@@ -26,7 +27,9 @@ export const useOpenAITTS = (): UseOpenAITTSReturn => {
   const loadVoices = useCallback(async () => {
     try {
       setError(null)
-      const response = await fetch(`${API_BASE_URL_NO_PATH}/api/v1/voice/openai/voices`)
+      const response = await fetchWithBackoff(`${API_BASE_URL_NO_PATH}/api/v1/voice/openai/voices`, {
+        headers: authHeaders(),
+      })
       const data = await response.json()
       
       if (data.success) {
@@ -65,11 +68,11 @@ export const useOpenAITTS = (): UseOpenAITTSReturn => {
         audioRef.current = null
       }
 
-      const response = await fetch(`${API_BASE_URL_NO_PATH}/api/v1/voice/openai/speech`, {
+      const response = await fetchWithBackoff(`${API_BASE_URL_NO_PATH}/api/v1/voice/openai/speech`, {
         method: 'POST',
-        headers: {
+        headers: authHeaders({
           'Content-Type': 'application/json',
-        },
+        }),
         body: JSON.stringify({
           text,
           voice: settings.voice,
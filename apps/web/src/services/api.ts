@@ -1,4 +1,5 @@
-import { API_BASE_URL } from '../utils/api'
+import { API_BASE_URL, fetchWithBackoff } from '../utils/api'
+import { authHeaders } from '../utils/auth'
 import { AnalyticsData, TimeRange } from '@altread/types'
 
 export interface ApiResponse<T> {
@@ -9,11 +10,11 @@ export interface ApiResponse<T> {
 }
 
 export const getAnalytics = async (timeRange: TimeRange): Promise<AnalyticsData> => {
-  const response = await fetch(`${API_BASE_URL}/analytics?timeRange=${timeRange}`, {
+  const response = await fetchWithBackoff(`${API_BASE_URL}/analytics?timeRange=${timeRange}`, {
     method: 'GET',
-    headers: {
+    headers: authHeaders({
       'Content-Type': 'application/json',
-    },
+    }),
   })
 
   const result: ApiResponse<AnalyticsData> = await response.json()
@@ -8,3 +8,59 @@ export const API_BASE_URL_NO_PATH = (() => {
   return url.includes('/api/v1') ? url.replace('/api/v1', '') : 'http://localhost:3001'
 })()
 
+
+const MAX_RATE_LIMIT_RETRIES = 2
+const MAX_RETRY_DELAY_MS = 30_000
+const BASE_BACKOFF_MS = 1000
+
+const sleep = (ms: number) => new Promise((resolve) => setTimeout(resolve, ms))
+
+// Parses a header holding a whole number of seconds, or returns null
+const parseSeconds = (value: string | null): number | null => {
+  if (value === null || !/^\s*\d+\s*$/.test(value)) {
+    return null
+  }
+  return Number(value) * 1000
+}
+
+// Milliseconds to wait before retrying, from Retry-After (seconds or HTTP date)
+// or RateLimit-Reset, or null when neither header is present and valid
+export const getRetryDelayMs = (response: Response): number | null => {
+  const retryAfter = response.headers.get('Retry-After')
+  if (retryAfter) {
+    const seconds = parseSeconds(retryAfter)
+    if (seconds !== null) {
+      return seconds
+    }
+    const date = Date.parse(retryAfter)
+    if (!Number.isNaN(date)) {
+      return Math.max(0, date - Date.now())
+    }
+  }
+  return parseSeconds(response.headers.get('RateLimit-Reset'))
+}
+
+// Exponential backoff with jitter, for responses without a usable retry header
+const backoffDelayMs = (attempt: number): number => {
+  const delay = BASE_BACKOFF_MS * 2 ** attempt
+  return delay / 2 + Math.random() * (delay / 2)
+}
+
+// fetch that waits and retries when the API responds 429 Too Many Requests
+export const fetchWithBackoff = async (
+  input: RequestInfo | URL,
+  init?: RequestInit,
+  maxRetries: number = MAX_RATE_LIMIT_RETRIES
+): Promise<Response> => {
+  for (let attempt = 0; ; attempt++) {
+    const response = await fetch(input, init)
+    if (response.status !== 429 || attempt >= maxRetries) {
+      return response
+    }
+    const delay = getRetryDelayMs(response) ?? backoffDelayMs(attempt)
+    if (delay > MAX_RETRY_DELAY_MS) {
+      return response
+    }
+    await sleep(delay)
+  }
+}
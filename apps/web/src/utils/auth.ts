@@ -0,0 +1,19 @@
+const TOKEN_STORAGE_KEY = 'altread.accessToken'
+
+// Reads the bearer token the host page stored for the dashboard, if any
+export const getAuthToken = (): string | null => {
+  try {
+    return window.localStorage.getItem(TOKEN_STORAGE_KEY)
+  } catch {
+    // localStorage can be unavailable (e.g. privacy mode)
+    return null
+  }
+}
+
+export const authHeaders = (headers: Record<string, string> = {}): Record<string, string> => {
+  const token = getAuthToken()
+  if (!token) {
+    return headers
+  }
+  return { ...headers, Authorization: `Bearer ${token}` }
+}